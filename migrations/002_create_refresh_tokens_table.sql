@@ -0,0 +1,23 @@
+-- Create refresh tokens table
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id UUID NOT NULL,
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+-- Create indexes for faster lookups
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
+
+-- Add comments
+COMMENT ON TABLE refresh_tokens IS 'Stores hashed opaque refresh tokens grouped into rotation families';
+COMMENT ON COLUMN refresh_tokens.family_id IS 'Session identifier shared by every token rotated from the same login';
+COMMENT ON COLUMN refresh_tokens.token_hash IS 'SHA-256 hex digest of the opaque refresh token';
+COMMENT ON COLUMN refresh_tokens.used_at IS 'Set when the token was exchanged; a second use revokes the family';
+COMMENT ON COLUMN refresh_tokens.revoked_at IS 'Set when the token or its family has been revoked';
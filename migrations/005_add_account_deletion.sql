@@ -0,0 +1,10 @@
+-- Add scheduled account deletion state to users
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+-- Create indexes for the purge job
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_at ON users(deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL;
+
+-- Add comments
+COMMENT ON COLUMN users.deletion_scheduled_at IS 'When a requested account deletion will be carried out; logging in before then cancels it';
+COMMENT ON COLUMN users.deleted_at IS 'When the account was anonymized; the row is kept so foreign keys stay valid';
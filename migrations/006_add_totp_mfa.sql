@@ -0,0 +1,21 @@
+-- Add TOTP two-factor authentication state to users
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled_at TIMESTAMP;
+
+-- Create recovery codes table
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash CHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    used_at TIMESTAMP
+);
+
+-- Create indexes for faster lookups
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id);
+
+-- Add comments
+COMMENT ON COLUMN users.totp_secret IS 'AES-GCM encrypted TOTP secret; set during enrolment, cleared when 2FA is disabled';
+COMMENT ON COLUMN users.totp_enabled IS 'Whether TOTP enrolment was confirmed and is enforced at login';
+COMMENT ON TABLE mfa_recovery_codes IS 'Stores SHA-256 hashes of single-use 2FA recovery codes';
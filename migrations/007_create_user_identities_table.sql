@@ -0,0 +1,20 @@
+-- Create user identities table for social / OIDC login
+CREATE TABLE IF NOT EXISTS user_identities (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_login_at TIMESTAMP,
+    UNIQUE (provider, subject),
+    UNIQUE (user_id, provider)
+);
+
+-- Create indexes for faster lookups
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+-- Add comments
+COMMENT ON TABLE user_identities IS 'Links identity provider accounts (OIDC subject IDs) to users';
+COMMENT ON COLUMN user_identities.provider IS 'Provider name as configured in oidc_providers';
+COMMENT ON COLUMN user_identities.subject IS 'Stable subject identifier issued by the provider';
@@ -0,0 +1,18 @@
+-- Create JWT signing keys table for asymmetric token signing with rotation
+CREATE TABLE IF NOT EXISTS jwt_signing_keys (
+    kid VARCHAR(64) PRIMARY KEY,
+    algorithm VARCHAR(10) NOT NULL,
+    private_key TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    activates_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP
+);
+
+-- Create indexes for faster lookups
+CREATE INDEX IF NOT EXISTS idx_jwt_signing_keys_expires_at ON jwt_signing_keys(expires_at);
+
+-- Add comments
+COMMENT ON TABLE jwt_signing_keys IS 'Asymmetric keys used to sign access tokens, published at /.well-known/jwks.json';
+COMMENT ON COLUMN jwt_signing_keys.private_key IS 'PKCS#8 private key encrypted with JWT_KEY_ENCRYPTION_KEY';
+COMMENT ON COLUMN jwt_signing_keys.activates_at IS 'Time from which the key signs new tokens';
+COMMENT ON COLUMN jwt_signing_keys.expires_at IS 'Time after which tokens signed by the key are no longer accepted';
@@ -0,0 +1,28 @@
+-- Create service accounts and API keys for non-human clients (e.g. game server nodes)
+CREATE TABLE IF NOT EXISTS service_accounts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(100) UNIQUE NOT NULL,
+    description TEXT,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    disabled_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS service_api_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    service_account_id UUID NOT NULL REFERENCES service_accounts(id) ON DELETE CASCADE,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) UNIQUE NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+-- Create indexes for faster lookups
+CREATE INDEX IF NOT EXISTS idx_service_api_keys_account_id ON service_api_keys(service_account_id);
+
+-- Add comments
+COMMENT ON TABLE service_accounts IS 'Non-human principals authenticated by API key';
+COMMENT ON COLUMN service_accounts.scopes IS 'Granted scopes, e.g. registry:write, matches:report';
+COMMENT ON COLUMN service_api_keys.key_hash IS 'SHA-256 hash of the raw API key';
+COMMENT ON COLUMN service_api_keys.prefix IS 'First characters of the key, shown to identify it';
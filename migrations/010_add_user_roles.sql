@@ -0,0 +1,16 @@
+-- Add roles to users for role-based access control
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'player';
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'users_role_check') THEN
+        ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('player', 'moderator', 'admin'));
+    END IF;
+END $$;
+
+-- Create indexes for faster lookups of staff accounts
+CREATE INDEX IF NOT EXISTS idx_users_role ON users(role) WHERE role <> 'player';
+
+-- Add comments
+COMMENT ON COLUMN users.role IS 'Access role: player, moderator or admin';
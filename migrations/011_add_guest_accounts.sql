@@ -0,0 +1,11 @@
+-- Add guest accounts that can later be upgraded to full accounts
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS is_guest BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS guest_expires_at TIMESTAMP;
+
+-- Create indexes for faster lookups of expired guests
+CREATE INDEX IF NOT EXISTS idx_users_guest_expires_at ON users(guest_expires_at) WHERE is_guest;
+
+-- Add comments
+COMMENT ON COLUMN users.is_guest IS 'Guest account with a generated name and placeholder email';
+COMMENT ON COLUMN users.guest_expires_at IS 'When an unupgraded guest account is purged';
@@ -0,0 +1,18 @@
+-- Create user sessions table for device management
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_name VARCHAR(100),
+    user_agent TEXT,
+    ip VARCHAR(64),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes for faster lookups
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user_id ON user_sessions(user_id);
+
+-- Add comments
+COMMENT ON TABLE user_sessions IS 'One row per login, keyed by the refresh token family (sid claim)';
+COMMENT ON COLUMN user_sessions.device_name IS 'Short label derived from the User-Agent, e.g. "Firefox on Windows"';
+COMMENT ON COLUMN user_sessions.last_used_at IS 'Last refresh; request activity between refreshes is kept in Redis';
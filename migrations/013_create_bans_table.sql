@@ -0,0 +1,23 @@
+-- Create bans table for suspensions and permanent bans
+CREATE TABLE IF NOT EXISTS bans (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    scope VARCHAR(20) NOT NULL CHECK (scope IN ('login', 'chat', 'ranked')),
+    reason TEXT NOT NULL,
+    issued_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP,
+    lifted_at TIMESTAMP,
+    lifted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    lift_reason TEXT
+);
+
+-- Create indexes for faster lookups of active bans
+CREATE INDEX IF NOT EXISTS idx_bans_user_id ON bans(user_id);
+CREATE INDEX IF NOT EXISTS idx_bans_active ON bans(user_id, scope) WHERE lifted_at IS NULL;
+
+-- Add comments
+COMMENT ON TABLE bans IS 'Bans and temporary suspensions; rows are kept after expiry as moderation history';
+COMMENT ON COLUMN bans.scope IS 'login blocks every entry point, chat blocks messaging, ranked blocks ranked queues';
+COMMENT ON COLUMN bans.expires_at IS 'NULL for a permanent ban';
+COMMENT ON COLUMN bans.issued_by IS 'Moderator who issued the ban, NULL when issued from the server console';
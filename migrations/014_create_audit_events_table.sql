@@ -0,0 +1,34 @@
+-- Create append-only audit log for authentication and account events
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_type VARCHAR(50) NOT NULL,
+    actor_id UUID,
+    subject_id UUID,
+    ip VARCHAR(64),
+    user_agent TEXT,
+    metadata JSONB,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes for the admin filters and retention pruning
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events(actor_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_subject_id ON audit_events(subject_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_type ON audit_events(event_type, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events(created_at);
+
+-- Events are never edited; old rows are only removed by retention pruning
+CREATE OR REPLACE FUNCTION reject_audit_event_update() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION reject_audit_event_update();
+
+-- Add comments
+COMMENT ON TABLE audit_events IS 'Append-only security audit log, pruned after audit_retention_days';
+COMMENT ON COLUMN audit_events.actor_id IS 'User who performed the action, NULL for anonymous or console actions';
+COMMENT ON COLUMN audit_events.subject_id IS 'User the event is about; not a foreign key so history outlives the account';
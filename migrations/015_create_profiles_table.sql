@@ -0,0 +1,42 @@
+-- Create public player profiles
+CREATE TABLE IF NOT EXISTS profiles (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    display_name VARCHAR(64),
+    avatar_url TEXT,
+    country CHAR(2),
+    bio TEXT,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create per-mode ratings and match history shown on profiles
+CREATE TABLE IF NOT EXISTS player_ratings (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    mode VARCHAR(50) NOT NULL,
+    rating INTEGER NOT NULL DEFAULT 1500,
+    games_played INTEGER NOT NULL DEFAULT 0,
+    wins INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, mode)
+);
+
+CREATE TABLE IF NOT EXISTS match_history (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    match_id UUID NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    mode VARCHAR(50) NOT NULL,
+    placement INTEGER NOT NULL,
+    result VARCHAR(10) NOT NULL CHECK (result IN ('win', 'loss', 'draw')),
+    rating_change INTEGER NOT NULL DEFAULT 0,
+    played_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (match_id, user_id)
+);
+
+-- Create indexes for faster lookups
+CREATE INDEX IF NOT EXISTS idx_match_history_user_played ON match_history(user_id, played_at DESC);
+
+-- Add comments
+COMMENT ON TABLE profiles IS 'Editable public profile fields; the row is optional and created on first edit';
+COMMENT ON COLUMN profiles.display_name IS 'Shown instead of the username when set';
+COMMENT ON COLUMN profiles.country IS 'ISO 3166-1 alpha-2 country code';
+COMMENT ON TABLE player_ratings IS 'Current rating of a player per game mode';
+COMMENT ON TABLE match_history IS 'One row per player per finished match, newest shown on profiles';
@@ -0,0 +1,7 @@
+-- Add uploaded avatars; the hash names the stored image set and appears in its URLs
+ALTER TABLE profiles
+    ADD COLUMN IF NOT EXISTS avatar_hash VARCHAR(64);
+
+-- Add comments
+COMMENT ON COLUMN profiles.avatar_hash IS 'Content hash of the uploaded avatar, NULL when none is uploaded';
+COMMENT ON COLUMN profiles.avatar_url IS 'External avatar URL, used when no avatar is uploaded';
@@ -0,0 +1,17 @@
+-- Create friendships table for friend requests, friends and blocks
+CREATE TABLE IF NOT EXISTS friendships (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    friend_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'accepted', 'blocked')),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, friend_id),
+    CHECK (user_id <> friend_id)
+);
+
+-- Create indexes for faster lookups of incoming requests and blocks
+CREATE INDEX IF NOT EXISTS idx_friendships_friend_id ON friendships(friend_id, status);
+
+-- Add comments
+COMMENT ON TABLE friendships IS 'Directed relations between two users';
+COMMENT ON COLUMN friendships.status IS 'pending: user_id asked friend_id; accepted: stored in both directions; blocked: user_id blocked friend_id';
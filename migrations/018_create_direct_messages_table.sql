@@ -0,0 +1,22 @@
+-- Create direct_messages table for persistent one-to-one messages
+CREATE TABLE IF NOT EXISTS direct_messages (
+    id BIGSERIAL PRIMARY KEY,
+    sender_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    recipient_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP,
+    read_at TIMESTAMP,
+    CHECK (sender_id <> recipient_id)
+);
+
+-- Create indexes for conversation history and offline delivery
+CREATE INDEX IF NOT EXISTS idx_direct_messages_conversation ON direct_messages(LEAST(sender_id, recipient_id), GREATEST(sender_id, recipient_id), id DESC);
+CREATE INDEX IF NOT EXISTS idx_direct_messages_undelivered ON direct_messages(recipient_id, id) WHERE delivered_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_direct_messages_unread ON direct_messages(recipient_id, sender_id) WHERE read_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_direct_messages_sender_id ON direct_messages(sender_id);
+
+-- Add comments
+COMMENT ON TABLE direct_messages IS 'One-to-one chat messages between two users';
+COMMENT ON COLUMN direct_messages.delivered_at IS 'When a socket of the recipient first received the message; NULL while it is queued';
+COMMENT ON COLUMN direct_messages.read_at IS 'When the recipient marked the message as read';
@@ -36,22 +36,28 @@ Write-Host "Database: $DB_NAME at $DB_HOST:$DB_PORT" -ForegroundColor Cyan
 # Set PGPASSWORD environment variable for psql
 $env:PGPASSWORD = $DB_PASSWORD
 
-# Run migrations
-Write-Host "`nRunning migration: 001_create_users_table.sql" -ForegroundColor Yellow
-
-try {
-    psql -h $DB_HOST -p $DB_PORT -U $DB_USER -d $DB_NAME -f "migrations/001_create_users_table.sql"
-    
-    if ($LASTEXITCODE -eq 0) {
-        Write-Host "`n✓ Migration completed successfully!" -ForegroundColor Green
-    } else {
-        Write-Host "`n✗ Migration failed!" -ForegroundColor Red
+# Run migrations in filename order
+$migrations = Get-ChildItem -Path "migrations" -Filter "*.sql" | Sort-Object Name
+
+foreach ($migration in $migrations) {
+    Write-Host "`nRunning migration: $($migration.Name)" -ForegroundColor Yellow
+
+    try {
+        psql -h $DB_HOST -p $DB_PORT -U $DB_USER -d $DB_NAME -v ON_ERROR_STOP=1 -f $migration.FullName
+
+        if ($LASTEXITCODE -eq 0) {
+            Write-Host "✓ $($migration.Name) completed successfully!" -ForegroundColor Green
+        } else {
+            Write-Host "`n✗ Migration $($migration.Name) failed!" -ForegroundColor Red
+            $env:PGPASSWORD = $null
+            exit 1
+        }
+    } catch {
+        Write-Host "`n✗ Error running migration: $_" -ForegroundColor Red
+        Write-Host "`nMake sure PostgreSQL client (psql) is installed and in your PATH" -ForegroundColor Yellow
+        $env:PGPASSWORD = $null
         exit 1
     }
-} catch {
-    Write-Host "`n✗ Error running migration: $_" -ForegroundColor Red
-    Write-Host "`nMake sure PostgreSQL client (psql) is installed and in your PATH" -ForegroundColor Yellow
-    exit 1
 }
 
 # Clear password from environment
@@ -5,10 +5,15 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/auth/signing"
 	"TetriON.WebServer/server/internal/logging"
+	"TetriON.WebServer/server/internal/worker"
 )
 
 var (
@@ -131,4 +136,157 @@ func registerDefaultCommands() {
 	RegisterCommand("status", []string{}, "Show the current server status", "status", func(arguments ...string) {
 		logging.White.Println("Server is currently running.")
 	})
+
+	RegisterCommand("purge", []string{"purge-accounts"}, "Purge accounts whose deletion grace period has elapsed, or one account immediately", "purge [user_id]", func(arguments ...string) {
+		if len(arguments) == 0 {
+			worker.RunAccountPurge()
+			logging.White.Println("Account purge finished.")
+			return
+		}
+
+		if err := auth.PurgeAccount(arguments[0]); err != nil {
+			logging.White.Printf("Failed to purge account %s: %v\n", arguments[0], err)
+			return
+		}
+		logging.White.Printf("Account %s purged.\n", arguments[0])
+	})
+
+	RegisterCommand("role", []string{"set-role"}, "Set the role of a user (player, moderator or admin)", "role <user_id> <player|moderator|admin>", func(arguments ...string) {
+		if len(arguments) < 2 {
+			logging.White.Println("Usage: role <user_id> <player|moderator|admin>")
+			return
+		}
+
+		user, err := auth.SetUserRole(arguments[0], arguments[1], "")
+		if err != nil {
+			logging.White.Printf("Failed to set role: %v\n", err)
+			return
+		}
+		logging.White.Printf("User %s is now %s.\n", user.Username, user.Role)
+	})
+
+	RegisterCommand("ban", []string{}, "Ban a user from logging in, chat or ranked play", "ban <user_id> <login|chat|ranked> <hours|permanent> <reason...>", func(arguments ...string) {
+		if len(arguments) < 4 {
+			logging.White.Println("Usage: ban <user_id> <login|chat|ranked> <hours|permanent> <reason...>")
+			return
+		}
+
+		var duration time.Duration
+		if arguments[2] != "permanent" {
+			hours, err := strconv.Atoi(arguments[2])
+			if err != nil || hours <= 0 {
+				logging.White.Println("Duration must be a positive number of hours or \"permanent\".")
+				return
+			}
+			duration = time.Duration(hours) * time.Hour
+		}
+
+		ban, err := auth.IssueBan(arguments[0], arguments[1], strings.Join(arguments[3:], " "), "", duration)
+		if err != nil {
+			logging.White.Printf("Failed to ban user: %v\n", err)
+			return
+		}
+		logging.White.Printf("Ban %s issued (%s).\n", ban.ID, ban.Scope)
+	})
+
+	RegisterCommand("unban", []string{"lift-ban"}, "Lift a ban early", "unban <ban_id>", func(arguments ...string) {
+		if len(arguments) < 1 {
+			logging.White.Println("Usage: unban <ban_id>")
+			return
+		}
+
+		ban, err := auth.LiftBan(arguments[0], "", "lifted from console")
+		if err != nil {
+			logging.White.Printf("Failed to lift ban: %v\n", err)
+			return
+		}
+		logging.White.Printf("Ban %s of user %s lifted.\n", ban.ID, ban.UserID)
+	})
+
+	RegisterCommand("service", []string{"service-account"}, "Manage service accounts and their API keys", "service <list|create <name> <scope,...>|key <account_id>|revoke <account_id> <key_id>|disable <account_id>>", func(arguments ...string) {
+		if len(arguments) == 0 {
+			logging.White.Println("Usage: service <list|create|key|revoke|disable> ...")
+			return
+		}
+
+		switch arguments[0] {
+		case "list":
+			accounts, err := auth.ListServiceAccounts()
+			if err != nil {
+				logging.White.Printf("Failed to list service accounts: %v\n", err)
+				return
+			}
+			if len(accounts) == 0 {
+				logging.White.Println("No service accounts.")
+				return
+			}
+			for _, account := range accounts {
+				status := "active"
+				if account.DisabledAt != nil {
+					status = "disabled"
+				}
+				logging.White.Printf("%s  %-24s %-8s scopes=%s\n", account.ID, account.Name, status, strings.Join(account.Scopes, ","))
+				for _, key := range account.Keys {
+					keyStatus := "active"
+					if key.RevokedAt != nil {
+						keyStatus = "revoked"
+					}
+					logging.White.Printf("    key %s  %s...  %s\n", key.ID, key.Prefix, keyStatus)
+				}
+			}
+		case "create":
+			if len(arguments) < 3 {
+				logging.White.Println("Usage: service create <name> <scope,...>")
+				return
+			}
+			account, key, err := auth.CreateServiceAccount(arguments[1], "", strings.Split(arguments[2], ","))
+			if err != nil {
+				logging.White.Printf("Failed to create service account: %v\n", err)
+				return
+			}
+			logging.White.Printf("Service account %s created (ID: %s).\n", account.Name, account.ID)
+			logging.White.Printf("API key (shown once): %s\n", key)
+		case "key":
+			if len(arguments) < 2 {
+				logging.White.Println("Usage: service key <account_id>")
+				return
+			}
+			raw, _, err := auth.CreateAPIKey(arguments[1])
+			if err != nil {
+				logging.White.Printf("Failed to issue API key: %v\n", err)
+				return
+			}
+			logging.White.Printf("API key (shown once): %s\n", raw)
+		case "revoke":
+			if len(arguments) < 3 {
+				logging.White.Println("Usage: service revoke <account_id> <key_id>")
+				return
+			}
+			if err := auth.RevokeAPIKey(arguments[1], arguments[2]); err != nil {
+				logging.White.Printf("Failed to revoke API key: %v\n", err)
+				return
+			}
+			logging.White.Printf("API key %s revoked.\n", arguments[2])
+		case "disable":
+			if len(arguments) < 2 {
+				logging.White.Println("Usage: service disable <account_id>")
+				return
+			}
+			if err := auth.DisableServiceAccount(arguments[1]); err != nil {
+				logging.White.Printf("Failed to disable service account: %v\n", err)
+				return
+			}
+			logging.White.Printf("Service account %s disabled.\n", arguments[1])
+		default:
+			logging.White.Printf("Unknown subcommand: %s\n", arguments[0])
+		}
+	})
+
+	RegisterCommand("rotate-keys", []string{"rotate"}, "Activate a new JWT signing key immediately; previous keys keep verifying during the overlap", "rotate-keys", func(arguments ...string) {
+		if _, err := signing.Rotate(true); err != nil {
+			logging.White.Printf("Failed to rotate signing keys: %v\n", err)
+			return
+		}
+		logging.White.Println("JWT signing key rotated.")
+	})
 }
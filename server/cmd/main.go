@@ -2,12 +2,17 @@ package main
 
 import (
 	"context"
+	"time"
 
+	"TetriON.WebServer/server/internal/auth/oidc"
+	"TetriON.WebServer/server/internal/auth/signing"
 	"TetriON.WebServer/server/internal/config"
 	"TetriON.WebServer/server/internal/db"
 	"TetriON.WebServer/server/internal/logging"
+	"TetriON.WebServer/server/internal/mail"
 	"TetriON.WebServer/server/internal/net/redis"
 	"TetriON.WebServer/server/internal/net/websocket"
+	"TetriON.WebServer/server/internal/storage"
 	"TetriON.WebServer/server/internal/worker"
 )
 
@@ -32,6 +37,10 @@ func main() {
 
 	redis.Init()
 	db.Init()
+	signing.Init()
+	mail.Init()
+	storage.Init()
+	oidc.Init()
 	websocket.Init()
 
 	logging.LogWithTime(logging.Green, "INFO", "✅ All systems initialized successfully!")
@@ -44,6 +53,21 @@ func main() {
 	keyspaceSub := worker.NewKeyspaceSubscriber()
 	keyspaceSub.Start(rootCtx)
 
+	accountPurger := worker.NewAccountPurger(time.Hour)
+	accountPurger.Start(rootCtx)
+
+	keyRotator := worker.NewKeyRotator(time.Hour)
+	keyRotator.Start(rootCtx)
+
+	auditWriter := worker.NewAuditWriter()
+	auditWriter.Start(rootCtx)
+
+	auditPruner := worker.NewAuditPruner(6 * time.Hour)
+	auditPruner.Start(rootCtx)
+
+	activityFlusher := worker.NewSessionActivityFlusher(30 * time.Second)
+	activityFlusher.Start(rootCtx)
+
 	if err := redis.PublishMessage(context.Background(), "REDIS ON!"); err != nil {
 		logging.LogWarning("Unable to publish startup message to Redis: %v", err)
 	}
@@ -52,7 +76,12 @@ func main() {
 
 	cancel()
 	keyspaceSub.Stop()
+	accountPurger.Stop()
+	keyRotator.Stop()
+	auditPruner.Stop()
 	websocket.Stop()
+	activityFlusher.Stop()
+	auditWriter.Stop()
 	db.Close()
 	redis.Close()
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"runtime"
+	"strings"
 	"time"
 
 	"TetriON.WebServer/server/internal/config"
@@ -32,12 +33,49 @@ func StatsHandler(w http.ResponseWriter, _ *http.Request) {
 	writeJSON(w, payload, http.StatusOK)
 }
 
+// ConfigHandler returns the loaded config.json with secret values redacted.
 func ConfigHandler(w http.ResponseWriter, _ *http.Request) {
 	writeJSON(w, map[string]any{
-		"config": config.GetAllConfig(),
+		"config": redactConfig(config.GetAllConfig()),
 	}, http.StatusOK)
 }
 
+// sensitiveConfigKeys are key fragments whose values are never exposed.
+var sensitiveConfigKeys = []string{"secret", "password", "private", "credential", "api_key", "token"}
+
+func redactConfig(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			if isSensitiveConfigKey(key) {
+				out[key] = "[REDACTED]"
+				continue
+			}
+			out[key] = redactConfig(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = redactConfig(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func isSensitiveConfigKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, fragment := range sensitiveConfigKeys {
+		if strings.Contains(key, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
 func writeJSON(w http.ResponseWriter, v any, status int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
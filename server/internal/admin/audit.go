@@ -0,0 +1,69 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/logging"
+)
+
+// AuditEventsHandler pages through the audit log, newest first
+// (GET /api/admin/audit?user_id=&type=login,login_failed&before=&limit=)
+func AuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := auth.AuditFilter{UserID: query.Get("user_id")}
+	for _, value := range query["type"] {
+		for _, eventType := range strings.Split(value, ",") {
+			if eventType = strings.TrimSpace(eventType); eventType != "" {
+				filter.Types = append(filter.Types, eventType)
+			}
+		}
+	}
+	if raw := query.Get("before"); raw != "" {
+		before, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || before <= 0 {
+			writeError(w, "before must be an event ID", http.StatusBadRequest)
+			return
+		}
+		filter.Before = before
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > 200 {
+			writeError(w, "limit must be between 1 and 200", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+
+	events, err := auth.ListAuditEvents(filter)
+	if err != nil {
+		if err == auth.ErrInvalidAuditFilter {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logging.LogError("Failed to list audit events: %v", err)
+		writeError(w, "Failed to list audit events", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]any{
+		"success": true,
+		"events":  events,
+	}
+	// A full page means there may be more; the client passes next_before as before
+	if filter.Limit == 0 {
+		filter.Limit = 50
+	}
+	if len(events) == filter.Limit {
+		response["next_before"] = events[len(events)-1].ID
+	}
+	writeJSON(w, response, http.StatusOK)
+}
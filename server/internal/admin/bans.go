@@ -0,0 +1,107 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/logging"
+)
+
+type issueBanRequest struct {
+	Scope         string `json:"scope"`
+	Reason        string `json:"reason"`
+	DurationHours int    `json:"duration_hours"` // 0 or omitted for a permanent ban
+}
+
+type liftBanRequest struct {
+	Reason string `json:"reason"`
+}
+
+// UserBansHandler lists (GET) or issues (POST) bans of a user (/api/admin/users/{id}/bans)
+func UserBansHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		bans, err := auth.ListBans(id)
+		if err != nil {
+			respondBanError(w, err)
+			return
+		}
+		writeJSON(w, map[string]any{
+			"success": true,
+			"bans":    bans,
+		}, http.StatusOK)
+
+	case http.MethodPost:
+		var req issueBanRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.DurationHours < 0 {
+			writeError(w, "duration_hours must not be negative", http.StatusBadRequest)
+			return
+		}
+
+		ban, err := auth.IssueBan(id, req.Scope, req.Reason, callerID(r), time.Duration(req.DurationHours)*time.Hour)
+		if err != nil {
+			respondBanError(w, err)
+			return
+		}
+
+		logging.LogInfo("User %s banned (%s) by %s: %s", id, ban.Scope, callerName(r), ban.Reason)
+		writeJSON(w, map[string]any{
+			"success": true,
+			"ban":     ban,
+		}, http.StatusCreated)
+
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// BanHandler lifts a ban early (DELETE /api/admin/bans/{id}); the body may carry a reason
+func BanHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req liftBanRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	id := r.PathValue("id")
+	ban, err := auth.LiftBan(id, callerID(r), req.Reason)
+	if err != nil {
+		respondBanError(w, err)
+		return
+	}
+
+	logging.LogInfo("Ban %s of user %s lifted by %s", ban.ID, ban.UserID, callerName(r))
+	writeJSON(w, map[string]any{
+		"success": true,
+		"ban":     ban,
+	}, http.StatusOK)
+}
+
+func respondBanError(w http.ResponseWriter, err error) {
+	switch err {
+	case auth.ErrInvalidBanScope, auth.ErrBanReason:
+		writeError(w, err.Error(), http.StatusBadRequest)
+	case auth.ErrUserNotFound, auth.ErrBanNotFound:
+		writeError(w, err.Error(), http.StatusNotFound)
+	case auth.ErrBanNotPermitted:
+		writeError(w, err.Error(), http.StatusForbidden)
+	default:
+		logging.LogError("Ban operation failed: %v", err)
+		writeError(w, "Ban operation failed", http.StatusInternalServerError)
+	}
+}
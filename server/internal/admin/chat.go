@@ -0,0 +1,202 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/domain/chat"
+	"TetriON.WebServer/server/internal/logging"
+)
+
+type chatMuteRequest struct {
+	UserID          string `json:"user_id"`
+	DurationMinutes int    `json:"duration_minutes"` // 0 or omitted mutes until lifted
+	Reason          string `json:"reason"`
+}
+
+type chatKickRequest struct {
+	UserID string `json:"user_id"`
+	Reason string `json:"reason"`
+}
+
+type chatSlowModeRequest struct {
+	Seconds int `json:"seconds"` // 0 turns slow mode off
+}
+
+type chatDeleteRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ChatMessagesHandler returns the retained history of a chat channel
+// (GET /api/admin/chat/channels/{channel}/messages)
+func ChatMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	channel := r.PathValue("channel")
+	if _, _, err := chat.ParseChannel(channel); err != nil {
+		respondChatError(w, err)
+		return
+	}
+	history, err := chat.History(r.Context(), channel)
+	if err != nil {
+		respondChatError(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"success":  true,
+		"messages": history,
+	}, http.StatusOK)
+}
+
+// ChatMessageHandler deletes a chat message for every member of the channel
+// (DELETE /api/admin/chat/channels/{channel}/messages/{id}); the body may carry a reason
+func ChatMessageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	messageID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		respondChatError(w, chat.ErrMessageNotFound)
+		return
+	}
+	var req chatDeleteRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	channel := r.PathValue("channel")
+	if err := chat.Delete(r.Context(), callerID(r), channel, messageID, req.Reason); err != nil {
+		respondChatError(w, err)
+		return
+	}
+
+	logging.LogInfo("Chat message %d in %s deleted by %s", messageID, channel, callerName(r))
+	writeJSON(w, map[string]any{
+		"success": true,
+	}, http.StatusOK)
+}
+
+// ChatMutesHandler mutes a player in a chat channel (POST /api/admin/chat/channels/{channel}/mutes)
+func ChatMutesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req chatMuteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == "" {
+		writeError(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
+
+	channel := r.PathValue("channel")
+	duration := time.Duration(req.DurationMinutes) * time.Minute
+	if err := chat.Mute(r.Context(), callerID(r), channel, req.UserID, duration, req.Reason); err != nil {
+		respondChatError(w, err)
+		return
+	}
+
+	logging.LogInfo("User %s muted in %s by %s: %s", req.UserID, channel, callerName(r), req.Reason)
+	writeJSON(w, map[string]any{
+		"success": true,
+	}, http.StatusOK)
+}
+
+// ChatMuteHandler lifts a mute early (DELETE /api/admin/chat/channels/{channel}/mutes/{user_id})
+func ChatMuteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	channel := r.PathValue("channel")
+	userID := r.PathValue("user_id")
+	if err := chat.Unmute(r.Context(), callerID(r), channel, userID); err != nil {
+		respondChatError(w, err)
+		return
+	}
+
+	logging.LogInfo("User %s unmuted in %s by %s", userID, channel, callerName(r))
+	writeJSON(w, map[string]any{
+		"success": true,
+	}, http.StatusOK)
+}
+
+// ChatKicksHandler removes a player from a chat channel
+// (POST /api/admin/chat/channels/{channel}/kicks)
+func ChatKicksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req chatKickRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == "" {
+		writeError(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
+
+	channel := r.PathValue("channel")
+	if err := chat.Kick(r.Context(), callerID(r), channel, req.UserID, req.Reason); err != nil {
+		respondChatError(w, err)
+		return
+	}
+
+	logging.LogInfo("User %s kicked from %s by %s: %s", req.UserID, channel, callerName(r), req.Reason)
+	writeJSON(w, map[string]any{
+		"success": true,
+	}, http.StatusOK)
+}
+
+// ChatSlowModeHandler sets the slow mode of a chat channel
+// (PUT /api/admin/chat/channels/{channel}/slow-mode)
+func ChatSlowModeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req chatSlowModeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	channel := r.PathValue("channel")
+	if err := chat.SetSlowMode(r.Context(), callerID(r), channel, time.Duration(req.Seconds)*time.Second); err != nil {
+		respondChatError(w, err)
+		return
+	}
+
+	logging.LogInfo("Slow mode of %s set to %ds by %s", channel, req.Seconds, callerName(r))
+	writeJSON(w, map[string]any{
+		"success": true,
+		"seconds": req.Seconds,
+	}, http.StatusOK)
+}
+
+func respondChatError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, chat.ErrInvalidChannel), errors.Is(err, chat.ErrInvalidDuration),
+		errors.Is(err, chat.ErrInvalidSlowMode), errors.Is(err, chat.ErrReasonTooLong):
+		writeError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, auth.ErrUserNotFound), errors.Is(err, chat.ErrMessageNotFound), errors.Is(err, chat.ErrNotMuted):
+		writeError(w, err.Error(), http.StatusNotFound)
+	default:
+		logging.LogError("Chat moderation failed: %v", err)
+		writeError(w, "Chat moderation failed", http.StatusInternalServerError)
+	}
+}
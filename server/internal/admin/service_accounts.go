@@ -0,0 +1,162 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/logging"
+	"TetriON.WebServer/server/internal/middleware"
+)
+
+type createServiceAccountRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Scopes      []string `json:"scopes"`
+}
+
+// ServiceAccountsHandler lists (GET) or creates (POST) service accounts
+func ServiceAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		accounts, err := auth.ListServiceAccounts()
+		if err != nil {
+			logging.LogError("Failed to list service accounts: %v", err)
+			writeError(w, "Failed to list service accounts", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]any{
+			"success":          true,
+			"service_accounts": accounts,
+		}, http.StatusOK)
+
+	case http.MethodPost:
+		var req createServiceAccountRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		account, key, err := auth.CreateServiceAccount(req.Name, req.Description, req.Scopes)
+		if err != nil {
+			respondServiceAccountError(w, err)
+			return
+		}
+
+		logging.LogInfo("Service account %s (%s) created by %s", account.Name, account.ID, callerName(r))
+		writeJSON(w, map[string]any{
+			"success":         true,
+			"service_account": account,
+			"api_key":         key,
+		}, http.StatusCreated)
+
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ServiceAccountHandler disables a service account and revokes its keys (DELETE /api/admin/service-accounts/{id})
+func ServiceAccountHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+	if err := auth.DisableServiceAccount(id); err != nil {
+		respondServiceAccountError(w, err)
+		return
+	}
+
+	logging.LogInfo("Service account %s disabled by %s", id, callerName(r))
+	writeJSON(w, map[string]any{
+		"success": true,
+		"message": "Service account disabled",
+	}, http.StatusOK)
+}
+
+// ServiceAccountKeysHandler issues a new API key for a service account (POST /api/admin/service-accounts/{id}/keys)
+func ServiceAccountKeysHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+	raw, key, err := auth.CreateAPIKey(id)
+	if err != nil {
+		respondServiceAccountError(w, err)
+		return
+	}
+
+	logging.LogInfo("API key %s issued for service account %s by %s", key.Prefix, id, callerName(r))
+	writeJSON(w, map[string]any{
+		"success": true,
+		"key":     key,
+		"api_key": raw,
+	}, http.StatusCreated)
+}
+
+// ServiceAccountKeyHandler revokes one API key (DELETE /api/admin/service-accounts/{id}/keys/{key_id})
+func ServiceAccountKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, keyID := r.PathValue("id"), r.PathValue("key_id")
+	if err := auth.RevokeAPIKey(id, keyID); err != nil {
+		respondServiceAccountError(w, err)
+		return
+	}
+
+	logging.LogInfo("API key %s of service account %s revoked by %s", keyID, id, callerName(r))
+	writeJSON(w, map[string]any{
+		"success": true,
+		"message": "API key revoked",
+	}, http.StatusOK)
+}
+
+func respondServiceAccountError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidScope), err == auth.ErrInvalidServiceName:
+		writeError(w, err.Error(), http.StatusBadRequest)
+	case err == auth.ErrServiceAccountExists:
+		writeError(w, err.Error(), http.StatusConflict)
+	case err == auth.ErrServiceAccountNotFound, err == auth.ErrAPIKeyNotFound:
+		writeError(w, err.Error(), http.StatusNotFound)
+	default:
+		logging.LogError("Service account operation failed: %v", err)
+		writeError(w, "Service account operation failed", http.StatusInternalServerError)
+	}
+}
+
+// callerName describes the authenticated principal for log lines
+func callerName(r *http.Request) string {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	switch {
+	case !ok:
+		return "unknown"
+	case principal.Service != nil:
+		return "service:" + principal.Service.Name
+	case principal.User != nil:
+		return "user:" + principal.User.Username
+	}
+	return "unknown"
+}
+
+// callerID returns the user ID of the authenticated caller, or "" for services
+func callerID(r *http.Request) string {
+	if user, ok := middleware.UserFromContext(r.Context()); ok {
+		return user.UserID
+	}
+	return ""
+}
+
+func writeError(w http.ResponseWriter, message string, status int) {
+	writeJSON(w, map[string]any{
+		"success": false,
+		"error":   message,
+	}, status)
+}
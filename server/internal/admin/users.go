@@ -0,0 +1,49 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/logging"
+)
+
+type setRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// UserRoleHandler changes the role of a user (PUT /api/admin/users/{id}/role)
+func UserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req setRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id := r.PathValue("id")
+	user, err := auth.SetUserRole(id, req.Role, callerID(r))
+	if err != nil {
+		switch err {
+		case auth.ErrInvalidRole:
+			writeError(w, err.Error(), http.StatusBadRequest)
+		case auth.ErrUserNotFound:
+			writeError(w, err.Error(), http.StatusNotFound)
+		default:
+			logging.LogError("Failed to set role of user %s: %v", id, err)
+			writeError(w, "Failed to update role", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	logging.LogInfo("Role of user %s set to %s by %s", user.Username, user.Role, callerName(r))
+	user.PasswordHash = ""
+	writeJSON(w, map[string]any{
+		"success": true,
+		"user":    user,
+	}, http.StatusOK)
+}
@@ -5,6 +5,11 @@ import (
 
 	"TetriON.WebServer/server/internal/admin"
 	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/domain/friends"
+	"TetriON.WebServer/server/internal/domain/gameserver"
+	"TetriON.WebServer/server/internal/domain/matchmaking"
+	"TetriON.WebServer/server/internal/domain/messages"
+	"TetriON.WebServer/server/internal/domain/profile"
 	"TetriON.WebServer/server/internal/logging"
 	"TetriON.WebServer/server/internal/metrics"
 	"TetriON.WebServer/server/internal/middleware"
@@ -16,24 +21,123 @@ func SetupRoutes(mux *http.ServeMux) {
 	chain := func(h http.Handler) http.Handler {
 		return middleware.RateLimitMiddleware(metrics.HTTPMetricsMiddleware(h))
 	}
+	adminOnly := func(h http.HandlerFunc) http.Handler {
+		return chain(middleware.RequireAuth(middleware.RequireRole(auth.RoleAdmin)(h)))
+	}
+	sensitiveAdmin := func(h http.HandlerFunc) http.Handler {
+		return chain(middleware.RequireAuth(middleware.RequireVerifiedRole(auth.RoleAdmin)(h)))
+	}
+	moderation := func(h http.HandlerFunc) http.Handler {
+		return chain(middleware.RequireAuth(middleware.RequireVerifiedRole(auth.RoleModerator, auth.RoleAdmin)(h)))
+	}
+	serviceAdmin := func(h http.HandlerFunc) http.Handler {
+		return chain(middleware.Authenticate(middleware.RequireVerifiedRoleOrScope(auth.ScopeServiceAccountsAdmin, auth.RoleAdmin)(h)))
+	}
+	// gameServer admits game server nodes holding the scope, and admins
+	gameServer := func(scope string, h http.HandlerFunc) http.Handler {
+		return chain(middleware.Authenticate(middleware.RequireVerifiedRoleOrScope(scope, auth.RoleAdmin)(h)))
+	}
+
+	middleware.SetServiceKeyAuthenticator(auth.APIKeyPrefix, authenticateServiceKey)
+	middleware.SetRoleVerifier(auth.GetUserRole)
+	auth.OnAccountPurged(profile.DeleteAllAvatarFiles)
 
 	// Authentication routes
 	mux.Handle("/api/auth/register", chain(http.HandlerFunc(auth.RegisterHandler)))
+	mux.Handle("/api/auth/guest", chain(http.HandlerFunc(auth.GuestHandler)))
+	mux.Handle("/api/auth/guest/upgrade", chain(middleware.RequireAuth(http.HandlerFunc(auth.GuestUpgradeHandler))))
 	mux.Handle("/api/auth/login", chain(http.HandlerFunc(auth.LoginHandler)))
+	mux.Handle("/api/auth/login/mfa", chain(http.HandlerFunc(auth.MFALoginHandler)))
+	mux.Handle("/api/auth/refresh", chain(http.HandlerFunc(auth.RefreshHandler)))
+	mux.Handle("/api/auth/logout", chain(middleware.RequireAuth(http.HandlerFunc(auth.LogoutHandler))))
+	mux.Handle("/api/auth/logout/all", chain(middleware.RequireAuth(http.HandlerFunc(auth.LogoutAllHandler))))
+	mux.Handle("/api/auth/verify", chain(http.HandlerFunc(auth.VerifyEmailHandler)))
+	mux.Handle("/api/auth/verify/resend", chain(middleware.RequireAuth(http.HandlerFunc(auth.ResendVerificationHandler))))
+	mux.Handle("/api/auth/password/forgot", chain(http.HandlerFunc(auth.ForgotPasswordHandler)))
+	mux.Handle("/api/auth/password/reset", chain(http.HandlerFunc(auth.ResetPasswordHandler)))
+	mux.Handle("/api/auth/password/change", chain(middleware.RequireAuth(http.HandlerFunc(auth.ChangePasswordHandler))))
+	mux.Handle("/api/auth/account", chain(middleware.RequireAuth(http.HandlerFunc(auth.DeleteAccountHandler))))
+	mux.Handle("/api/auth/mfa/totp/setup", chain(middleware.RequireAuth(http.HandlerFunc(auth.TOTPSetupHandler))))
+	mux.Handle("/api/auth/mfa/totp/confirm", chain(middleware.RequireAuth(http.HandlerFunc(auth.TOTPConfirmHandler))))
+	mux.Handle("/api/auth/mfa/totp/disable", chain(middleware.RequireAuth(http.HandlerFunc(auth.TOTPDisableHandler))))
+	mux.Handle("/api/auth/oidc/providers", chain(http.HandlerFunc(auth.OIDCProvidersHandler)))
+	mux.Handle("/api/auth/oidc/{provider}/start", chain(http.HandlerFunc(auth.OIDCStartHandler)))
+	mux.Handle("/api/auth/oidc/{provider}/callback", chain(http.HandlerFunc(auth.OIDCCallbackHandler)))
+	mux.Handle("/api/auth/oidc/{provider}/link", chain(middleware.RequireAuth(http.HandlerFunc(auth.OIDCLinkHandler))))
+	mux.Handle("/api/auth/oidc/{provider}", chain(middleware.RequireAuth(http.HandlerFunc(auth.OIDCUnlinkHandler))))
+	mux.Handle("/api/auth/identities", chain(middleware.RequireAuth(http.HandlerFunc(auth.IdentitiesHandler))))
+	mux.Handle("/api/auth/sessions", chain(middleware.RequireAuth(http.HandlerFunc(auth.SessionsHandler))))
+	mux.Handle("/api/auth/sessions/{id}", chain(middleware.RequireAuth(http.HandlerFunc(auth.SessionHandler))))
 	mux.Handle("/api/auth/profile", chain(middleware.RequireAuth(http.HandlerFunc(auth.ProfileHandler))))
 
+	// Player profile routes
+	mux.Handle("/api/users/me", chain(middleware.RequireAuth(http.HandlerFunc(profile.MeHandler))))
+	mux.Handle("/api/users/me/avatar", chain(middleware.RequireAuth(http.HandlerFunc(profile.AvatarHandler))))
+	mux.Handle("/api/users/{username}", chain(http.HandlerFunc(profile.ProfileHandler)))
+	mux.Handle("/api/avatars/{user_id}/{hash}/{file}", chain(http.HandlerFunc(profile.AvatarFileHandler)))
+
+	// Friends and blocks
+	mux.Handle("/api/friends", chain(middleware.RequireAuth(http.HandlerFunc(friends.FriendsHandler))))
+	mux.Handle("/api/friends/requests", chain(middleware.RequireAuth(http.HandlerFunc(friends.FriendRequestsHandler))))
+	mux.Handle("/api/friends/requests/{user_id}/{action}", chain(middleware.RequireAuth(http.HandlerFunc(friends.FriendRequestActionHandler))))
+	mux.Handle("/api/friends/{user_id}", chain(middleware.RequireAuth(http.HandlerFunc(friends.FriendHandler))))
+	mux.Handle("/api/blocks", chain(middleware.RequireAuth(http.HandlerFunc(friends.BlocksHandler))))
+	mux.Handle("/api/blocks/{user_id}", chain(middleware.RequireAuth(http.HandlerFunc(friends.BlockHandler))))
+	mux.Handle("/api/presence", chain(middleware.RequireAuth(http.HandlerFunc(friends.PresenceHandler))))
+
+	// Direct messages
+	mux.Handle("/api/messages/{user_id}", chain(middleware.RequireAuth(http.HandlerFunc(messages.ConversationHandler))))
+	mux.Handle("/api/messages/{user_id}/read", chain(middleware.RequireAuth(http.HandlerFunc(messages.ReadHandler))))
+
+	// Game server routes (service API keys)
+	mux.Handle("/api/gameservers", gameServer(auth.ScopeRegistryWrite, gameserver.NodesHandler))
+	mux.Handle("/api/gameservers/{id}", gameServer(auth.ScopeRegistryWrite, gameserver.NodeHandler))
+	mux.Handle("/api/matches/{id}/end", gameServer(auth.ScopeMatchesReport, matchmaking.MatchEndHandler))
+	mux.Handle("/api/matches/{id}/spectators/{user_id}", gameServer(auth.ScopeMatchesReport, matchmaking.MatchSpectatorHandler))
+
+	// Public token signing keys, used by game servers to verify access tokens offline
+	mux.Handle("/.well-known/jwks.json", chain(http.HandlerFunc(auth.JWKSHandler)))
+
 	// Health check
 	mux.Handle("/api/health", chain(http.HandlerFunc(HealthCheckHandler)))
 
 	// Metrics and admin routes
 	mux.Handle("/api/metrics", chain(http.HandlerFunc(metrics.Handler)))
-	mux.Handle("/api/admin/health", chain(http.HandlerFunc(admin.HealthHandler)))
-	mux.Handle("/api/admin/stats", chain(http.HandlerFunc(admin.StatsHandler)))
-	mux.Handle("/api/admin/config", chain(http.HandlerFunc(admin.ConfigHandler)))
+	mux.Handle("/api/admin/health", adminOnly(admin.HealthHandler))
+	mux.Handle("/api/admin/stats", adminOnly(admin.StatsHandler))
+	mux.Handle("/api/admin/config", sensitiveAdmin(admin.ConfigHandler))
+	mux.Handle("/api/admin/audit", sensitiveAdmin(admin.AuditEventsHandler))
+	mux.Handle("/api/admin/users/{id}/role", sensitiveAdmin(admin.UserRoleHandler))
+	mux.Handle("/api/admin/users/{id}/bans", moderation(admin.UserBansHandler))
+	mux.Handle("/api/admin/bans/{id}", moderation(admin.BanHandler))
+	mux.Handle("/api/admin/chat/channels/{channel}/messages", moderation(admin.ChatMessagesHandler))
+	mux.Handle("/api/admin/chat/channels/{channel}/messages/{id}", moderation(admin.ChatMessageHandler))
+	mux.Handle("/api/admin/chat/channels/{channel}/mutes", moderation(admin.ChatMutesHandler))
+	mux.Handle("/api/admin/chat/channels/{channel}/mutes/{user_id}", moderation(admin.ChatMuteHandler))
+	mux.Handle("/api/admin/chat/channels/{channel}/kicks", moderation(admin.ChatKicksHandler))
+	mux.Handle("/api/admin/chat/channels/{channel}/slow-mode", moderation(admin.ChatSlowModeHandler))
+	mux.Handle("/api/admin/service-accounts", serviceAdmin(admin.ServiceAccountsHandler))
+	mux.Handle("/api/admin/service-accounts/{id}", serviceAdmin(admin.ServiceAccountHandler))
+	mux.Handle("/api/admin/service-accounts/{id}/keys", serviceAdmin(admin.ServiceAccountKeysHandler))
+	mux.Handle("/api/admin/service-accounts/{id}/keys/{key_id}", serviceAdmin(admin.ServiceAccountKeyHandler))
 
 	logging.LogInfo("API routes registered successfully")
 }
 
+// authenticateServiceKey adapts auth API key verification to the middleware authenticator
+func authenticateServiceKey(key string) (*middleware.ServicePrincipal, error) {
+	principal, err := auth.AuthenticateAPIKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &middleware.ServicePrincipal{
+		AccountID: principal.AccountID,
+		Name:      principal.Name,
+		KeyID:     principal.KeyID,
+		Scopes:    principal.Scopes,
+	}, nil
+}
+
 // HealthCheckHandler returns server health status
 func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,282 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"TetriON.WebServer/server/internal/config"
+	"TetriON.WebServer/server/internal/db"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+	ErrSamePassword      = errors.New("new password must differ from the current password")
+	ErrRecentLoginNeeded = errors.New("log in again to confirm this change")
+)
+
+// recentLoginWindow is how long after logging in an account without a password may confirm
+// sensitive changes with its session instead
+const recentLoginWindow = 10 * time.Minute
+
+// accountOwnedData lists statements that remove data owned by an account when it is purged.
+// Each statement receives the user ID as $1.
+var accountOwnedData = []string{
+	`DELETE FROM refresh_tokens WHERE user_id = $1`,
+	`DELETE FROM user_sessions WHERE user_id = $1`,
+	`DELETE FROM email_verification_tokens WHERE user_id = $1`,
+	`DELETE FROM password_reset_tokens WHERE user_id = $1`,
+	`DELETE FROM mfa_recovery_codes WHERE user_id = $1`,
+	`DELETE FROM user_identities WHERE user_id = $1`,
+	`DELETE FROM profiles WHERE user_id = $1`,
+	`DELETE FROM friendships WHERE user_id = $1 OR friend_id = $1`,
+	`DELETE FROM direct_messages WHERE sender_id = $1 OR recipient_id = $1`,
+}
+
+var (
+	accountHooksMu     sync.RWMutex
+	accountPurgedHooks []func(userID string)
+)
+
+// OnAccountPurged registers a callback invoked after an account has been purged, for data
+// kept outside the database such as uploaded files
+func OnAccountPurged(fn func(userID string)) {
+	accountHooksMu.Lock()
+	defer accountHooksMu.Unlock()
+	accountPurgedHooks = append(accountPurgedHooks, fn)
+}
+
+// ChangePassword replaces the password of an authenticated user and revokes all of their sessions.
+// Accounts without a password, such as social sign-ups, set their first one from a session that
+// logged in recently; guests set theirs by upgrading.
+func ChangePassword(userID, sessionID, currentPassword, newPassword string) error {
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.IsGuest {
+		return ErrGuestAccount
+	}
+
+	if err := reauthenticate(user, sessionID, currentPassword); err != nil {
+		return err
+	}
+	if user.PasswordHash != "" && currentPassword == newPassword {
+		return ErrSamePassword
+	}
+	if err := validatePassword(newPassword, user.Username, user.Email); err != nil {
+		return err
+	}
+
+	hashedPassword, err := hashPassword(newPassword)
+	if err != nil {
+		return errors.New("failed to hash password")
+	}
+
+	if err := UpdatePasswordHash(user.ID, hashedPassword); err != nil {
+		return err
+	}
+
+	return RevokeAllSessions(user.ID)
+}
+
+// ScheduleAccountDeletion re-authenticates the user and schedules their account for purging
+// after the configured grace period. Every session is revoked; logging in again cancels the deletion.
+// Guests have nothing to re-authenticate with and may delete their account outright.
+func ScheduleAccountDeletion(userID, sessionID, password string) (time.Time, error) {
+	if db.DB == nil {
+		return time.Time{}, ErrDatabaseError
+	}
+
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !user.IsGuest {
+		if err := reauthenticate(user, sessionID, password); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	scheduledAt := time.Now().Add(accountDeletionGracePeriod())
+	if _, err := db.DB.Exec(context.Background(), `
+		UPDATE users
+		SET deletion_scheduled_at = $1, updated_at = $2
+		WHERE id = $3
+	`, scheduledAt, time.Now(), user.ID); err != nil {
+		return time.Time{}, err
+	}
+
+	if err := RevokeAllSessions(user.ID); err != nil {
+		return time.Time{}, err
+	}
+	return scheduledAt, nil
+}
+
+// reauthenticate confirms a sensitive change with the account's password, or for an account
+// without one with a session that logged in within recentLoginWindow
+func reauthenticate(user *User, sessionID, password string) error {
+	if user.PasswordHash != "" {
+		if err := verifyPassword(user.PasswordHash, password); err != nil {
+			return ErrIncorrectPassword
+		}
+		return nil
+	}
+
+	if db.DB == nil {
+		return ErrDatabaseError
+	}
+	if sessionID == "" {
+		return ErrRecentLoginNeeded
+	}
+
+	// Refreshing keeps the session, so its creation is the last time the user logged in
+	var createdAt time.Time
+	err := db.DB.QueryRow(context.Background(), `
+		SELECT created_at
+		FROM user_sessions
+		WHERE id = $1 AND user_id = $2
+	`, sessionID, user.ID).Scan(&createdAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrRecentLoginNeeded
+		}
+		return err
+	}
+	if time.Since(createdAt) > recentLoginWindow {
+		return ErrRecentLoginNeeded
+	}
+	return nil
+}
+
+// CancelAccountDeletion clears a pending deletion request
+func CancelAccountDeletion(userID string) error {
+	if db.DB == nil {
+		return ErrDatabaseError
+	}
+
+	_, err := db.DB.Exec(context.Background(), `
+		UPDATE users
+		SET deletion_scheduled_at = NULL, updated_at = $1
+		WHERE id = $2 AND deleted_at IS NULL
+	`, time.Now(), userID)
+	return err
+}
+
+// PurgeExpiredAccounts purges every account whose deletion grace period has elapsed,
+// and guest accounts that expired without being upgraded
+func PurgeExpiredAccounts() (int, error) {
+	if db.DB == nil {
+		return 0, ErrDatabaseError
+	}
+
+	rows, err := db.DB.Query(context.Background(), `
+		SELECT id
+		FROM users
+		WHERE deleted_at IS NULL AND (
+			(deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= $1)
+			OR (is_guest AND guest_expires_at <= $1)
+		)
+	`, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	var userIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		userIDs = append(userIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, id := range userIDs {
+		if err := PurgeAccount(id); err != nil {
+			return purged, fmt.Errorf("purge %s: %w", id, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// PurgeAccount anonymizes the users row and removes all data owned by the account.
+// The row itself is kept so references from shared data (e.g. match history) stay valid.
+func PurgeAccount(userID string) error {
+	if db.DB == nil {
+		return ErrDatabaseError
+	}
+
+	ctx := context.Background()
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE users
+		SET username = 'deleted_' || left(replace(id::text, '-', ''), 16),
+			email = 'deleted+' || id::text || '@deleted.invalid',
+			email_verified = FALSE,
+			email_verified_at = NULL,
+			password_hash = '',
+			totp_secret = NULL,
+			totp_enabled = FALSE,
+			totp_enabled_at = NULL,
+			deletion_scheduled_at = NULL,
+			is_guest = FALSE,
+			guest_expires_at = NULL,
+			deleted_at = $1,
+			updated_at = $1
+		WHERE id = $2 AND deleted_at IS NULL
+	`, time.Now(), userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	for _, statement := range accountOwnedData {
+		if _, err := tx.Exec(ctx, statement, userID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	notifyAccountPurged(userID)
+	return RevokeAllSessions(userID)
+}
+
+func notifyAccountPurged(userID string) {
+	accountHooksMu.RLock()
+	hooks := append([]func(string){}, accountPurgedHooks...)
+	accountHooksMu.RUnlock()
+
+	for _, fn := range hooks {
+		fn(userID)
+	}
+}
+
+// accountDeletionGracePeriod returns the configured grace period (account_deletion_grace_days, default 14)
+func accountDeletionGracePeriod() time.Duration {
+	days := 14
+	if d, err := strconv.Atoi(fmt.Sprint(config.GetConfig(config.CONFIG_ACCOUNT_DELETION_GRACE_DAYS))); err == nil && d >= 0 {
+		days = d
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
@@ -0,0 +1,285 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"TetriON.WebServer/server/internal/config"
+	"TetriON.WebServer/server/internal/db"
+	"TetriON.WebServer/server/internal/logging"
+	"TetriON.WebServer/server/internal/middleware"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Audit event types
+const (
+	AuditRegister           = "register"
+	AuditLogin              = "login"
+	AuditLoginFailed        = "login_failed"
+	AuditAccountLocked      = "account_locked"
+	AuditTokenRefreshed     = "token_refreshed"
+	AuditLogout             = "logout"
+	AuditLogoutAll          = "logout_all"
+	AuditSessionRevoked     = "session_revoked"
+	AuditPasswordChanged    = "password_changed"
+	AuditPasswordReset      = "password_reset"
+	AuditEmailVerified      = "email_verified"
+	AuditMFAEnabled         = "mfa_enabled"
+	AuditMFADisabled        = "mfa_disabled"
+	AuditIdentityLinked     = "identity_linked"
+	AuditIdentityUnlinked   = "identity_unlinked"
+	AuditGuestCreated       = "guest_created"
+	AuditGuestUpgraded      = "guest_upgraded"
+	AuditDeletionRequested  = "account_deletion_requested"
+	AuditRoleChanged        = "role_changed"
+	AuditUserBanned         = "user_banned"
+	AuditBanLifted          = "ban_lifted"
+	AuditChatMessageDeleted = "chat_message_deleted"
+	AuditChatMuted          = "chat_muted"
+	AuditChatUnmuted        = "chat_unmuted"
+	AuditChatKicked         = "chat_kicked"
+	AuditChatSlowMode       = "chat_slow_mode_changed"
+)
+
+const (
+	auditQueueSize            = 4096
+	auditBatchSize            = 256
+	auditFlushInterval        = time.Second
+	defaultAuditRetentionDays = 365
+)
+
+var ErrInvalidAuditFilter = errors.New("user_id must be a valid user ID")
+
+// AuditEvent describes a security relevant event on an account
+type AuditEvent struct {
+	ID        int64          `json:"id"`
+	Type      string         `json:"type"`
+	ActorID   string         `json:"actor_id,omitempty"`
+	SubjectID string         `json:"subject_id,omitempty"`
+	IP        string         `json:"ip,omitempty"`
+	UserAgent string         `json:"user_agent,omitempty"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// AuditFilter selects audit events. UserID matches either the actor or the subject;
+// Before is the ID of the last event of the previous page.
+type AuditFilter struct {
+	UserID string
+	Types  []string
+	Before int64
+	Limit  int
+}
+
+var auditQueue = make(chan AuditEvent, auditQueueSize)
+
+// recordAudit queues an audit event; RunAuditWriter stores it in the background
+func recordAudit(event AuditEvent) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	select {
+	case auditQueue <- event:
+	default:
+		metadata, _ := json.Marshal(event.Metadata)
+		logging.LogWarning("Audit queue full, dropped %s subject=%s actor=%s ip=%s metadata=%s",
+			event.Type, event.SubjectID, event.ActorID, event.IP, metadata)
+	}
+}
+
+// RecordAudit queues an audit event raised outside the auth package, such as a chat
+// moderation action
+func RecordAudit(event AuditEvent) {
+	recordAudit(event)
+}
+
+// auditRequest records an event with the client IP and User-Agent of the request
+func auditRequest(r *http.Request, event AuditEvent) {
+	event.IP = middleware.ClientIP(r)
+	event.UserAgent = r.UserAgent()
+	recordAudit(event)
+}
+
+// RunAuditWriter stores queued audit events in batches until ctx is cancelled,
+// then writes whatever is still queued
+func RunAuditWriter(ctx context.Context) {
+	ticker := time.NewTicker(auditFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]AuditEvent, 0, auditBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := insertAuditEvents(batch); err != nil {
+			logging.LogError("Failed to write %d audit event(s): %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case event := <-auditQueue:
+					batch = append(batch, event)
+					if len(batch) == auditBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		case event := <-auditQueue:
+			batch = append(batch, event)
+			if len(batch) == auditBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// ListAuditEvents returns audit events matching the filter, newest first
+func ListAuditEvents(filter AuditFilter) ([]AuditEvent, error) {
+	if db.DB == nil {
+		return nil, ErrDatabaseError
+	}
+
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+
+	query := `
+		SELECT id, event_type, actor_id, subject_id, ip, user_agent, metadata, created_at
+		FROM audit_events
+		WHERE TRUE`
+	args := []any{}
+	if filter.UserID != "" {
+		args = append(args, filter.UserID)
+		query += fmt.Sprintf(" AND (actor_id = $%d OR subject_id = $%d)", len(args), len(args))
+	}
+	if len(filter.Types) > 0 {
+		args = append(args, filter.Types)
+		query += fmt.Sprintf(" AND event_type = ANY($%d)", len(args))
+	}
+	if filter.Before > 0 {
+		args = append(args, filter.Before)
+		query += fmt.Sprintf(" AND id < $%d", len(args))
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := db.DB.Query(context.Background(), query, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "22P02" {
+			return nil, ErrInvalidAuditFilter
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []AuditEvent{}
+	for rows.Next() {
+		var event AuditEvent
+		var actorID, subjectID, ip, userAgent *string
+		var metadata []byte
+		if err := rows.Scan(&event.ID, &event.Type, &actorID, &subjectID, &ip, &userAgent, &metadata, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		event.ActorID = stringValue(actorID)
+		event.SubjectID = stringValue(subjectID)
+		event.IP = stringValue(ip)
+		event.UserAgent = stringValue(userAgent)
+		if len(metadata) > 0 {
+			if err := json.Unmarshal(metadata, &event.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// PurgeAuditEvents deletes audit events older than the retention period (audit_retention_days,
+// default 365; 0 keeps events forever) and returns how many were deleted
+func PurgeAuditEvents() (int64, error) {
+	if db.DB == nil {
+		return 0, ErrDatabaseError
+	}
+
+	days := auditRetentionDays()
+	if days == 0 {
+		return 0, nil
+	}
+
+	tag, err := db.DB.Exec(context.Background(), `
+		DELETE FROM audit_events
+		WHERE created_at < $1
+	`, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Helper functions
+
+func insertAuditEvents(events []AuditEvent) error {
+	if db.DB == nil {
+		// Without a database the console is the only place events can go
+		for _, event := range events {
+			metadata, _ := json.Marshal(event.Metadata)
+			logging.LogWarning("AUDIT %s subject=%s actor=%s ip=%s metadata=%s",
+				event.Type, event.SubjectID, event.ActorID, event.IP, metadata)
+		}
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, event := range events {
+		var metadata []byte
+		if len(event.Metadata) > 0 {
+			metadata, _ = json.Marshal(event.Metadata)
+		}
+		batch.Queue(`
+			INSERT INTO audit_events (event_type, actor_id, subject_id, ip, user_agent, metadata, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, event.Type, nullString(event.ActorID), nullString(event.SubjectID),
+			nullString(event.IP), nullString(event.UserAgent), metadata, event.CreatedAt)
+	}
+	return db.DB.SendBatch(context.Background(), batch).Close()
+}
+
+func auditRetentionDays() int {
+	days, err := strconv.Atoi(fmt.Sprint(config.GetConfig(config.CONFIG_AUDIT_RETENTION_DAYS)))
+	if err != nil || days < 0 {
+		return defaultAuditRetentionDays
+	}
+	return days
+}
+
+func nullString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,284 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"TetriON.WebServer/server/internal/db"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Ban scopes. A login ban also implies every other scope.
+const (
+	BanScopeLogin  = "login"
+	BanScopeChat   = "chat"
+	BanScopeRanked = "ranked"
+)
+
+var (
+	ErrBanned          = errors.New("account is banned")
+	ErrBanNotFound     = errors.New("ban not found")
+	ErrInvalidBanScope = errors.New("ban scope must be login, chat or ranked")
+	ErrBanReason       = errors.New("a ban reason of at most 500 characters is required")
+	ErrBanNotPermitted = errors.New("you can only ban users with a lower role than yours")
+)
+
+// Ban restricts a user from logging in, chatting or playing ranked, permanently or until ExpiresAt
+type Ban struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Scope      string     `json:"scope"`
+	Reason     string     `json:"reason"`
+	IssuedBy   *string    `json:"issued_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LiftedAt   *time.Time `json:"lifted_at,omitempty"`
+	LiftedBy   *string    `json:"lifted_by,omitempty"`
+	LiftReason *string    `json:"lift_reason,omitempty"`
+}
+
+// Permanent reports whether the ban has no expiry
+func (b *Ban) Permanent() bool {
+	return b.ExpiresAt == nil
+}
+
+// BanError is returned to banned users; it matches ErrBanned with errors.Is
+type BanError struct {
+	Scope     string     `json:"scope"`
+	Reason    string     `json:"reason"`
+	Permanent bool       `json:"permanent"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func (e *BanError) Error() string {
+	if e.Permanent {
+		return fmt.Sprintf("account is permanently banned (%s): %s", e.Scope, e.Reason)
+	}
+	return fmt.Sprintf("account is banned (%s) until %s: %s", e.Scope, e.ExpiresAt.UTC().Format(time.RFC3339), e.Reason)
+}
+
+func (e *BanError) Is(target error) bool {
+	return target == ErrBanned
+}
+
+// ValidBanScope reports whether scope is a known ban scope
+func ValidBanScope(scope string) bool {
+	switch scope {
+	case BanScopeLogin, BanScopeChat, BanScopeRanked:
+		return true
+	}
+	return false
+}
+
+// IssueBan bans a user. A zero duration makes the ban permanent. issuedBy is the moderator's
+// user ID, or empty for bans issued from the console; moderators may only ban users with a
+// lower role than their own. Login bans end every session of the user.
+func IssueBan(userID, scope, reason, issuedBy string, duration time.Duration) (*Ban, error) {
+	if db.DB == nil {
+		return nil, ErrDatabaseError
+	}
+
+	scope = strings.ToLower(strings.TrimSpace(scope))
+	if !ValidBanScope(scope) {
+		return nil, ErrInvalidBanScope
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" || len(reason) > 500 {
+		return nil, ErrBanReason
+	}
+
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt != nil {
+		return nil, ErrUserNotFound
+	}
+	if issuedBy != "" {
+		issuerRole, err := GetUserRole(issuedBy)
+		if err != nil {
+			return nil, err
+		}
+		if roleRank[user.Role] >= roleRank[issuerRole] {
+			return nil, ErrBanNotPermitted
+		}
+	}
+
+	ban := &Ban{
+		UserID:    user.ID,
+		Scope:     scope,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	}
+	if issuedBy != "" {
+		ban.IssuedBy = &issuedBy
+	}
+	if duration > 0 {
+		expiresAt := ban.CreatedAt.Add(duration)
+		ban.ExpiresAt = &expiresAt
+	}
+
+	err = db.DB.QueryRow(context.Background(), `
+		INSERT INTO bans (user_id, scope, reason, issued_by, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, ban.UserID, ban.Scope, ban.Reason, ban.IssuedBy, ban.CreatedAt, ban.ExpiresAt).Scan(&ban.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if scope == BanScopeLogin {
+		if err := RevokeAllSessions(user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	recordAudit(AuditEvent{
+		Type:      AuditUserBanned,
+		ActorID:   issuedBy,
+		SubjectID: user.ID,
+		Metadata: map[string]any{
+			"ban_id":     ban.ID,
+			"scope":      ban.Scope,
+			"reason":     ban.Reason,
+			"expires_at": ban.ExpiresAt,
+		},
+	})
+	return ban, nil
+}
+
+// LiftBan ends an active ban early
+func LiftBan(banID, liftedBy, reason string) (*Ban, error) {
+	if db.DB == nil {
+		return nil, ErrDatabaseError
+	}
+
+	var by, why *string
+	if liftedBy != "" {
+		by = &liftedBy
+	}
+	if reason = strings.TrimSpace(reason); reason != "" {
+		why = &reason
+	}
+
+	ban, err := scanBan(db.DB.QueryRow(context.Background(), `
+		UPDATE bans
+		SET lifted_at = $1, lifted_by = $2, lift_reason = $3
+		WHERE id = $4 AND lifted_at IS NULL
+		RETURNING `+banColumns,
+		time.Now(), by, why, banID))
+	if err != nil {
+		return nil, err
+	}
+
+	recordAudit(AuditEvent{
+		Type:      AuditBanLifted,
+		ActorID:   liftedBy,
+		SubjectID: ban.UserID,
+		Metadata: map[string]any{
+			"ban_id": ban.ID,
+			"scope":  ban.Scope,
+			"reason": reason,
+		},
+	})
+	return ban, nil
+}
+
+// ListBans returns every ban of a user, newest first, including expired and lifted ones
+func ListBans(userID string) ([]Ban, error) {
+	if db.DB == nil {
+		return nil, ErrDatabaseError
+	}
+
+	rows, err := db.DB.Query(context.Background(), `
+		SELECT `+banColumns+`
+		FROM bans
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bans := []Ban{}
+	for rows.Next() {
+		ban, err := scanBan(rows)
+		if err != nil {
+			return nil, err
+		}
+		bans = append(bans, *ban)
+	}
+	return bans, rows.Err()
+}
+
+// CheckBan returns a *BanError if the user has an active ban covering scope, nil otherwise.
+// Login bans cover every scope.
+func CheckBan(userID, scope string) error {
+	if db.DB == nil {
+		return ErrDatabaseError
+	}
+	return checkBan(context.Background(), db.DB, userID, scope)
+}
+
+// Helper functions
+
+// rowQuerier is the part of a pool or transaction checkBan needs
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// checkBan is CheckBan on a given pool or transaction
+func checkBan(ctx context.Context, q rowQuerier, userID, scope string) error {
+	scopes := []string{BanScopeLogin}
+	if scope != BanScopeLogin {
+		scopes = append(scopes, scope)
+	}
+
+	// Permanent bans first, then the one that runs longest
+	ban, err := scanBan(q.QueryRow(ctx, `
+		SELECT `+banColumns+`
+		FROM bans
+		WHERE user_id = $1 AND scope = ANY($2) AND lifted_at IS NULL
+			AND (expires_at IS NULL OR expires_at > $3)
+		ORDER BY expires_at DESC NULLS FIRST
+		LIMIT 1
+	`, userID, scopes, time.Now()))
+	if err == ErrBanNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return &BanError{
+		Scope:     ban.Scope,
+		Reason:    ban.Reason,
+		Permanent: ban.Permanent(),
+		ExpiresAt: ban.ExpiresAt,
+	}
+}
+
+const banColumns = `id, user_id, scope, reason, issued_by, created_at, expires_at, lifted_at, lifted_by, lift_reason`
+
+func scanBan(row pgx.Row) (*Ban, error) {
+	ban := &Ban{}
+	err := row.Scan(&ban.ID, &ban.UserID, &ban.Scope, &ban.Reason, &ban.IssuedBy,
+		&ban.CreatedAt, &ban.ExpiresAt, &ban.LiftedAt, &ban.LiftedBy, &ban.LiftReason)
+	if err == pgx.ErrNoRows {
+		return nil, ErrBanNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "22P02" {
+		// The id is not a valid UUID
+		return nil, ErrBanNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ban, nil
+}
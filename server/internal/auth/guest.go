@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"TetriON.WebServer/server/internal/config"
+	"TetriON.WebServer/server/internal/db"
+	"TetriON.WebServer/server/internal/logging"
+	redisnet "TetriON.WebServer/server/internal/net/redis"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrGuestAccount   = errors.New("not available for guest accounts, register first")
+	ErrNotGuest       = errors.New("account is not a guest account")
+	ErrGuestThrottled = errors.New("too many guest accounts created, try again later")
+)
+
+const (
+	guestNamePrefix   = "Guest_"
+	guestEmailDomain  = "guest.invalid"
+	guestCreateWindow = time.Hour
+	guestCreateMax    = 10
+)
+
+// CreateGuest creates a guest account with a generated name and starts a session for it.
+// Guest sessions end when the account expires (guest_account_ttl_days) unless it is upgraded.
+func CreateGuest(ip string) (*User, *TokenPair, error) {
+	if db.DB == nil {
+		return nil, nil, ErrDatabaseError
+	}
+
+	ctx := context.Background()
+	if ip != "" {
+		allowed, err := redisnet.HitRateLimit(ctx, "guest_create:"+ip, guestCreateWindow, guestCreateMax)
+		if err != nil {
+			logging.LogError("Failed to rate limit guest creation for %s: %v", ip, err)
+		} else if !allowed {
+			return nil, nil, ErrGuestThrottled
+		}
+	}
+
+	now := time.Now()
+	var userID string
+	for attempt := 0; ; attempt++ {
+		suffix, err := randomHex(4)
+		if err != nil {
+			return nil, nil, err
+		}
+		emailID, err := randomHex(8)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		err = db.DB.QueryRow(ctx, `
+			INSERT INTO users (username, email, password_hash, is_guest, guest_expires_at, created_at, updated_at)
+			VALUES ($1, $2, '', TRUE, $3, $4, $4)
+			ON CONFLICT DO NOTHING
+			RETURNING id
+		`, guestNamePrefix+suffix, "guest+"+emailID+"@"+guestEmailDomain, now.Add(guestAccountTTL()), now).Scan(&userID)
+		if err == nil {
+			break
+		}
+		if err != pgx.ErrNoRows {
+			return nil, nil, err
+		}
+		if attempt >= 5 {
+			return nil, nil, errors.New("could not find a free guest name")
+		}
+	}
+
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := IssueSession(user)
+	if err != nil {
+		return nil, nil, errors.New("failed to generate token")
+	}
+	return user, tokens, nil
+}
+
+// UpgradeGuest turns the caller's guest account into a full account. The user ID is kept,
+// so stats and match history stay attached. The current session continues; the returned
+// access token carries the updated claims.
+func UpgradeGuest(claims *Claims, username, email, password string) (*User, string, error) {
+	if db.DB == nil {
+		return nil, "", ErrDatabaseError
+	}
+
+	if err := validateUsername(username); err != nil {
+		return nil, "", err
+	}
+	if err := validateEmail(email); err != nil {
+		return nil, "", err
+	}
+	if err := validatePassword(password, username, email); err != nil {
+		return nil, "", err
+	}
+
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return nil, "", errors.New("failed to hash password")
+	}
+
+	tag, err := db.DB.Exec(context.Background(), `
+		UPDATE users
+		SET username = $1, email = $2, password_hash = $3, email_verified = FALSE,
+			is_guest = FALSE, guest_expires_at = NULL, updated_at = $4
+		WHERE id = $5 AND is_guest AND deleted_at IS NULL
+	`, username, email, hashedPassword, time.Now(), claims.UserID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, "", ErrUserAlreadyExists
+		}
+		return nil, "", err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, "", ErrNotGuest
+	}
+
+	user, err := GetUserByID(claims.UserID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	go func(u User) {
+		if err := SendVerificationEmail(&u); err != nil {
+			logging.LogError("Failed to send verification email to %s: %v", u.Username, err)
+		}
+	}(*user)
+
+	accessToken, err := GenerateToken(user, claims.SessionID)
+	if err != nil {
+		return nil, "", errors.New("failed to generate token")
+	}
+	return user, accessToken, nil
+}
+
+// guestAccountTTL returns guest_account_ttl_days from config.json (default 7)
+func guestAccountTTL() time.Duration {
+	days := 7
+	if d, err := strconv.Atoi(fmt.Sprint(config.GetConfig(config.CONFIG_GUEST_ACCOUNT_TTL_DAYS))); err == nil && d > 0 {
+		days = d
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -2,9 +2,17 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"TetriON.WebServer/server/internal/auth/oidc"
+	"TetriON.WebServer/server/internal/auth/signing"
 	"TetriON.WebServer/server/internal/logging"
+	"TetriON.WebServer/server/internal/mail"
+	"TetriON.WebServer/server/internal/middleware"
 )
 
 type RegisterRequest struct {
@@ -18,11 +26,46 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
 type AuthResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message,omitempty"`
-	Token   string `json:"token,omitempty"`
-	User    *User  `json:"user,omitempty"`
+	Success      bool   `json:"success"`
+	Message      string `json:"message,omitempty"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+	User         *User  `json:"user,omitempty"`
 }
 
 type ErrorResponse struct {
@@ -45,7 +88,10 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Register user
-	user, token, err := Register(req.Username, req.Email, req.Password)
+	user, tokens, err := Register(req.Username, req.Email, req.Password)
+	if respondPasswordPolicy(w, err) {
+		return
+	}
 	if err != nil {
 		logging.LogWarning("Registration failed: %v", err)
 		respondError(w, err.Error(), http.StatusBadRequest)
@@ -53,18 +99,113 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logging.LogInfo("User registered successfully: %s (ID: %s)", user.Username, user.ID)
+	recordSessionClient(r, tokens)
+	auditRequest(r, AuditEvent{Type: AuditRegister, ActorID: user.ID, SubjectID: user.ID})
 
 	// Remove password hash from response
 	user.PasswordHash = ""
 
 	respondJSON(w, AuthResponse{
-		Success: true,
-		Message: "Registration successful",
-		Token:   token,
-		User:    user,
+		Success:      true,
+		Message:      "Registration successful",
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         user,
+	}, http.StatusCreated)
+}
+
+// GuestHandler creates a guest account and returns a session for it (POST /api/auth/guest)
+func GuestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, tokens, err := CreateGuest(middleware.ClientIP(r))
+	if err != nil {
+		if err == ErrGuestThrottled {
+			w.Header().Set("Retry-After", strconv.Itoa(int(guestCreateWindow.Seconds())))
+			respondError(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		logging.LogError("Failed to create guest account: %v", err)
+		respondError(w, "Failed to create guest account", http.StatusInternalServerError)
+		return
+	}
+
+	logging.LogInfo("Guest account created: %s (ID: %s)", user.Username, user.ID)
+	recordSessionClient(r, tokens)
+	auditRequest(r, AuditEvent{Type: AuditGuestCreated, ActorID: user.ID, SubjectID: user.ID})
+
+	respondJSON(w, AuthResponse{
+		Success:      true,
+		Message:      "Guest session started",
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         user,
 	}, http.StatusCreated)
 }
 
+// GuestUpgradeHandler turns the caller's guest account into a full account (POST /api/auth/guest/upgrade)
+func GuestUpgradeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := authenticatedClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var req RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logging.LogError("Failed to decode guest upgrade request: %v", err)
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, accessToken, err := UpgradeGuest(claims, req.Username, req.Email, req.Password)
+	if respondPasswordPolicy(w, err) {
+		return
+	}
+	if err != nil {
+		switch err {
+		case ErrNotGuest:
+			respondError(w, err.Error(), http.StatusConflict)
+		case ErrUserAlreadyExists:
+			respondError(w, "username or email already taken", http.StatusConflict)
+		case ErrInvalidUsername, ErrInvalidEmail:
+			respondError(w, err.Error(), http.StatusBadRequest)
+		default:
+			logging.LogError("Failed to upgrade guest %s: %v", claims.Username, err)
+			respondError(w, "Failed to upgrade guest account", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	logging.LogInfo("Guest %s upgraded to %s (ID: %s)", claims.Username, user.Username, user.ID)
+	auditRequest(r, AuditEvent{
+		Type:      AuditGuestUpgraded,
+		ActorID:   user.ID,
+		SubjectID: user.ID,
+		Metadata:  map[string]any{"guest_username": claims.Username},
+	})
+
+	// Remove password hash from response
+	user.PasswordHash = ""
+
+	respondJSON(w, AuthResponse{
+		Success:   true,
+		Message:   "Account upgraded, check your email to verify it",
+		Token:     accessToken,
+		ExpiresIn: int64(accessTokenTTL().Seconds()),
+		User:      user,
+	}, http.StatusOK)
+}
+
 // LoginHandler handles user login
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -79,8 +220,55 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := middleware.ClientIP(r)
+	if retryAfter, err := CheckLoginLockout(req.Username, ip); err == ErrLoginLocked {
+		logging.LogWarning("Login attempt for locked user %s from %s", req.Username, ip)
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+		respondError(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+
 	// Authenticate user
-	user, token, err := Login(req.Username, req.Password)
+	user, tokens, err := Login(req.Username, req.Password)
+	if err == ErrInvalidCredentials {
+		logging.LogWarning("Login failed for user %s: %v", req.Username, err)
+		if lockout := RecordLoginFailure(req.Username, LoginFailureCredentials, ip, r.UserAgent()); lockout > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(lockout.Seconds())))
+			respondError(w, ErrLoginLocked.Error(), http.StatusTooManyRequests)
+			return
+		}
+		respondError(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+	// With 2FA the failures are only cleared once the code was right too, so that wrong codes
+	// add up across password steps
+	if err == nil || errors.Is(err, ErrBanned) {
+		ClearLoginFailures(req.Username)
+	}
+	if respondBanned(w, err) {
+		logging.LogWarning("Login rejected for banned user %s", req.Username)
+		auditRequest(r, AuditEvent{
+			Type:      AuditLoginFailed,
+			SubjectID: user.ID,
+			Metadata:  map[string]any{"username": req.Username, "reason": "banned"},
+		})
+		return
+	}
+	if err == ErrMFARequired {
+		mfaToken, err := IssueMFAChallenge(user)
+		if err != nil {
+			logging.LogError("Failed to start two-factor login for user %s: %v", user.Username, err)
+			respondError(w, "Failed to start two-factor login", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, AuthResponse{
+			Success:     true,
+			Message:     "Two-factor code required",
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		}, http.StatusOK)
+		return
+	}
 	if err != nil {
 		logging.LogWarning("Login failed for user %s: %v", req.Username, err)
 		respondError(w, "Invalid credentials", http.StatusUnauthorized)
@@ -88,63 +276,1015 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logging.LogInfo("User logged in successfully: %s", user.Username)
+	recordSessionClient(r, tokens)
+	auditRequest(r, AuditEvent{
+		Type:      AuditLogin,
+		ActorID:   user.ID,
+		SubjectID: user.ID,
+		Metadata:  map[string]any{"method": "password", "session_id": tokens.SessionID},
+	})
 
 	// Remove password hash from response
 	user.PasswordHash = ""
 
 	respondJSON(w, AuthResponse{
-		Success: true,
-		Message: "Login successful",
-		Token:   token,
-		User:    user,
+		Success:      true,
+		Message:      "Login successful",
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         user,
 	}, http.StatusOK)
 }
 
-// ProfileHandler returns the current user's profile (protected endpoint)
-func ProfileHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+// MFALoginHandler completes a two-step login with an "mfa_pending" token and a TOTP or recovery code
+func MFALoginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
 		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Extract token from Authorization header
-	token := extractToken(r)
-	if token == "" {
-		respondError(w, "No authorization token provided", http.StatusUnauthorized)
+	var req MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logging.LogError("Failed to decode two-factor login request: %v", err)
+		respondError(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// Validate token and get user
-	user, err := ValidateToken(token)
+	pending, err := PendingMFAUser(req.MFAToken)
 	if err != nil {
-		logging.LogWarning("Invalid token: %v", err)
-		respondError(w, "Invalid or expired token", http.StatusUnauthorized)
+		if err == ErrInvalidMFAToken || err == ErrUserNotFound {
+			logging.LogWarning("Two-factor login failed: %v", err)
+			auditRequest(r, AuditEvent{
+				Type:     AuditLoginFailed,
+				Metadata: map[string]any{"method": "totp", "reason": ErrInvalidMFAToken.Error()},
+			})
+			respondError(w, ErrInvalidMFAToken.Error(), http.StatusUnauthorized)
+			return
+		}
+		logging.LogError("Two-factor login failed: %v", err)
+		respondError(w, "Failed to complete login", http.StatusInternalServerError)
+		return
+	}
+
+	// Wrong codes count toward the same lockout as wrong passwords
+	ip := middleware.ClientIP(r)
+	if retryAfter, err := CheckLoginLockout(pending.Username, ip); err == ErrLoginLocked {
+		logging.LogWarning("Two-factor login attempt for locked user %s from %s", pending.Username, ip)
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+		respondError(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+
+	user, tokens, err := CompleteMFALogin(req.MFAToken, req.Code)
+	if respondBanned(w, err) {
+		logging.LogWarning("Two-factor login rejected for banned user %s", pending.Username)
+		auditRequest(r, AuditEvent{
+			Type:      AuditLoginFailed,
+			SubjectID: pending.ID,
+			Metadata:  map[string]any{"method": "totp", "reason": "banned"},
+		})
+		return
+	}
+	if err != nil {
+		switch err {
+		case ErrInvalidMFACode:
+			logging.LogWarning("Two-factor login failed for user %s: %v", pending.Username, err)
+			if lockout := RecordLoginFailure(pending.Username, LoginFailureMFACode, ip, r.UserAgent()); lockout > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(lockout.Seconds())))
+				respondError(w, ErrLoginLocked.Error(), http.StatusTooManyRequests)
+				return
+			}
+			respondError(w, err.Error(), http.StatusUnauthorized)
+		case ErrInvalidMFAToken:
+			logging.LogWarning("Two-factor login failed for user %s: %v", pending.Username, err)
+			auditRequest(r, AuditEvent{
+				Type:      AuditLoginFailed,
+				SubjectID: pending.ID,
+				Metadata:  map[string]any{"method": "totp", "reason": err.Error()},
+			})
+			respondError(w, err.Error(), http.StatusUnauthorized)
+		default:
+			logging.LogError("Two-factor login failed: %v", err)
+			respondError(w, "Failed to complete login", http.StatusInternalServerError)
+		}
 		return
 	}
+	ClearLoginFailures(user.Username)
+
+	logging.LogInfo("User logged in successfully with two-factor: %s", user.Username)
+	recordSessionClient(r, tokens)
+	auditRequest(r, AuditEvent{
+		Type:      AuditLogin,
+		ActorID:   user.ID,
+		SubjectID: user.ID,
+		Metadata:  map[string]any{"method": "totp", "session_id": tokens.SessionID},
+	})
 
 	// Remove password hash from response
 	user.PasswordHash = ""
 
+	respondJSON(w, AuthResponse{
+		Success:      true,
+		Message:      "Login successful",
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         user,
+	}, http.StatusOK)
+}
+
+// TOTPSetupHandler starts TOTP enrolment and returns the secret and otpauth URI for the QR code
+func TOTPSetupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := authenticatedClaims(w, r)
+	if !ok {
+		return
+	}
+
+	enrollment, err := BeginTOTPEnrollment(claims.UserID)
+	if err != nil {
+		switch err {
+		case ErrMFAAlreadyEnabled:
+			respondError(w, err.Error(), http.StatusConflict)
+			return
+		case ErrGuestAccount:
+			respondError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		logging.LogError("TOTP setup failed for user %s: %v", claims.Username, err)
+		respondError(w, "Failed to start two-factor setup", http.StatusInternalServerError)
+		return
+	}
+
 	respondJSON(w, map[string]interface{}{
 		"success": true,
-		"user":    user,
+		"totp":    enrollment,
 	}, http.StatusOK)
 }
 
-// Helper functions
+// TOTPConfirmHandler enables 2FA with a first valid code and returns the recovery codes
+func TOTPConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-func extractToken(r *http.Request) string {
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		return ""
+	claims, ok := authenticatedClaims(w, r)
+	if !ok {
+		return
 	}
 
-	// Expected format: "Bearer <token>"
-	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-		return authHeader[7:]
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logging.LogError("Failed to decode TOTP confirm request: %v", err)
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
 	}
 
-	return authHeader
+	codes, err := ConfirmTOTPEnrollment(claims.UserID, req.Code)
+	if err != nil {
+		switch err {
+		case ErrInvalidMFACode, ErrMFANotEnrolled:
+			respondError(w, err.Error(), http.StatusBadRequest)
+		case ErrMFAAlreadyEnabled:
+			respondError(w, err.Error(), http.StatusConflict)
+		default:
+			logging.LogError("TOTP confirmation failed for user %s: %v", claims.Username, err)
+			respondError(w, "Failed to enable two-factor authentication", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	logging.LogInfo("Two-factor authentication enabled for user: %s", claims.Username)
+	auditRequest(r, AuditEvent{Type: AuditMFAEnabled, ActorID: claims.UserID, SubjectID: claims.UserID})
+
+	respondJSON(w, map[string]interface{}{
+		"success":        true,
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	}, http.StatusOK)
+}
+
+// TOTPDisableHandler turns 2FA off; it requires a current TOTP or recovery code
+func TOTPDisableHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := authenticatedClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logging.LogError("Failed to decode TOTP disable request: %v", err)
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := DisableTOTP(claims.UserID, req.Code); err != nil {
+		switch err {
+		case ErrInvalidMFACode:
+			respondError(w, err.Error(), http.StatusForbidden)
+		case ErrMFANotEnabled:
+			respondError(w, err.Error(), http.StatusBadRequest)
+		default:
+			logging.LogError("Disabling TOTP failed for user %s: %v", claims.Username, err)
+			respondError(w, "Failed to disable two-factor authentication", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	logging.LogInfo("Two-factor authentication disabled for user: %s", claims.Username)
+	auditRequest(r, AuditEvent{Type: AuditMFADisabled, ActorID: claims.UserID, SubjectID: claims.UserID})
+
+	respondJSON(w, map[string]interface{}{
+		"success": true,
+		"message": "Two-factor authentication disabled",
+	}, http.StatusOK)
+}
+
+// RefreshHandler exchanges a refresh token for a new access/refresh token pair
+func RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logging.LogError("Failed to decode refresh request: %v", err)
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, tokens, err := RefreshSession(req.RefreshToken)
+	if respondBanned(w, err) {
+		logging.LogWarning("Token refresh rejected for a banned user")
+		return
+	}
+	if err != nil {
+		if err == ErrRefreshTokenReused {
+			logging.LogWarning("Refresh token reuse detected, session family revoked")
+		} else if err != ErrInvalidRefreshToken {
+			logging.LogError("Token refresh failed: %v", err)
+		}
+		respondError(w, "Invalid or expired refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	auditRequest(r, AuditEvent{
+		Type:      AuditTokenRefreshed,
+		ActorID:   user.ID,
+		SubjectID: user.ID,
+		Metadata:  map[string]any{"session_id": tokens.SessionID},
+	})
+
+	// Remove password hash from response
+	user.PasswordHash = ""
+
+	respondJSON(w, AuthResponse{
+		Success:      true,
+		Message:      "Token refreshed",
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         user,
+	}, http.StatusOK)
+}
+
+// LogoutHandler revokes the caller's access token and the session it belongs to
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := authenticatedClaims(w, r)
+	if !ok {
+		return
+	}
+
+	if err := Logout(claims); err != nil {
+		logging.LogError("Logout failed for user %s: %v", claims.Username, err)
+		respondError(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	logging.LogInfo("User logged out: %s", claims.Username)
+	auditRequest(r, AuditEvent{
+		Type:      AuditLogout,
+		ActorID:   claims.UserID,
+		SubjectID: claims.UserID,
+		Metadata:  map[string]any{"session_id": claims.SessionID},
+	})
+
+	respondJSON(w, map[string]interface{}{
+		"success": true,
+		"message": "Logged out",
+	}, http.StatusOK)
+}
+
+// LogoutAllHandler revokes every session of the caller ("log out everywhere")
+func LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := authenticatedClaims(w, r)
+	if !ok {
+		return
+	}
+
+	if err := RevokeAllSessions(claims.UserID); err != nil {
+		logging.LogError("Logout everywhere failed for user %s: %v", claims.Username, err)
+		respondError(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	logging.LogInfo("User logged out of all sessions: %s", claims.Username)
+	auditRequest(r, AuditEvent{Type: AuditLogoutAll, ActorID: claims.UserID, SubjectID: claims.UserID})
+
+	respondJSON(w, map[string]interface{}{
+		"success": true,
+		"message": "Logged out of all sessions",
+	}, http.StatusOK)
+}
+
+// VerifyEmailHandler confirms an email address from the emailed link (GET /api/auth/verify?token=)
+func VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, err := VerifyEmail(r.URL.Query().Get("token"))
+	if err != nil {
+		if err != ErrInvalidVerificationToken {
+			logging.LogError("Email verification failed: %v", err)
+		}
+		respondError(w, "Invalid or expired verification link", http.StatusBadRequest)
+		return
+	}
+
+	logging.LogInfo("Email verified for user: %s", user.Username)
+	auditRequest(r, AuditEvent{Type: AuditEmailVerified, ActorID: user.ID, SubjectID: user.ID})
+
+	respondJSON(w, map[string]interface{}{
+		"success": true,
+		"message": "Email verified",
+	}, http.StatusOK)
+}
+
+// ResendVerificationHandler sends a new verification link to the caller (throttled)
+func ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := authenticatedClaims(w, r)
+	if !ok {
+		return
+	}
+
+	retryAfter, err := ResendVerificationEmail(claims.UserID)
+	if err != nil {
+		switch err {
+		case ErrEmailAlreadyVerified:
+			respondError(w, err.Error(), http.StatusConflict)
+		case ErrGuestAccount:
+			respondError(w, err.Error(), http.StatusForbidden)
+		case ErrVerificationThrottled:
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			respondError(w, err.Error(), http.StatusTooManyRequests)
+		default:
+			logging.LogError("Failed to resend verification email for user %s: %v", claims.Username, err)
+			respondError(w, "Failed to send verification email", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"success": true,
+		"message": "Verification email sent",
+	}, http.StatusOK)
+}
+
+// ForgotPasswordHandler emails a password reset link. It always answers 200 to avoid account enumeration.
+func ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logging.LogError("Failed to decode forgot password request: %v", err)
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Handled in the background so response timing does not reveal whether the account exists
+	go func(email string) {
+		if err := RequestPasswordReset(email); err != nil {
+			logging.LogError("Password reset request failed: %v", err)
+		}
+	}(req.Email)
+
+	respondJSON(w, map[string]interface{}{
+		"success": true,
+		"message": "If an account with that email exists, a reset link has been sent",
+	}, http.StatusOK)
+}
+
+// ResetPasswordHandler sets a new password from a reset token and signs out every session
+func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		// The emailed link opens the reset form when no client page is configured
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(resetPasswordPage))
+		return
+	}
+	if r.Method != http.MethodPost {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logging.LogError("Failed to decode reset password request: %v", err)
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := ResetPassword(req.Token, req.Password)
+	if respondPasswordPolicy(w, err) {
+		return
+	}
+	if err != nil {
+		switch err {
+		case ErrInvalidResetToken:
+			respondError(w, "Invalid or expired reset token", http.StatusBadRequest)
+		default:
+			logging.LogError("Password reset failed: %v", err)
+			respondError(w, "Failed to reset password", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	logging.LogInfo("Password reset completed")
+	auditRequest(r, AuditEvent{Type: AuditPasswordReset, ActorID: userID, SubjectID: userID})
+
+	respondJSON(w, map[string]interface{}{
+		"success": true,
+		"message": "Password has been reset",
+	}, http.StatusOK)
+}
+
+// ChangePasswordHandler changes the caller's password after checking the current one
+func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := authenticatedClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logging.LogError("Failed to decode change password request: %v", err)
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err := ChangePassword(claims.UserID, claims.SessionID, req.CurrentPassword, req.NewPassword)
+	if respondPasswordPolicy(w, err) {
+		return
+	}
+	if err != nil {
+		switch err {
+		case ErrIncorrectPassword, ErrRecentLoginNeeded, ErrGuestAccount:
+			respondError(w, err.Error(), http.StatusForbidden)
+		case ErrSamePassword:
+			respondError(w, err.Error(), http.StatusBadRequest)
+		default:
+			logging.LogError("Password change failed for user %s: %v", claims.Username, err)
+			respondError(w, "Failed to change password", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	logging.LogInfo("Password changed for user: %s", claims.Username)
+	auditRequest(r, AuditEvent{Type: AuditPasswordChanged, ActorID: claims.UserID, SubjectID: claims.UserID})
+
+	respondJSON(w, map[string]interface{}{
+		"success": true,
+		"message": "Password changed, please log in again",
+	}, http.StatusOK)
+}
+
+// DeleteAccountHandler schedules the caller's account for deletion after re-authentication
+func DeleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := authenticatedClaims(w, r)
+	if !ok {
+		return
+	}
+
+	// Guests and accounts without a password may send no body
+	var req DeleteAccountRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			logging.LogError("Failed to decode delete account request: %v", err)
+			respondError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	scheduledAt, err := ScheduleAccountDeletion(claims.UserID, claims.SessionID, req.Password)
+	if err != nil {
+		switch err {
+		case ErrIncorrectPassword:
+			respondError(w, "Password is incorrect", http.StatusForbidden)
+			return
+		case ErrRecentLoginNeeded:
+			respondError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		logging.LogError("Account deletion failed for user %s: %v", claims.Username, err)
+		respondError(w, "Failed to delete account", http.StatusInternalServerError)
+		return
+	}
+
+	logging.LogInfo("Account deletion scheduled for user %s at %s", claims.Username, scheduledAt.Format(time.RFC3339))
+	auditRequest(r, AuditEvent{
+		Type:      AuditDeletionRequested,
+		ActorID:   claims.UserID,
+		SubjectID: claims.UserID,
+		Metadata:  map[string]any{"deletion_scheduled_at": scheduledAt},
+	})
+
+	respondJSON(w, map[string]interface{}{
+		"success":               true,
+		"message":               "Account scheduled for deletion, log in before the deadline to cancel",
+		"deletion_scheduled_at": scheduledAt,
+	}, http.StatusAccepted)
+}
+
+// OIDCProvidersHandler lists the configured social login providers
+func OIDCProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"success":   true,
+		"providers": oidc.Names(),
+	}, http.StatusOK)
+}
+
+// OIDCStartHandler redirects the browser to the provider to log in (GET /api/auth/oidc/{provider}/start)
+func OIDCStartHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	authURL, binding, err := BeginOIDC(r.PathValue("provider"), OIDCModeLogin, "")
+	if err != nil {
+		respondOIDCError(w, err)
+		return
+	}
+
+	setOIDCBinding(w, r, binding)
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCLinkHandler returns the provider URL that links a provider account to the caller.
+// The browser must call it with credentials so that it keeps the binding cookie.
+func OIDCLinkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := authenticatedClaims(w, r)
+	if !ok {
+		return
+	}
+
+	authURL, binding, err := BeginOIDC(r.PathValue("provider"), OIDCModeLink, claims.UserID)
+	if err != nil {
+		respondOIDCError(w, err)
+		return
+	}
+
+	// Only the browser that made this request can complete the link
+	setOIDCBinding(w, r, binding)
+
+	respondJSON(w, map[string]interface{}{
+		"success":           true,
+		"authorization_url": authURL,
+	}, http.StatusOK)
+}
+
+// OIDCCallbackHandler completes a provider login or link (GET /api/auth/oidc/{provider}/callback)
+func OIDCCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		logging.LogWarning("OIDC provider %s returned error: %s", r.PathValue("provider"), providerErr)
+		respondError(w, "Sign-in was cancelled or denied by the provider", http.StatusBadRequest)
+		return
+	}
+
+	var binding string
+	if cookie, err := r.Cookie(OIDCBindingCookie); err == nil {
+		binding = cookie.Value
+	}
+	clearOIDCBinding(w, r)
+
+	result, err := CompleteOIDC(r.PathValue("provider"), query.Get("state"), binding, query.Get("code"))
+	if err == ErrMFARequired {
+		mfaToken, err := IssueMFAChallenge(result.User)
+		if err != nil {
+			logging.LogError("Failed to start two-factor login for user %s: %v", result.User.Username, err)
+			respondError(w, "Failed to start two-factor login", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, AuthResponse{
+			Success:     true,
+			Message:     "Two-factor code required",
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		}, http.StatusOK)
+		return
+	}
+	if err != nil {
+		respondOIDCError(w, err)
+		return
+	}
+
+	// Remove password hash from response
+	result.User.PasswordHash = ""
+
+	if result.Mode == OIDCModeLink {
+		logging.LogInfo("User %s linked provider %s", result.User.Username, r.PathValue("provider"))
+		auditRequest(r, AuditEvent{
+			Type:      AuditIdentityLinked,
+			ActorID:   result.User.ID,
+			SubjectID: result.User.ID,
+			Metadata:  map[string]any{"provider": r.PathValue("provider")},
+		})
+		respondJSON(w, AuthResponse{
+			Success: true,
+			Message: "Provider linked",
+			User:    result.User,
+		}, http.StatusOK)
+		return
+	}
+
+	message := "Login successful"
+	status := http.StatusOK
+	if result.Created {
+		logging.LogInfo("User registered via %s: %s (ID: %s)", r.PathValue("provider"), result.User.Username, result.User.ID)
+		message = "Registration successful"
+		status = http.StatusCreated
+	} else {
+		logging.LogInfo("User logged in via %s: %s", r.PathValue("provider"), result.User.Username)
+	}
+	recordSessionClient(r, result.Tokens)
+	eventType := AuditLogin
+	if result.Created {
+		eventType = AuditRegister
+	}
+	auditRequest(r, AuditEvent{
+		Type:      eventType,
+		ActorID:   result.User.ID,
+		SubjectID: result.User.ID,
+		Metadata:  map[string]any{"method": "oidc", "provider": r.PathValue("provider"), "session_id": result.Tokens.SessionID},
+	})
+
+	respondJSON(w, AuthResponse{
+		Success:      true,
+		Message:      message,
+		Token:        result.Tokens.AccessToken,
+		RefreshToken: result.Tokens.RefreshToken,
+		ExpiresIn:    result.Tokens.ExpiresIn,
+		User:         result.User,
+	}, status)
+}
+
+// OIDCUnlinkHandler removes a linked provider from the caller (DELETE /api/auth/oidc/{provider})
+func OIDCUnlinkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := authenticatedClaims(w, r)
+	if !ok {
+		return
+	}
+
+	if err := UnlinkIdentity(claims.UserID, r.PathValue("provider")); err != nil {
+		respondOIDCError(w, err)
+		return
+	}
+
+	logging.LogInfo("User %s unlinked provider %s", claims.Username, r.PathValue("provider"))
+	auditRequest(r, AuditEvent{
+		Type:      AuditIdentityUnlinked,
+		ActorID:   claims.UserID,
+		SubjectID: claims.UserID,
+		Metadata:  map[string]any{"provider": r.PathValue("provider")},
+	})
+
+	respondJSON(w, map[string]interface{}{
+		"success": true,
+		"message": "Provider unlinked",
+	}, http.StatusOK)
+}
+
+// IdentitiesHandler lists the providers linked to the caller
+func IdentitiesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := authenticatedClaims(w, r)
+	if !ok {
+		return
+	}
+
+	identities, err := ListIdentities(claims.UserID)
+	if err != nil {
+		logging.LogError("Failed to list identities for user %s: %v", claims.Username, err)
+		respondError(w, "Failed to list linked providers", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"success":    true,
+		"identities": identities,
+	}, http.StatusOK)
+}
+
+// SessionsHandler lists the caller's active sessions (GET /api/auth/sessions)
+func SessionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := authenticatedClaims(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := ListSessions(claims.UserID, claims.SessionID)
+	if err != nil {
+		logging.LogError("Failed to list sessions for user %s: %v", claims.Username, err)
+		respondError(w, "Failed to list sessions", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"success":  true,
+		"sessions": sessions,
+	}, http.StatusOK)
+}
+
+// SessionHandler logs out one of the caller's sessions (DELETE /api/auth/sessions/{id})
+func SessionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := authenticatedClaims(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID := r.PathValue("id")
+	if err := RevokeSession(claims.UserID, sessionID); err != nil {
+		if err == ErrSessionNotFound {
+			respondError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		logging.LogError("Failed to revoke session %s for user %s: %v", sessionID, claims.Username, err)
+		respondError(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+
+	logging.LogInfo("User %s revoked session %s", claims.Username, sessionID)
+	auditRequest(r, AuditEvent{
+		Type:      AuditSessionRevoked,
+		ActorID:   claims.UserID,
+		SubjectID: claims.UserID,
+		Metadata:  map[string]any{"session_id": sessionID},
+	})
+	respondJSON(w, map[string]interface{}{
+		"success": true,
+		"message": "Session revoked",
+	}, http.StatusOK)
+}
+
+// JWKSHandler publishes the public token signing keys (GET /.well-known/jwks.json)
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondJSON(w, map[string]interface{}{
+		"keys": signing.PublicKeys(),
+	}, http.StatusOK)
+}
+
+// ProfileHandler returns the current user's profile (protected endpoint)
+func ProfileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract token from Authorization header
+	token := extractToken(r)
+	if token == "" {
+		respondError(w, "No authorization token provided", http.StatusUnauthorized)
+		return
+	}
+
+	// Validate token and get user
+	user, err := ValidateToken(token)
+	if respondBanned(w, err) {
+		return
+	}
+	if err != nil {
+		logging.LogWarning("Invalid token: %v", err)
+		respondError(w, "Invalid or expired token", http.StatusUnauthorized)
+		return
+	}
+
+	// Remove password hash from response
+	user.PasswordHash = ""
+
+	respondJSON(w, map[string]interface{}{
+		"success": true,
+		"user":    user,
+	}, http.StatusOK)
+}
+
+// Helper functions
+
+// authenticatedClaims validates the bearer token of the request and writes a 401 when it is unusable
+func authenticatedClaims(w http.ResponseWriter, r *http.Request) (*Claims, bool) {
+	token := extractToken(r)
+	if token == "" {
+		respondError(w, "No authorization token provided", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	_, claims, err := AuthenticateToken(token)
+	if respondBanned(w, err) {
+		return nil, false
+	}
+	if err != nil {
+		logging.LogWarning("Invalid token: %v", err)
+		respondError(w, "Invalid or expired token", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	return claims, true
+}
+
+func extractToken(r *http.Request) string {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return ""
+	}
+
+	// Expected format: "Bearer <token>"
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		return authHeader[7:]
+	}
+
+	return authHeader
+}
+
+// recordSessionClient stores the device and IP of a freshly issued session
+func recordSessionClient(r *http.Request, tokens *TokenPair) {
+	if tokens == nil || tokens.SessionID == "" {
+		return
+	}
+	if err := UpdateSessionClient(tokens.SessionID, r.UserAgent(), middleware.ClientIP(r)); err != nil {
+		logging.LogError("Failed to record session client: %v", err)
+	}
+}
+
+// respondPasswordPolicy writes a 400 with the violated rule as "code" when err is a *PasswordPolicyError
+func respondPasswordPolicy(w http.ResponseWriter, err error) bool {
+	var policyErr *PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+	respondJSON(w, map[string]interface{}{
+		"success": false,
+		"error":   policyErr.Message,
+		"code":    policyErr.Code,
+	}, http.StatusBadRequest)
+	return true
+}
+
+// respondBanned writes a 403 with the ban details when err is a *BanError
+func respondBanned(w http.ResponseWriter, err error) bool {
+	var banErr *BanError
+	if !errors.As(err, &banErr) {
+		return false
+	}
+	respondJSON(w, map[string]interface{}{
+		"success": false,
+		"error":   ErrBanned.Error(),
+		"ban":     banErr,
+	}, http.StatusForbidden)
+	return true
+}
+
+// setOIDCBinding stores the binding of a provider flow in the browser that starts it
+func setOIDCBinding(w http.ResponseWriter, r *http.Request, binding string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     OIDCBindingCookie,
+		Value:    binding,
+		Path:     "/api/auth/oidc/",
+		MaxAge:   int(oidcStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   secureCookies(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func clearOIDCBinding(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     OIDCBindingCookie,
+		Value:    "",
+		Path:     "/api/auth/oidc/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secureCookies(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// secureCookies reports whether cookies should be limited to HTTPS
+func secureCookies(r *http.Request) bool {
+	return r.TLS != nil || strings.HasPrefix(mail.PublicURL(""), "https://")
+}
+
+func respondOIDCError(w http.ResponseWriter, err error) {
+	if respondBanned(w, err) {
+		return
+	}
+	switch err {
+	case oidc.ErrUnknownProvider:
+		respondError(w, err.Error(), http.StatusNotFound)
+	case ErrInvalidOIDCState, ErrIdentityEmailMissing, ErrIdentityNotLinked:
+		respondError(w, err.Error(), http.StatusBadRequest)
+	case ErrIdentityAlreadyLinked, ErrProviderAlreadyLinked, ErrIdentityEmailInUse, ErrLastLoginMethod:
+		respondError(w, err.Error(), http.StatusConflict)
+	case ErrInvalidCredentials:
+		respondError(w, "Invalid credentials", http.StatusUnauthorized)
+	default:
+		logging.LogError("OIDC flow failed: %v", err)
+		respondError(w, "Sign-in with provider failed", http.StatusBadGateway)
+	}
 }
 
 func respondJSON(w http.ResponseWriter, data interface{}, statusCode int) {
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"TetriON.WebServer/server/internal/logging"
+	redisnet "TetriON.WebServer/server/internal/net/redis"
+)
+
+var ErrLoginLocked = errors.New("too many failed login attempts, try again later")
+
+// Failed logins are counted per username and per client IP. Exceeding either limit locks that
+// subject out; every further lockout within a day doubles the duration.
+var (
+	userLockoutPolicy = redisnet.LockoutPolicy{
+		Window:      15 * time.Minute,
+		MaxFailures: 5,
+		BaseLockout: time.Minute,
+		MaxLockout:  time.Hour,
+		LevelTTL:    24 * time.Hour,
+	}
+	ipLockoutPolicy = redisnet.LockoutPolicy{
+		Window:      15 * time.Minute,
+		MaxFailures: 20,
+		BaseLockout: time.Minute,
+		MaxLockout:  time.Hour,
+		LevelTTL:    24 * time.Hour,
+	}
+)
+
+const (
+	lockoutKindUser = "user"
+	lockoutKindIP   = "ip"
+
+	// Reasons recorded with a failed login
+	LoginFailureCredentials = "invalid_credentials"
+	LoginFailureMFACode     = "invalid_mfa_code"
+)
+
+// CheckLoginLockout returns ErrLoginLocked and the remaining time when the username or IP is locked out.
+// Redis errors are logged and do not block logins.
+func CheckLoginLockout(username, ip string) (time.Duration, error) {
+	ctx := context.Background()
+
+	var retryAfter time.Duration
+	for kind, subject := range lockoutSubjects(username, ip) {
+		remaining, err := redisnet.LockoutRemaining(ctx, kind, subject)
+		if err != nil {
+			logging.LogError("Failed to check login lockout for %s %s: %v", kind, subject, err)
+			continue
+		}
+		if remaining > retryAfter {
+			retryAfter = remaining
+		}
+	}
+
+	if retryAfter > 0 {
+		return retryAfter, ErrLoginLocked
+	}
+	return 0, nil
+}
+
+// RecordLoginFailure counts a failed login for the username and IP: a wrong password or a wrong
+// two-factor code, named by reason. It returns the lockout duration when this failure locked
+// either of them. The failure and any account lockout are audited.
+func RecordLoginFailure(username, reason, ip, userAgent string) time.Duration {
+	ctx := context.Background()
+
+	var subjectID string
+	if user, err := GetUserByUsername(username); err == nil {
+		subjectID = user.ID
+	}
+	recordAudit(AuditEvent{
+		Type:      AuditLoginFailed,
+		SubjectID: subjectID,
+		IP:        ip,
+		UserAgent: userAgent,
+		Metadata:  map[string]any{"username": username, "reason": reason},
+	})
+
+	var lockout time.Duration
+	for kind, subject := range lockoutSubjects(username, ip) {
+		policy := userLockoutPolicy
+		if kind == lockoutKindIP {
+			policy = ipLockoutPolicy
+		}
+
+		locked, err := redisnet.RecordFailure(ctx, kind, subject, policy)
+		if err != nil {
+			logging.LogError("Failed to record login failure for %s %s: %v", kind, subject, err)
+			continue
+		}
+		if locked == 0 {
+			continue
+		}
+		if locked > lockout {
+			lockout = locked
+		}
+
+		if kind == lockoutKindIP {
+			logging.LogWarning("Locked out IP %s for %s after repeated failed logins", ip, locked)
+			continue
+		}
+
+		recordAudit(AuditEvent{
+			Type:      AuditAccountLocked,
+			SubjectID: subjectID,
+			IP:        ip,
+			UserAgent: userAgent,
+			Metadata: map[string]any{
+				"username":        subject,
+				"lockout_seconds": int(locked.Seconds()),
+				"max_failures":    policy.MaxFailures,
+				"window_seconds":  int(policy.Window.Seconds()),
+			},
+		})
+	}
+	return lockout
+}
+
+// ClearLoginFailures resets the failure count and backoff of a username once a login completed,
+// including its second factor
+func ClearLoginFailures(username string) {
+	if err := redisnet.ClearFailures(context.Background(), lockoutKindUser, normalizeLockoutUsername(username)); err != nil {
+		logging.LogError("Failed to clear login failures for %s: %v", username, err)
+	}
+}
+
+func lockoutSubjects(username, ip string) map[string]string {
+	subjects := map[string]string{lockoutKindUser: normalizeLockoutUsername(username)}
+	if ip != "" {
+		subjects[lockoutKindIP] = ip
+	}
+	return subjects
+}
+
+func normalizeLockoutUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
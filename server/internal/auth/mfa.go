@@ -0,0 +1,362 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"TetriON.WebServer/server/internal/db"
+	"TetriON.WebServer/server/internal/logging"
+	redisnet "TetriON.WebServer/server/internal/net/redis"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrMFARequired       = errors.New("two-factor authentication required")
+	ErrInvalidMFACode    = errors.New("invalid two-factor code")
+	ErrInvalidMFAToken   = errors.New("invalid or expired two-factor login token")
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrMFANotEnrolled    = errors.New("two-factor enrolment has not been started")
+)
+
+const (
+	mfaPendingTicket  = "mfa_pending"
+	mfaPendingTTL     = 5 * time.Minute
+	mfaMaxAttempts    = 5
+	recoveryCodeCount = 10
+)
+
+// TOTPEnrollment is returned when a user starts enrolling an authenticator app
+type TOTPEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	QRPayload  string `json:"qr_payload"`
+}
+
+// BeginTOTPEnrollment generates and stores a new (unconfirmed) TOTP secret for the user
+func BeginTOTPEnrollment(userID string) (*TOTPEnrollment, error) {
+	if db.DB == nil {
+		return nil, ErrDatabaseError
+	}
+
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsGuest {
+		return nil, ErrGuestAccount
+	}
+	if user.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := sealSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.DB.Exec(context.Background(), `
+		UPDATE users
+		SET totp_secret = $1, updated_at = $2
+		WHERE id = $3 AND totp_enabled = FALSE
+	`, sealed, time.Now(), user.ID); err != nil {
+		return nil, err
+	}
+
+	uri := totpURI(secret, user.Username)
+	return &TOTPEnrollment{
+		Secret:     secret,
+		OTPAuthURI: uri,
+		QRPayload:  uri,
+	}, nil
+}
+
+// ConfirmTOTPEnrollment enables 2FA once the user proves their app produces valid codes.
+// It returns freshly generated recovery codes, which are only shown this once.
+func ConfirmTOTPEnrollment(userID, code string) ([]string, error) {
+	if db.DB == nil {
+		return nil, ErrDatabaseError
+	}
+
+	secret, enabled, err := loadTOTPSecret(userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if secret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+	if err := checkTOTP(userID, secret, code); err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	if _, err := tx.Exec(ctx, `
+		UPDATE users
+		SET totp_enabled = TRUE, totp_enabled_at = $1, updated_at = $1
+		WHERE id = $2
+	`, now, userID); err != nil {
+		return nil, err
+	}
+
+	codes, err := replaceRecoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP turns 2FA off after checking a current TOTP or recovery code
+func DisableTOTP(userID, code string) error {
+	if db.DB == nil {
+		return ErrDatabaseError
+	}
+
+	if err := verifySecondFactor(userID, code); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE users
+		SET totp_secret = NULL, totp_enabled = FALSE, totp_enabled_at = NULL, updated_at = $1
+		WHERE id = $2
+	`, time.Now(), userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// IssueMFAChallenge returns a short-lived "mfa_pending" token for a user who passed the password step
+func IssueMFAChallenge(user *User) (string, error) {
+	rawToken, tokenHash, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := redisnet.PutTicket(context.Background(), mfaPendingTicket, tokenHash, user.ID, mfaPendingTTL); err != nil {
+		return "", err
+	}
+	return rawToken, nil
+}
+
+// PendingMFAUser returns the user an "mfa_pending" token was issued to, without consuming it
+func PendingMFAUser(mfaToken string) (*User, error) {
+	userID, ok, err := redisnet.GetTicket(context.Background(), mfaPendingTicket, hashOpaqueToken(mfaToken))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidMFAToken
+	}
+	return GetUserByID(userID)
+}
+
+// CompleteMFALogin exchanges an "mfa_pending" token and a valid TOTP or recovery code for a session
+func CompleteMFALogin(mfaToken, code string) (*User, *TokenPair, error) {
+	ctx := context.Background()
+	tokenHash := hashOpaqueToken(mfaToken)
+
+	userID, ok, err := redisnet.GetTicket(ctx, mfaPendingTicket, tokenHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, ErrInvalidMFAToken
+	}
+
+	if err := verifySecondFactor(userID, code); err != nil {
+		if err != ErrInvalidMFACode {
+			return nil, nil, err
+		}
+		failures, countErr := redisnet.CountTicketFailure(ctx, mfaPendingTicket, tokenHash, mfaPendingTTL)
+		if countErr == nil && failures >= mfaMaxAttempts {
+			// Too many wrong codes: force the user back through the password step
+			redisnet.TakeTicket(ctx, mfaPendingTicket, tokenHash)
+		}
+		return nil, nil, ErrInvalidMFACode
+	}
+
+	// Consume the ticket so the same token cannot be exchanged twice
+	if _, ok, err := redisnet.TakeTicket(ctx, mfaPendingTicket, tokenHash); err != nil {
+		return nil, nil, err
+	} else if !ok {
+		return nil, nil, ErrInvalidMFAToken
+	}
+
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := CheckBan(user.ID, BanScopeLogin); err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := startSession(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// Helper functions
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code
+func verifySecondFactor(userID, code string) error {
+	secret, enabled, err := loadTOTPSecret(userID)
+	if err != nil {
+		return err
+	}
+	if !enabled || secret == "" {
+		return ErrMFANotEnabled
+	}
+
+	normalized := normalizeRecoveryCode(code)
+	if len(normalized) == totpDigits && isDigits(normalized) {
+		return checkTOTP(userID, secret, normalized)
+	}
+	return consumeRecoveryCode(userID, normalized)
+}
+
+// checkTOTP validates a code and rejects replays of a code that was already accepted
+func checkTOTP(userID, secret, code string) error {
+	step, ok := matchTOTP(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	fresh, _, err := redisnet.AcquireCooldown(context.Background(), "totp_used", userID+":"+strconv.FormatInt(step, 10), time.Duration(totpPeriod*(2*totpSkewSteps+1))*time.Second)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func loadTOTPSecret(userID string) (string, bool, error) {
+	if db.DB == nil {
+		return "", false, ErrDatabaseError
+	}
+
+	var sealed *string
+	var enabled bool
+	err := db.DB.QueryRow(context.Background(), `
+		SELECT totp_secret, totp_enabled
+		FROM users
+		WHERE id = $1
+	`, userID).Scan(&sealed, &enabled)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", false, ErrUserNotFound
+		}
+		return "", false, err
+	}
+	if sealed == nil || *sealed == "" {
+		return "", enabled, nil
+	}
+
+	secret, err := openSecret(*sealed)
+	if err != nil {
+		logging.LogError("Failed to decrypt TOTP secret for user %s: %v", userID, err)
+		return "", false, err
+	}
+	return secret, enabled, nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID string) ([]string, error) {
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(base32.StdEncoding.EncodeToString(buf))
+		codes = append(codes, raw[:4]+"-"+raw[4:])
+
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO mfa_recovery_codes (user_id, code_hash)
+			VALUES ($1, $2)
+		`, userID, hashOpaqueToken(raw)); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+func consumeRecoveryCode(userID, normalized string) error {
+	if normalized == "" {
+		return ErrInvalidMFACode
+	}
+
+	tag, err := db.DB.Exec(context.Background(), `
+		UPDATE mfa_recovery_codes
+		SET used_at = $1
+		WHERE id = (
+			SELECT id FROM mfa_recovery_codes
+			WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL
+			LIMIT 1
+		)
+	`, time.Now(), userID, hashOpaqueToken(normalized))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInvalidMFACode
+	}
+
+	logging.LogInfo("Recovery code used by user %s", userID)
+	return nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
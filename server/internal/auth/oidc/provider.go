@@ -0,0 +1,375 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown identity provider")
+	ErrMissingSubject  = errors.New("identity provider did not return a subject")
+	ErrNonceMismatch   = errors.New("id token nonce does not match")
+)
+
+// ProviderConfig describes an OAuth2 / OpenID Connect provider.
+// When Issuer is set, endpoints that are left empty are filled from its discovery document.
+type ProviderConfig struct {
+	Name                  string   `json:"name"`
+	Issuer                string   `json:"issuer"`
+	ClientID              string   `json:"client_id"`
+	ClientSecret          string   `json:"client_secret"`
+	ClientSecretEnv       string   `json:"client_secret_env"`
+	RedirectURL           string   `json:"redirect_url"`
+	Scopes                []string `json:"scopes"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserInfoEndpoint      string   `json:"userinfo_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+}
+
+// TokenResponse is the token endpoint response of the authorization code grant
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// Identity is the provider-side account a user authenticated with
+type Identity struct {
+	Provider          string `json:"provider"`
+	Subject           string `json:"subject"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Name              string `json:"name,omitempty"`
+}
+
+// Provider runs the authorization code + PKCE flow against one identity provider
+type Provider struct {
+	cfg    ProviderConfig
+	client *http.Client
+
+	mu         sync.Mutex
+	discovered bool
+	keys       *keySet
+}
+
+// NewProvider creates a provider. A nil client uses a client with a 10 second timeout.
+func NewProvider(cfg ProviderConfig, client *http.Client) *Provider {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{cfg: cfg, client: client}
+}
+
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// RedirectURL returns the configured callback URL, or an empty string to let the caller decide
+func (p *Provider) RedirectURL() string {
+	return p.cfg.RedirectURL
+}
+
+// AuthCodeURL builds the URL the user agent is sent to for authorization
+func (p *Provider) AuthCodeURL(ctx context.Context, redirectURL, state, nonce, codeChallenge string) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.cfg.ClientID)
+	params.Set("redirect_uri", redirectURL)
+	params.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", codeChallenge)
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(p.cfg.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.cfg.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange trades an authorization code and its PKCE verifier for tokens
+func (p *Provider) Exchange(ctx context.Context, redirectURL, code, codeVerifier string) (*TokenResponse, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	if p.cfg.ClientSecret != "" {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tokens TokenResponse
+	if err := p.doJSON(req, &tokens); err != nil {
+		return nil, fmt.Errorf("token exchange: %w", err)
+	}
+	if tokens.AccessToken == "" && tokens.IDToken == "" {
+		return nil, errors.New("token exchange: no tokens returned")
+	}
+	return &tokens, nil
+}
+
+// Identity resolves who authenticated. The ID token is verified when present;
+// providers without one (plain OAuth2) are queried through the userinfo endpoint.
+func (p *Provider) Identity(ctx context.Context, tokens *TokenResponse, nonce string) (*Identity, error) {
+	var identity *Identity
+	var err error
+
+	if tokens.IDToken != "" {
+		identity, err = p.verifyIDToken(ctx, tokens.IDToken, nonce)
+	} else {
+		identity, err = p.userInfo(ctx, tokens.AccessToken)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if identity.Subject == "" {
+		return nil, ErrMissingSubject
+	}
+	identity.Provider = p.cfg.Name
+	return identity, nil
+}
+
+// NewPKCE returns a random code verifier and its S256 challenge
+func NewPKCE() (string, string, error) {
+	verifier, err := RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// RandomString returns n random bytes encoded as unpadded base64url
+func RandomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Helper functions
+
+type idTokenClaims struct {
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     flexBool `json:"email_verified"`
+	PreferredUsername string   `json:"preferred_username"`
+	Name              string   `json:"name"`
+	jwt.RegisteredClaims
+}
+
+func (p *Provider) verifyIDToken(ctx context.Context, rawToken, nonce string) (*Identity, error) {
+	keys, err := p.keySet(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "EdDSA"}),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	}
+	if p.cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(p.cfg.Issuer))
+	}
+
+	claims := &idTokenClaims{}
+	_, err = jwt.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return keys.lookup(ctx, kid)
+	}, options...)
+	if err != nil {
+		return nil, fmt.Errorf("id token: %w", err)
+	}
+	if claims.Nonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+
+	return &Identity{
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     bool(claims.EmailVerified),
+		PreferredUsername: claims.PreferredUsername,
+		Name:              claims.Name,
+	}, nil
+}
+
+func (p *Provider) userInfo(ctx context.Context, accessToken string) (*Identity, error) {
+	if p.cfg.UserInfoEndpoint == "" {
+		return nil, errors.New("provider returned no id token and has no userinfo endpoint")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.UserInfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	// "sub" is the OIDC claim; plain OAuth2 providers such as Discord use "id" and "verified"
+	var info struct {
+		Subject           string   `json:"sub"`
+		ID                string   `json:"id"`
+		Email             string   `json:"email"`
+		EmailVerified     flexBool `json:"email_verified"`
+		Verified          flexBool `json:"verified"`
+		PreferredUsername string   `json:"preferred_username"`
+		Username          string   `json:"username"`
+		Name              string   `json:"name"`
+	}
+	if err := p.doJSON(req, &info); err != nil {
+		return nil, fmt.Errorf("userinfo: %w", err)
+	}
+
+	identity := &Identity{
+		Subject:           info.Subject,
+		Email:             info.Email,
+		EmailVerified:     bool(info.EmailVerified) || bool(info.Verified),
+		PreferredUsername: info.PreferredUsername,
+		Name:              info.Name,
+	}
+	if identity.Subject == "" {
+		identity.Subject = info.ID
+	}
+	if identity.PreferredUsername == "" {
+		identity.PreferredUsername = info.Username
+	}
+	return identity, nil
+}
+
+// discover fills missing endpoints from the issuer's discovery document (once)
+func (p *Provider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovered {
+		return nil
+	}
+
+	if p.cfg.Issuer != "" && (p.cfg.AuthorizationEndpoint == "" || p.cfg.TokenEndpoint == "" || p.cfg.JWKSURI == "") {
+		discoveryURL := strings.TrimRight(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+		if err != nil {
+			return err
+		}
+
+		var doc struct {
+			Issuer                string `json:"issuer"`
+			AuthorizationEndpoint string `json:"authorization_endpoint"`
+			TokenEndpoint         string `json:"token_endpoint"`
+			UserInfoEndpoint      string `json:"userinfo_endpoint"`
+			JWKSURI               string `json:"jwks_uri"`
+		}
+		if err := p.doJSON(req, &doc); err != nil {
+			return fmt.Errorf("discovery: %w", err)
+		}
+		if strings.TrimRight(doc.Issuer, "/") != strings.TrimRight(p.cfg.Issuer, "/") {
+			return fmt.Errorf("discovery: issuer mismatch (%s)", doc.Issuer)
+		}
+
+		if p.cfg.AuthorizationEndpoint == "" {
+			p.cfg.AuthorizationEndpoint = doc.AuthorizationEndpoint
+		}
+		if p.cfg.TokenEndpoint == "" {
+			p.cfg.TokenEndpoint = doc.TokenEndpoint
+		}
+		if p.cfg.UserInfoEndpoint == "" {
+			p.cfg.UserInfoEndpoint = doc.UserInfoEndpoint
+		}
+		if p.cfg.JWKSURI == "" {
+			p.cfg.JWKSURI = doc.JWKSURI
+		}
+	}
+
+	if p.cfg.AuthorizationEndpoint == "" || p.cfg.TokenEndpoint == "" {
+		return fmt.Errorf("provider %s has no authorization or token endpoint", p.cfg.Name)
+	}
+
+	p.discovered = true
+	return nil
+}
+
+func (p *Provider) keySet(ctx context.Context) (*keySet, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cfg.JWKSURI == "" {
+		return nil, fmt.Errorf("provider %s has no jwks_uri", p.cfg.Name)
+	}
+	if p.keys == nil {
+		p.keys = newKeySet(p.cfg.JWKSURI, p.doJSON)
+	}
+	return p.keys, nil
+}
+
+func (p *Provider) doJSON(req *http.Request, out any) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s: status %d: %s", req.Method, req.URL.Redacted(), resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, out)
+}
+
+// flexBool accepts both JSON booleans and the "true"/"false" strings some providers send
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	default:
+		*b = false
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package oidc
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"TetriON.WebServer/server/internal/config"
+	"TetriON.WebServer/server/internal/logging"
+)
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]*Provider)
+)
+
+// Init registers every provider listed under the oidc_providers key of config.json
+func Init() {
+	raw := config.GetConfig(config.CONFIG_OIDC_PROVIDERS)
+	if raw == nil {
+		logging.LogInfo("No OIDC providers configured")
+		return
+	}
+
+	// config.json is decoded into generic maps; round-trip to get typed configs
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		logging.LogError("Invalid oidc_providers configuration: %v", err)
+		return
+	}
+	var configs []ProviderConfig
+	if err := json.Unmarshal(encoded, &configs); err != nil {
+		logging.LogError("Invalid oidc_providers configuration: %v", err)
+		return
+	}
+
+	for _, cfg := range configs {
+		cfg.Name = strings.ToLower(strings.TrimSpace(cfg.Name))
+		if cfg.Name == "" || cfg.ClientID == "" {
+			logging.LogWarning("Skipping OIDC provider without name or client_id")
+			continue
+		}
+		if cfg.ClientSecret == "" && cfg.ClientSecretEnv != "" {
+			cfg.ClientSecret = config.GetEnv(cfg.ClientSecretEnv)
+		}
+		Register(NewProvider(cfg, nil))
+		logging.LogInfo("Registered OIDC provider: %s", cfg.Name)
+	}
+}
+
+// Register adds or replaces a provider
+func Register(p *Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[p.Name()] = p
+}
+
+// Lookup returns the provider registered under name
+func Lookup(name string) (*Provider, error) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	if p, ok := providers[strings.ToLower(name)]; ok {
+		return p, nil
+	}
+	return nil, ErrUnknownProvider
+}
+
+// Names returns the registered provider names in alphabetical order
+func Names() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"TetriON.WebServer/server/internal/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hash algorithms
+const (
+	HashArgon2id = "argon2id"
+	HashBcrypt   = "bcrypt"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var (
+	ErrPasswordMismatch    = errors.New("password does not match")
+	ErrUnknownHashFormat   = errors.New("unknown password hash format")
+	ErrInvalidArgon2Format = errors.New("invalid argon2id hash")
+)
+
+// argon2Params are the tunable Argon2id parameters
+type argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// hashPassword hashes a password with the configured algorithm (password_hash_algorithm).
+// Argon2id hashes use the PHC string format: $argon2id$v=19$m=<KiB>,t=<iterations>,p=<lanes>$<salt>$<hash>
+func hashPassword(password string) (string, error) {
+	if configuredHashAlgorithm() == HashBcrypt {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), configuredBcryptCost())
+		if err != nil {
+			return "", err
+		}
+		return string(hashed), nil
+	}
+
+	params := configuredArgon2Params()
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// verifyPassword checks a password against a stored hash, dispatching on the hash format
+func verifyPassword(hashedPassword, password string) error {
+	switch hashAlgorithm(hashedPassword) {
+	case HashArgon2id:
+		params, salt, key, err := decodeArgon2Hash(hashedPassword)
+		if err != nil {
+			return err
+		}
+		candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(candidate, key) != 1 {
+			return ErrPasswordMismatch
+		}
+		return nil
+	case HashBcrypt:
+		if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)); err != nil {
+			return ErrPasswordMismatch
+		}
+		return nil
+	default:
+		return ErrUnknownHashFormat
+	}
+}
+
+// passwordNeedsRehash reports whether a hash was made with another algorithm or outdated parameters
+func passwordNeedsRehash(hashedPassword string) bool {
+	algorithm := hashAlgorithm(hashedPassword)
+	if algorithm != configuredHashAlgorithm() {
+		return true
+	}
+
+	switch algorithm {
+	case HashArgon2id:
+		params, _, key, err := decodeArgon2Hash(hashedPassword)
+		return err != nil || params != configuredArgon2Params() || len(key) != argon2KeyLength
+	case HashBcrypt:
+		cost, err := bcrypt.Cost([]byte(hashedPassword))
+		return err != nil || cost != configuredBcryptCost()
+	}
+	return true
+}
+
+func hashAlgorithm(hashedPassword string) string {
+	switch {
+	case strings.HasPrefix(hashedPassword, "$argon2id$"):
+		return HashArgon2id
+	case strings.HasPrefix(hashedPassword, "$2a$"), strings.HasPrefix(hashedPassword, "$2b$"), strings.HasPrefix(hashedPassword, "$2y$"):
+		return HashBcrypt
+	}
+	return ""
+}
+
+func decodeArgon2Hash(hashedPassword string) (argon2Params, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, hash
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 {
+		return argon2Params{}, nil, nil, ErrInvalidArgon2Format
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return argon2Params{}, nil, nil, ErrInvalidArgon2Format
+	}
+
+	var params argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return argon2Params{}, nil, nil, ErrInvalidArgon2Format
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return argon2Params{}, nil, nil, ErrInvalidArgon2Format
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return argon2Params{}, nil, nil, ErrInvalidArgon2Format
+	}
+	return params, salt, key, nil
+}
+
+// configuredHashAlgorithm returns password_hash_algorithm from config.json (default argon2id)
+func configuredHashAlgorithm() string {
+	if algorithm, ok := config.GetConfig(config.CONFIG_PASSWORD_HASH_ALGORITHM).(string); ok && strings.EqualFold(algorithm, HashBcrypt) {
+		return HashBcrypt
+	}
+	return HashArgon2id
+}
+
+// configuredArgon2Params reads argon2_memory_kib, argon2_iterations and argon2_parallelism
+// (defaults 64 MiB, 3, 2)
+func configuredArgon2Params() argon2Params {
+	return argon2Params{
+		Memory:      uint32(configInt(config.CONFIG_ARGON2_MEMORY_KIB, 64*1024, 8*1024, 4*1024*1024)),
+		Iterations:  uint32(configInt(config.CONFIG_ARGON2_ITERATIONS, 3, 1, 100)),
+		Parallelism: uint8(configInt(config.CONFIG_ARGON2_PARALLELISM, 2, 1, 255)),
+	}
+}
+
+// configuredBcryptCost returns bcrypt_cost from config.json (default bcrypt.DefaultCost)
+func configuredBcryptCost() int {
+	return configInt(config.CONFIG_BCRYPT_COST, bcrypt.DefaultCost, bcrypt.MinCost, bcrypt.MaxCost)
+}
+
+// configInt reads an integer config value, falling back to def when missing or out of range
+func configInt(key string, def, lowest, highest int) int {
+	value, err := strconv.Atoi(fmt.Sprint(config.GetConfig(key)))
+	if err != nil || value < lowest || value > highest {
+		return def
+	}
+	return value
+}
@@ -0,0 +1,312 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+
+	"TetriON.WebServer/server/internal/config"
+	"TetriON.WebServer/server/internal/logging"
+)
+
+// Password policy violation codes, returned to clients as "code"
+const (
+	PasswordTooShort         = "password_too_short"
+	PasswordTooLong          = "password_too_long"
+	PasswordTooWeak          = "password_too_weak"
+	PasswordContainsUsername = "password_contains_username"
+	PasswordContainsEmail    = "password_contains_email"
+	PasswordBreached         = "password_breached"
+)
+
+const (
+	defaultPasswordMinLength   = 8
+	passwordMaxLength          = 128
+	defaultPasswordMinEntropy  = 40
+	breachedCorpusMaxLineBytes = 128
+)
+
+// PasswordPolicyError names the password rule that was violated; it matches ErrWeakPassword with errors.Is
+type PasswordPolicyError struct {
+	Code    string `json:"code"`
+	Message string `json:"error"`
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return e.Message
+}
+
+func (e *PasswordPolicyError) Is(target error) bool {
+	return target == ErrWeakPassword
+}
+
+// PasswordContext is what a password rule may compare the password against
+type PasswordContext struct {
+	Username string
+	Email    string
+}
+
+// PasswordRule returns a *PasswordPolicyError when the password breaks the rule, nil otherwise
+type PasswordRule func(password string, account PasswordContext) error
+
+var (
+	passwordRulesMu sync.RWMutex
+	passwordRules   = []PasswordRule{
+		passwordLengthRule,
+		passwordPersonalInfoRule,
+		passwordEntropyRule,
+		passwordBreachedRule,
+	}
+)
+
+// AddPasswordRule appends a rule to the password policy. Rules run in order and the first
+// violation is reported.
+func AddPasswordRule(rule PasswordRule) {
+	passwordRulesMu.Lock()
+	defer passwordRulesMu.Unlock()
+	passwordRules = append(passwordRules, rule)
+}
+
+// validatePassword checks a new password against every rule of the password policy
+func validatePassword(password, username, email string) error {
+	passwordRulesMu.RLock()
+	rules := passwordRules
+	passwordRulesMu.RUnlock()
+
+	account := PasswordContext{Username: username, Email: email}
+	for _, rule := range rules {
+		if err := rule(password, account); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Built-in rules
+
+// passwordLengthRule enforces password_min_length (default 8) and a hard maximum so hashing stays cheap
+func passwordLengthRule(password string, _ PasswordContext) error {
+	minLength := configInt(config.CONFIG_PASSWORD_MIN_LENGTH, defaultPasswordMinLength, 6, passwordMaxLength)
+	length := len([]rune(password))
+	if length < minLength {
+		return &PasswordPolicyError{Code: PasswordTooShort, Message: fmt.Sprintf("password must be at least %d characters", minLength)}
+	}
+	if length > passwordMaxLength {
+		return &PasswordPolicyError{Code: PasswordTooLong, Message: fmt.Sprintf("password must be at most %d characters", passwordMaxLength)}
+	}
+	return nil
+}
+
+// passwordPersonalInfoRule rejects passwords containing the username, the email address or its local part
+func passwordPersonalInfoRule(password string, account PasswordContext) error {
+	lowered := strings.ToLower(password)
+
+	if username := strings.ToLower(account.Username); len(username) >= 3 && strings.Contains(lowered, username) {
+		return &PasswordPolicyError{Code: PasswordContainsUsername, Message: "password must not contain your username"}
+	}
+
+	email := strings.ToLower(account.Email)
+	local, _, _ := strings.Cut(email, "@")
+	if (email != "" && strings.Contains(lowered, email)) || (len(local) >= 3 && strings.Contains(lowered, local)) {
+		return &PasswordPolicyError{Code: PasswordContainsEmail, Message: "password must not contain your email address"}
+	}
+	return nil
+}
+
+// passwordEntropyRule requires an estimated password_min_entropy_bits (default 40)
+func passwordEntropyRule(password string, _ PasswordContext) error {
+	minEntropy := configInt(config.CONFIG_PASSWORD_MIN_ENTROPY_BITS, defaultPasswordMinEntropy, 0, 256)
+	if passwordEntropy(password) < float64(minEntropy) {
+		return &PasswordPolicyError{Code: PasswordTooWeak, Message: "password is too easy to guess, use a longer or more varied password"}
+	}
+	return nil
+}
+
+// passwordBreachedRule rejects passwords found in the breached password corpus, when one is configured
+func passwordBreachedRule(password string, _ PasswordContext) error {
+	corpus := loadBreachedCorpus()
+	if corpus == nil {
+		return nil
+	}
+
+	sum := sha1.Sum([]byte(password))
+	found, err := corpus.contains(strings.ToUpper(hex.EncodeToString(sum[:])))
+	if err != nil {
+		// A broken corpus should not lock everyone out of changing passwords
+		logging.LogError("Breached password lookup failed: %v", err)
+		return nil
+	}
+	if found {
+		return &PasswordPolicyError{Code: PasswordBreached, Message: "password has appeared in a data breach, choose another one"}
+	}
+	return nil
+}
+
+// passwordEntropy estimates the entropy in bits from the character classes used. Characters
+// that repeat or step by one from the previous character (aaaa, abcd, 4321) add only one bit.
+func passwordEntropy(password string) float64 {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r < unicode.MaxASCII && unicode.IsLower(r):
+			lower = true
+		case r < unicode.MaxASCII && unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	pool := 0
+	if lower {
+		pool += 26
+	}
+	if upper {
+		pool += 26
+	}
+	if digit {
+		pool += 10
+	}
+	if symbol {
+		pool += 33
+	}
+	if other {
+		pool += 100
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	bitsPerChar := math.Log2(float64(pool))
+	var bits float64
+	var previous rune
+	for i, r := range []rune(password) {
+		if diff := r - previous; i > 0 && diff >= -1 && diff <= 1 {
+			bits++
+		} else {
+			bits += bitsPerChar
+		}
+		previous = r
+	}
+	return bits
+}
+
+// Breached password corpus
+
+// breachedCorpus is a file of SHA-1 password hashes in hex, one per line and sorted, optionally
+// followed by ":<count>" (the format of the downloadable Pwned Passwords list). It is searched
+// on disk with a binary search, so even very large lists need no memory.
+type breachedCorpus struct {
+	file *os.File
+	size int64
+}
+
+var (
+	breachedCorpusOnce sync.Once
+	breachedPasswords  *breachedCorpus
+)
+
+// loadBreachedCorpus opens breached_passwords_file on first use; nil means the check is disabled
+func loadBreachedCorpus() *breachedCorpus {
+	breachedCorpusOnce.Do(func() {
+		path, _ := config.GetConfig(config.CONFIG_BREACHED_PASSWORDS_FILE).(string)
+		if path == "" {
+			return
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			logging.LogError("Breached password check disabled, cannot open %s: %v", path, err)
+			return
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			logging.LogError("Breached password check disabled, cannot read %s: %v", path, err)
+			return
+		}
+		breachedPasswords = &breachedCorpus{file: file, size: info.Size()}
+		logging.LogInfo("Breached password corpus loaded from %s (%d bytes)", path, info.Size())
+	})
+	return breachedPasswords
+}
+
+var errMalformedCorpus = errors.New("breached password corpus has an overlong line")
+
+// contains binary searches the sorted corpus for an upper-case hex SHA-1 hash
+func (c *breachedCorpus) contains(hash string) (bool, error) {
+	// lo and hi are always offsets where a line starts
+	lo, hi := int64(0), c.size
+	for lo < hi {
+		start, err := c.lineStart(lo, lo+(hi-lo)/2)
+		if err != nil {
+			return false, err
+		}
+		line, end, err := c.lineAt(start)
+		if err != nil {
+			return false, err
+		}
+
+		key, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+		switch key = strings.ToUpper(key); {
+		case key == hash:
+			return true, nil
+		case hash < key:
+			hi = start
+		default:
+			lo = end
+		}
+	}
+	return false, nil
+}
+
+// lineStart returns the start of the line containing offset, which is no earlier than lo
+func (c *breachedCorpus) lineStart(lo, offset int64) (int64, error) {
+	n := offset - lo
+	if n > breachedCorpusMaxLineBytes {
+		n = breachedCorpusMaxLineBytes
+	}
+	if n == 0 {
+		return offset, nil
+	}
+
+	buf := make([]byte, n)
+	if _, err := c.file.ReadAt(buf, offset-n); err != nil && err != io.EOF {
+		return 0, err
+	}
+	if i := bytes.LastIndexByte(buf, '\n'); i >= 0 {
+		return offset - n + int64(i) + 1, nil
+	}
+	if offset-n == lo {
+		return lo, nil
+	}
+	return 0, errMalformedCorpus
+}
+
+// lineAt reads the line starting at start and returns it with the offset of the next line
+func (c *breachedCorpus) lineAt(start int64) (string, int64, error) {
+	buf := make([]byte, breachedCorpusMaxLineBytes)
+	n, err := c.file.ReadAt(buf, start)
+	if err != nil && err != io.EOF {
+		return "", 0, err
+	}
+	buf = buf[:n]
+	if i := bytes.IndexByte(buf, '\n'); i >= 0 {
+		return string(buf[:i]), start + int64(i) + 1, nil
+	}
+	if start+int64(n) == c.size {
+		return string(buf), c.size, nil
+	}
+	return "", 0, errMalformedCorpus
+}
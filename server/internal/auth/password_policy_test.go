@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		username string
+		email    string
+		wantCode string // empty when the password is accepted
+	}{
+		{"strong password", "Tr0ub4dor&3x", "alice", "alice@example.com", ""},
+		{"long passphrase", "correct horse battery staple", "alice", "", ""},
+		{"too short", "aB3$xY", "alice", "", PasswordTooShort},
+		{"length counts runes", "ÄöÜßéèñ", "alice", "", PasswordTooShort},
+		{"too long", strings.Repeat("aB3$", 33), "alice", "", PasswordTooLong},
+		{"contains username", "xX9!Alice#Qz", "alice", "", PasswordContainsUsername},
+		{"short usernames are not matched", "xX9!al#Qzw7", "al", "", ""},
+		{"contains email", "Zq8!bob.smith@example.com", "player1", "bob.smith@example.com", PasswordContainsEmail},
+		{"contains email local part", "Zq8!bob.smith#", "player1", "bob.smith@example.com", PasswordContainsEmail},
+		{"single class", "password", "alice", "", PasswordTooWeak},
+		{"sequences add little", "abcdefghijklmnop", "alice", "", PasswordTooWeak},
+		{"repeats add little", "aaaaaaaaaaaaaaaa", "alice", "", PasswordTooWeak},
+		{"descending digits", "9876543210", "alice", "", PasswordTooWeak},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePassword(tt.password, tt.username, tt.email)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatalf("validatePassword(%q) = %v, want nil", tt.password, err)
+				}
+				return
+			}
+
+			var policyErr *PasswordPolicyError
+			if !errors.As(err, &policyErr) {
+				t.Fatalf("validatePassword(%q) = %v, want a *PasswordPolicyError", tt.password, err)
+			}
+			if policyErr.Code != tt.wantCode {
+				t.Errorf("validatePassword(%q) code = %s, want %s", tt.password, policyErr.Code, tt.wantCode)
+			}
+			if !errors.Is(err, ErrWeakPassword) {
+				t.Errorf("validatePassword(%q) does not match ErrWeakPassword", tt.password)
+			}
+		})
+	}
+}
+
+func TestBreachedCorpusContains(t *testing.T) {
+	breached := []string{"password", "123456", "qwerty", "letmein", "Tr0ub4dor&3"}
+	lines := make([]string, 0, len(breached))
+	for i, password := range breached {
+		sum := sha1.Sum([]byte(password))
+		line := strings.ToUpper(hex.EncodeToString(sum[:]))
+		if i%2 == 0 {
+			line += ":42"
+		}
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	info, _ := file.Stat()
+	corpus := &breachedCorpus{file: file, size: info.Size()}
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"password", true},
+		{"123456", true},
+		{"qwerty", true},
+		{"letmein", true},
+		{"Tr0ub4dor&3", true},
+		{"correct horse battery staple", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		sum := sha1.Sum([]byte(tt.password))
+		got, err := corpus.contains(strings.ToUpper(hex.EncodeToString(sum[:])))
+		if err != nil {
+			t.Fatalf("contains(%q): %v", tt.password, err)
+		}
+		if got != tt.want {
+			t.Errorf("contains(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
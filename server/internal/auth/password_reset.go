@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"TetriON.WebServer/server/internal/config"
+	"TetriON.WebServer/server/internal/db"
+	"TetriON.WebServer/server/internal/mail"
+	redisnet "TetriON.WebServer/server/internal/net/redis"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+const (
+	passwordResetTokenTTL = time.Hour
+	passwordResetCooldown = time.Minute
+	// passwordResetPagePath serves a reset form when PASSWORD_RESET_URL names no client page
+	passwordResetPagePath = "/api/auth/password/reset"
+)
+
+// resetPasswordPage is the form served at passwordResetPagePath. It reads the token from the
+// query string in the browser and posts it with the new password to the same path.
+const resetPasswordPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Reset your TetriON password</title>
+</head>
+<body>
+<h1>Reset your TetriON password</h1>
+<form id="reset">
+<label>New password <input type="password" id="password" autocomplete="new-password" required></label>
+<button type="submit">Reset password</button>
+</form>
+<p id="result" role="status"></p>
+<script>
+document.getElementById("reset").addEventListener("submit", async (event) => {
+	event.preventDefault();
+	const token = new URLSearchParams(location.search).get("token") || "";
+	const password = document.getElementById("password").value;
+	const response = await fetch(location.pathname, {
+		method: "POST",
+		headers: { "Content-Type": "application/json" },
+		body: JSON.stringify({ token, password }),
+	});
+	const body = await response.json().catch(() => ({}));
+	document.getElementById("result").textContent = response.ok
+		? "Your password has been reset. You can log in now."
+		: (body.error || "The password could not be reset.");
+});
+</script>
+</body>
+</html>
+`
+
+// RequestPasswordReset emails a reset link when the address belongs to an account.
+// Unknown addresses and throttled requests are silently ignored so callers cannot enumerate accounts.
+func RequestPasswordReset(email string) error {
+	user, err := GetUserByEmail(strings.TrimSpace(email))
+	if err != nil {
+		if err == ErrUserNotFound {
+			return nil
+		}
+		return err
+	}
+
+	acquired, _, err := redisnet.AcquireCooldown(context.Background(), "password_reset", user.ID, passwordResetCooldown)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		return nil
+	}
+
+	if db.DB == nil {
+		return ErrDatabaseError
+	}
+
+	rawToken, tokenHash, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	if _, err := db.DB.Exec(ctx, `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, user.ID, tokenHash, now.Add(passwordResetTokenTTL), now); err != nil {
+		return err
+	}
+
+	return mail.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your TetriON password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your TetriON account. Open the link below to choose a new one:\n\n%s\n\nThe link expires in %d minutes and can only be used once. If you did not request this you can ignore this email.\n",
+			user.Username, passwordResetLink(rawToken), int(passwordResetTokenTTL.Minutes())),
+	})
+}
+
+// passwordResetLink points at the client page named by PASSWORD_RESET_URL, or at the form
+// this server serves when none is configured
+func passwordResetLink(rawToken string) string {
+	base := config.GetEnv(config.ENV_PASSWORD_RESET_URL)
+	if base == "" {
+		base = mail.PublicURL(passwordResetPagePath)
+	}
+	separator := "?"
+	if strings.Contains(base, "?") {
+		separator = "&"
+	}
+	return base + separator + "token=" + url.QueryEscape(rawToken)
+}
+
+// ResetPassword consumes a reset token, stores the new password and revokes every session of the user.
+// It returns the ID of the user whose password was reset.
+func ResetPassword(rawToken, newPassword string) (string, error) {
+	if db.DB == nil {
+		return "", ErrDatabaseError
+	}
+	if rawToken == "" {
+		return "", ErrInvalidResetToken
+	}
+
+	// Look the account up first so the policy can compare against its username and email;
+	// the token is only consumed once the new password is acceptable
+	ctx := context.Background()
+	now := time.Now()
+	var username, email string
+	err := db.DB.QueryRow(ctx, `
+		SELECT u.username, u.email
+		FROM password_reset_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = $1 AND t.used_at IS NULL AND t.expires_at > $2
+	`, hashOpaqueToken(rawToken), now).Scan(&username, &email)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", ErrInvalidResetToken
+		}
+		return "", err
+	}
+	if err := validatePassword(newPassword, username, email); err != nil {
+		return "", err
+	}
+
+	hashedPassword, err := hashPassword(newPassword)
+	if err != nil {
+		return "", errors.New("failed to hash password")
+	}
+
+	// Consuming the token and storing the password succeed or fail together
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	var userID string
+	err = tx.QueryRow(ctx, `
+		UPDATE password_reset_tokens
+		SET used_at = $1
+		WHERE token_hash = $2 AND used_at IS NULL AND expires_at > $1
+		RETURNING user_id
+	`, now, hashOpaqueToken(rawToken)).Scan(&userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", ErrInvalidResetToken
+		}
+		return "", err
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE users
+		SET password_hash = $1, updated_at = $2
+		WHERE id = $3
+	`, hashedPassword, now, userID)
+	if err != nil {
+		return "", err
+	}
+	if tag.RowsAffected() == 0 {
+		return "", ErrUserNotFound
+	}
+
+	// Any other outstanding link for this account is void now
+	if _, err := tx.Exec(ctx, `
+		UPDATE password_reset_tokens
+		SET used_at = $1
+		WHERE user_id = $2 AND used_at IS NULL
+	`, now, userID); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", err
+	}
+
+	return userID, RevokeAllSessions(userID)
+}
@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"TetriON.WebServer/server/internal/db"
+	redisnet "TetriON.WebServer/server/internal/net/redis"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// TokenPair holds the credentials handed to a client after authentication
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64
+	SessionID    string
+}
+
+// IssueSession starts a new refresh token family for the user and returns its first token pair
+func IssueSession(user *User) (*TokenPair, error) {
+	if db.DB == nil {
+		return nil, ErrDatabaseError
+	}
+
+	rawToken, tokenHash, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// Guest sessions never outlive the guest account
+	query := `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at)
+		VALUES ($1, uuid_generate_v4(), $2, LEAST($3, (SELECT guest_expires_at FROM users WHERE id = $1)), $4)
+		RETURNING family_id
+	`
+
+	now := time.Now()
+	var familyID string
+	ctx := context.Background()
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := tx.QueryRow(ctx, query, user.ID, tokenHash, now.Add(refreshTokenTTL()), now).Scan(&familyID); err != nil {
+		return nil, err
+	}
+
+	// The refresh token family doubles as the session shown in the sessions API
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO user_sessions (id, user_id, created_at, last_used_at)
+		VALUES ($1, $2, $3, $3)
+	`, familyID, user.ID, now); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return newTokenPair(user, familyID, rawToken)
+}
+
+// RefreshSession exchanges a refresh token for a new token pair, rotating the refresh token.
+// Presenting a token that was already exchanged or revoked revokes its whole family. Users with
+// a login ban get a *BanError and lose the family, even one started just before the ban.
+func RefreshSession(rawToken string) (*User, *TokenPair, error) {
+	if db.DB == nil {
+		return nil, nil, ErrDatabaseError
+	}
+	if rawToken == "" {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	ctx := context.Background()
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		SELECT user_id, family_id, expires_at, used_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`
+
+	var (
+		userID, familyID  string
+		expiresAt         time.Time
+		usedAt, revokedAt *time.Time
+	)
+	err = tx.QueryRow(ctx, query, hashOpaqueToken(rawToken)).Scan(&userID, &familyID, &expiresAt, &usedAt, &revokedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil, ErrInvalidRefreshToken
+		}
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	banErr := checkBan(ctx, tx, userID, BanScopeLogin)
+	var ban *BanError
+	if banErr != nil && !errors.As(banErr, &ban) {
+		return nil, nil, banErr
+	}
+	if ban != nil || usedAt != nil || revokedAt != nil {
+		if _, err := tx.Exec(ctx, `
+			UPDATE refresh_tokens
+			SET revoked_at = $1
+			WHERE family_id = $2 AND revoked_at IS NULL
+		`, now, familyID); err != nil {
+			return nil, nil, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, nil, err
+		}
+		if err := redisnet.RevokeSession(ctx, familyID, accessTokenTTL()); err != nil {
+			return nil, nil, err
+		}
+		notifySessionRevoked(userID, familyID)
+		if ban != nil {
+			return nil, nil, ban
+		}
+		return nil, nil, ErrRefreshTokenReused
+	}
+	if expiresAt.Before(now) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE refresh_tokens SET used_at = $1 WHERE token_hash = $2`, now, hashOpaqueToken(rawToken)); err != nil {
+		return nil, nil, err
+	}
+
+	newRawToken, newTokenHash, err := generateOpaqueToken()
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, LEAST($4, (SELECT guest_expires_at FROM users WHERE id = $1)), $5)
+	`, userID, familyID, newTokenHash, now.Add(refreshTokenTTL()), now); err != nil {
+		return nil, nil, err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE user_sessions SET last_used_at = $1 WHERE id = $2`, now, familyID); err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pair, err := newTokenPair(user, familyID, newRawToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, pair, nil
+}
+
+// Helper functions
+
+func newTokenPair(user *User, sessionID, refreshToken string) (*TokenPair, error) {
+	accessToken, err := GenerateToken(user, sessionID)
+	if err != nil {
+		return nil, errors.New("failed to generate token")
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(accessTokenTTL().Seconds()),
+		SessionID:    sessionID,
+	}, nil
+}
+
+// refreshTokenTTL returns the lifetime of refresh tokens (REFRESH_TOKEN_TTL_HOURS, default 720)
+func refreshTokenTTL() time.Duration {
+	expirationHours := 720
+	if hours := os.Getenv("REFRESH_TOKEN_TTL_HOURS"); hours != "" {
+		if h, err := strconv.Atoi(hours); err == nil && h > 0 {
+			expirationHours = h
+		}
+	}
+	return time.Hour * time.Duration(expirationHours)
+}
+
+// generateOpaqueToken returns a random URL-safe token and the hash that should be persisted
+func generateOpaqueToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashOpaqueToken(token), nil
+}
+
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
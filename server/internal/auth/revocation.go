@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"TetriON.WebServer/server/internal/db"
+	"TetriON.WebServer/server/internal/logging"
+	redisnet "TetriON.WebServer/server/internal/net/redis"
+)
+
+var (
+	sessionHooksMu      sync.RWMutex
+	sessionRevokedHooks []func(userID, sessionID string)
+)
+
+// OnSessionRevoked registers a callback invoked whenever a session is revoked.
+// An empty sessionID means every session of the user was revoked.
+func OnSessionRevoked(fn func(userID, sessionID string)) {
+	sessionHooksMu.Lock()
+	defer sessionHooksMu.Unlock()
+	sessionRevokedHooks = append(sessionRevokedHooks, fn)
+}
+
+// Logout revokes the presented access token and the session it belongs to
+func Logout(claims *Claims) error {
+	ctx := context.Background()
+
+	if claims.ID != "" && claims.ExpiresAt != nil {
+		if err := redisnet.RevokeToken(ctx, claims.ID, time.Until(claims.ExpiresAt.Time)); err != nil {
+			return err
+		}
+	}
+
+	if claims.SessionID != "" {
+		if err := revokeRefreshFamily(ctx, claims.SessionID); err != nil {
+			return err
+		}
+		if err := redisnet.RevokeSession(ctx, claims.SessionID, accessTokenTTL()); err != nil {
+			return err
+		}
+	}
+
+	notifySessionRevoked(claims.UserID, claims.SessionID)
+	return nil
+}
+
+// RevokeAllSessions revokes every refresh token and outstanding access token of the user
+func RevokeAllSessions(userID string) error {
+	if db.DB == nil {
+		return ErrDatabaseError
+	}
+
+	ctx := context.Background()
+	// Taken before revoking, so every token issued so far falls before the cutoff
+	now := time.Now()
+	if _, err := db.DB.Exec(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = $1
+		WHERE user_id = $2 AND revoked_at IS NULL
+	`, now, userID); err != nil {
+		return err
+	}
+
+	if err := redisnet.RevokeUserTokensBefore(ctx, userID, now, accessTokenTTL()); err != nil {
+		return err
+	}
+
+	notifySessionRevoked(userID, "")
+	return nil
+}
+
+// Helper functions
+
+func checkRevocation(claims *Claims) error {
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+
+	revoked, err := redisnet.IsTokenRevoked(context.Background(), claims.ID, claims.SessionID, claims.UserID, issuedAt)
+	if err != nil {
+		logging.LogError("Failed to check token revocation: %v", err)
+		return ErrInvalidToken
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+func revokeRefreshFamily(ctx context.Context, familyID string) error {
+	if db.DB == nil {
+		return ErrDatabaseError
+	}
+
+	_, err := db.DB.Exec(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = $1
+		WHERE family_id = $2 AND revoked_at IS NULL
+	`, time.Now(), familyID)
+	return err
+}
+
+func notifySessionRevoked(userID, sessionID string) {
+	sessionHooksMu.RLock()
+	hooks := append([]func(string, string){}, sessionRevokedHooks...)
+	sessionHooksMu.RUnlock()
+
+	for _, fn := range hooks {
+		fn(userID, sessionID)
+	}
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"TetriON.WebServer/server/internal/db"
+)
+
+// User roles, from least to most privileged
+const (
+	RolePlayer    = "player"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var ErrInvalidRole = errors.New("role must be player, moderator or admin")
+
+var roleRank = map[string]int{
+	RolePlayer:    0,
+	RoleModerator: 1,
+	RoleAdmin:     2,
+}
+
+// ValidRole reports whether role is a known role
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// GetUserRole reads the current role of an active user from the database.
+// Used to re-check roles carried in access tokens before sensitive actions.
+func GetUserRole(userID string) (string, error) {
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return "", err
+	}
+	if user.DeletedAt != nil {
+		return "", ErrUserNotFound
+	}
+	return user.Role, nil
+}
+
+// SetUserRole changes the role of a user; actorID is the admin making the change, or empty
+// from the console. A demotion revokes every session so the old role cannot be used from
+// existing access tokens.
+func SetUserRole(userID, role, actorID string) (*User, error) {
+	if db.DB == nil {
+		return nil, ErrDatabaseError
+	}
+	if !ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.Role == role {
+		return user, nil
+	}
+
+	if _, err := db.DB.Exec(context.Background(), `
+		UPDATE users
+		SET role = $1, updated_at = $2
+		WHERE id = $3
+	`, role, time.Now(), user.ID); err != nil {
+		return nil, err
+	}
+
+	recordAudit(AuditEvent{
+		Type:      AuditRoleChanged,
+		ActorID:   actorID,
+		SubjectID: user.ID,
+		Metadata:  map[string]any{"from": user.Role, "to": role},
+	})
+
+	demoted := roleRank[role] < roleRank[user.Role]
+	user.Role = role
+	if demoted {
+		if err := RevokeAllSessions(user.ID); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
@@ -5,12 +5,12 @@ import (
 	"regexp"
 	"strings"
 
-	"golang.org/x/crypto/bcrypt"
+	"TetriON.WebServer/server/internal/logging"
 )
 
 var (
 	ErrInvalidCredentials = errors.New("invalid username or password")
-	ErrWeakPassword       = errors.New("password must be at least 8 characters")
+	ErrWeakPassword       = errors.New("password does not meet the password policy")
 	ErrInvalidUsername    = errors.New("username must be 3-50 characters and alphanumeric")
 	ErrInvalidEmail       = errors.New("invalid email format")
 )
@@ -18,31 +18,31 @@ var (
 var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 var usernameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{3,50}$`)
 
-// Register creates a new user account
-func Register(username, email, password string) (*User, string, error) {
+// Register creates a new user account and starts its first session
+func Register(username, email, password string) (*User, *TokenPair, error) {
 	// Validate input
 	if err := validateUsername(username); err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 	if err := validateEmail(email); err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
-	if err := validatePassword(password); err != nil {
-		return nil, "", err
+	if err := validatePassword(password, username, email); err != nil {
+		return nil, nil, err
 	}
 
 	// Check if user already exists
 	if existingUser, _ := GetUserByUsername(username); existingUser != nil {
-		return nil, "", errors.New("username already taken")
+		return nil, nil, errors.New("username already taken")
 	}
 	if existingUser, _ := GetUserByEmail(email); existingUser != nil {
-		return nil, "", errors.New("email already registered")
+		return nil, nil, errors.New("email already registered")
 	}
 
 	// Hash password
 	hashedPassword, err := hashPassword(password)
 	if err != nil {
-		return nil, "", errors.New("failed to hash password")
+		return nil, nil, errors.New("failed to hash password")
 	}
 
 	// Create user
@@ -53,41 +53,68 @@ func Register(username, email, password string) (*User, string, error) {
 	}
 
 	if err := CreateUser(user); err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	// Generate token
-	token, err := GenerateToken(user.ID, user.Username, user.Email)
+	// Send the verification link without holding up the response
+	go func(u User) {
+		if err := SendVerificationEmail(&u); err != nil {
+			logging.LogError("Failed to send verification email to %s: %v", u.Username, err)
+		}
+	}(*user)
+
+	// Issue tokens
+	tokens, err := IssueSession(user)
 	if err != nil {
-		return nil, "", errors.New("failed to generate token")
+		return nil, nil, errors.New("failed to generate token")
 	}
 
-	return user, token, nil
+	return user, tokens, nil
 }
 
-// Login authenticates a user and returns a token
-func Login(username, password string) (*User, string, error) {
+// Login authenticates a user and starts a new session
+func Login(username, password string) (*User, *TokenPair, error) {
 	// Get user from database
 	user, err := GetUserByUsername(username)
 	if err != nil {
 		if err == ErrUserNotFound {
-			return nil, "", ErrInvalidCredentials
+			return nil, nil, ErrInvalidCredentials
 		}
-		return nil, "", err
+		return nil, nil, err
+	}
+
+	// Anonymized accounts can never log in again
+	if user.DeletedAt != nil {
+		return nil, nil, ErrInvalidCredentials
 	}
 
 	// Verify password
 	if err := verifyPassword(user.PasswordHash, password); err != nil {
-		return nil, "", ErrInvalidCredentials
+		return nil, nil, ErrInvalidCredentials
+	}
+
+	// Banned users are only told so once they proved they own the account; the user is
+	// returned with the *BanError so callers can attribute the rejection
+	if err := CheckBan(user.ID, BanScopeLogin); err != nil {
+		return user, nil, err
+	}
+
+	// Upgrade hashes made with an older algorithm or parameters while the plain password is at hand
+	if passwordNeedsRehash(user.PasswordHash) {
+		rehashPassword(user, password)
 	}
 
-	// Generate token
-	token, err := GenerateToken(user.ID, user.Username, user.Email)
+	// Accounts with 2FA finish logging in through CompleteMFALogin
+	if user.TOTPEnabled {
+		return user, nil, ErrMFARequired
+	}
+
+	tokens, err := startSession(user)
 	if err != nil {
-		return nil, "", errors.New("failed to generate token")
+		return nil, nil, err
 	}
 
-	return user, token, nil
+	return user, tokens, nil
 }
 
 // ValidateUser checks if a user exists and returns their information
@@ -101,31 +128,66 @@ func ValidateUser(userID string) (*User, error) {
 
 // ValidateToken validates a token and returns the associated user
 func ValidateToken(tokenString string) (*User, error) {
+	user, _, err := AuthenticateToken(tokenString)
+	return user, err
+}
+
+// AuthenticateToken validates a token, rejects revoked ones and returns the user with the token claims
+func AuthenticateToken(tokenString string) (*User, *Claims, error) {
 	claims, err := VerifyToken(tokenString)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	if err := checkRevocation(claims); err != nil {
+		return nil, nil, err
 	}
 
 	user, err := GetUserByID(claims.UserID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return user, nil
+	if err := CheckBan(user.ID, BanScopeLogin); err != nil {
+		return nil, nil, err
+	}
+
+	return user, claims, nil
 }
 
 // Helper functions
 
-func hashPassword(password string) (string, error) {
-	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+// startSession finishes a successful login: it cancels a pending deletion and issues tokens
+func startSession(user *User) (*TokenPair, error) {
+	// Logging in during the grace period cancels a pending deletion
+	if user.DeletionScheduledAt != nil {
+		if err := CancelAccountDeletion(user.ID); err != nil {
+			return nil, err
+		}
+		user.DeletionScheduledAt = nil
+		logging.LogInfo("Pending account deletion cancelled by login: %s", user.Username)
+	}
+
+	tokens, err := IssueSession(user)
 	if err != nil {
-		return "", err
+		return nil, errors.New("failed to generate token")
 	}
-	return string(hashed), nil
+	return tokens, nil
 }
 
-func verifyPassword(hashedPassword, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+// rehashPassword stores a new hash of the password; failures are logged and do not affect the login
+func rehashPassword(user *User, password string) {
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		logging.LogError("Failed to rehash password for user %s: %v", user.Username, err)
+		return
+	}
+	if err := UpdatePasswordHash(user.ID, hashedPassword); err != nil {
+		logging.LogError("Failed to store rehashed password for user %s: %v", user.Username, err)
+		return
+	}
+	user.PasswordHash = hashedPassword
+	logging.LogInfo("Upgraded password hash for user %s", user.Username)
 }
 
 func validateUsername(username string) error {
@@ -143,10 +205,3 @@ func validateEmail(email string) error {
 	}
 	return nil
 }
-
-func validatePassword(password string) error {
-	if len(password) < 8 {
-		return ErrWeakPassword
-	}
-	return nil
-}
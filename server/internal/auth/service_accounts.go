@@ -0,0 +1,385 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"TetriON.WebServer/server/internal/db"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Scopes that can be granted to service accounts
+const (
+	ScopeRegistryWrite        = "registry:write"
+	ScopeMatchesReport        = "matches:report"
+	ScopeServiceAccountsAdmin = "service_accounts:admin"
+)
+
+// APIKeyPrefix marks service API keys so they can be told apart from JWTs
+const APIKeyPrefix = "tsk_"
+
+var knownScopes = map[string]bool{
+	ScopeRegistryWrite:        true,
+	ScopeMatchesReport:        true,
+	ScopeServiceAccountsAdmin: true,
+}
+
+var (
+	ErrServiceAccountNotFound = errors.New("service account not found")
+	ErrServiceAccountExists   = errors.New("service account name already exists")
+	ErrAPIKeyNotFound         = errors.New("api key not found")
+	ErrInvalidAPIKey          = errors.New("invalid or revoked api key")
+	ErrInvalidScope           = errors.New("unknown scope")
+	ErrInvalidServiceName     = errors.New("service account name must be 3-100 characters")
+)
+
+// ServiceAccount is a non-human principal, such as a dedicated game server fleet
+type ServiceAccount struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Scopes      []string   `json:"scopes"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DisabledAt  *time.Time `json:"disabled_at,omitempty"`
+	Keys        []APIKey   `json:"keys,omitempty"`
+}
+
+// APIKey is a credential of a service account; only its hash is stored
+type APIKey struct {
+	ID         string     `json:"id"`
+	Prefix     string     `json:"prefix"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ServicePrincipal is the identity resolved from a valid API key
+type ServicePrincipal struct {
+	AccountID string
+	Name      string
+	KeyID     string
+	Scopes    []string
+}
+
+// IsAPIKey reports whether a credential looks like a service API key rather than a JWT
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// CreateServiceAccount creates a service account with the given scopes and its first API key.
+// The raw key is returned once and cannot be recovered later.
+func CreateServiceAccount(name, description string, scopes []string) (*ServiceAccount, string, error) {
+	if db.DB == nil {
+		return nil, "", ErrDatabaseError
+	}
+
+	name = strings.TrimSpace(name)
+	if len(name) < 3 || len(name) > 100 {
+		return nil, "", ErrInvalidServiceName
+	}
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ctx := context.Background()
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback(ctx)
+
+	account := &ServiceAccount{
+		Name:        name,
+		Description: description,
+		Scopes:      scopes,
+		CreatedAt:   time.Now(),
+	}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO service_accounts (name, description, scopes, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, account.Name, account.Description, account.Scopes, account.CreatedAt).Scan(&account.ID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, "", ErrServiceAccountExists
+		}
+		return nil, "", err
+	}
+
+	raw, key, err := insertAPIKey(ctx, tx, account.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	account.Keys = []APIKey{*key}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, "", err
+	}
+	return account, raw, nil
+}
+
+// CreateAPIKey issues an additional key for an active service account, e.g. to rotate keys
+func CreateAPIKey(accountID string) (string, *APIKey, error) {
+	if db.DB == nil {
+		return "", nil, ErrDatabaseError
+	}
+
+	account, err := GetServiceAccount(accountID)
+	if err != nil {
+		return "", nil, err
+	}
+	if account.DisabledAt != nil {
+		return "", nil, ErrServiceAccountNotFound
+	}
+
+	ctx := context.Background()
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	raw, key, err := insertAPIKey(ctx, tx, account.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return "", nil, err
+	}
+	return raw, key, nil
+}
+
+// GetServiceAccount returns a service account together with its keys
+func GetServiceAccount(accountID string) (*ServiceAccount, error) {
+	if db.DB == nil {
+		return nil, ErrDatabaseError
+	}
+
+	account := &ServiceAccount{}
+	var description *string
+	err := db.DB.QueryRow(context.Background(), `
+		SELECT id, name, description, scopes, created_at, disabled_at
+		FROM service_accounts
+		WHERE id = $1
+	`, accountID).Scan(&account.ID, &account.Name, &description, &account.Scopes, &account.CreatedAt, &account.DisabledAt)
+	if err == pgx.ErrNoRows {
+		return nil, ErrServiceAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if description != nil {
+		account.Description = *description
+	}
+
+	if account.Keys, err = listAPIKeys(account.ID); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// ListServiceAccounts returns every service account with its keys, oldest first
+func ListServiceAccounts() ([]ServiceAccount, error) {
+	if db.DB == nil {
+		return nil, ErrDatabaseError
+	}
+
+	rows, err := db.DB.Query(context.Background(), `
+		SELECT id
+		FROM service_accounts
+		ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	accounts := make([]ServiceAccount, 0, len(ids))
+	for _, id := range ids {
+		account, err := GetServiceAccount(id)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *account)
+	}
+	return accounts, nil
+}
+
+// RevokeAPIKey revokes a single key of a service account
+func RevokeAPIKey(accountID, keyID string) error {
+	if db.DB == nil {
+		return ErrDatabaseError
+	}
+
+	tag, err := db.DB.Exec(context.Background(), `
+		UPDATE service_api_keys
+		SET revoked_at = $1
+		WHERE id = $2 AND service_account_id = $3 AND revoked_at IS NULL
+	`, time.Now(), keyID, accountID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// DisableServiceAccount disables a service account and revokes all of its keys
+func DisableServiceAccount(accountID string) error {
+	if db.DB == nil {
+		return ErrDatabaseError
+	}
+
+	ctx := context.Background()
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	tag, err := tx.Exec(ctx, `
+		UPDATE service_accounts
+		SET disabled_at = $1
+		WHERE id = $2 AND disabled_at IS NULL
+	`, now, accountID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrServiceAccountNotFound
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE service_api_keys
+		SET revoked_at = $1
+		WHERE service_account_id = $2 AND revoked_at IS NULL
+	`, now, accountID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// AuthenticateAPIKey resolves a raw API key to its service principal
+func AuthenticateAPIKey(raw string) (*ServicePrincipal, error) {
+	if db.DB == nil {
+		return nil, ErrDatabaseError
+	}
+	if !IsAPIKey(raw) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	ctx := context.Background()
+	principal := &ServicePrincipal{}
+	err := db.DB.QueryRow(ctx, `
+		SELECT k.id, a.id, a.name, a.scopes
+		FROM service_api_keys k
+		JOIN service_accounts a ON a.id = k.service_account_id
+		WHERE k.key_hash = $1 AND k.revoked_at IS NULL AND a.disabled_at IS NULL
+	`, hashOpaqueToken(raw)).Scan(&principal.KeyID, &principal.AccountID, &principal.Name, &principal.Scopes)
+	if err == pgx.ErrNoRows {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Only record usage once a minute to avoid a write on every request
+	now := time.Now()
+	if _, err := db.DB.Exec(ctx, `
+		UPDATE service_api_keys
+		SET last_used_at = $1
+		WHERE id = $2 AND (last_used_at IS NULL OR last_used_at < $3)
+	`, now, principal.KeyID, now.Add(-time.Minute)); err != nil {
+		return nil, err
+	}
+
+	return principal, nil
+}
+
+// Helper functions
+
+func insertAPIKey(ctx context.Context, tx pgx.Tx, accountID string) (string, *APIKey, error) {
+	secret, _, err := generateOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+	raw := APIKeyPrefix + secret
+
+	key := &APIKey{
+		Prefix:    raw[:len(APIKeyPrefix)+8],
+		CreatedAt: time.Now(),
+	}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO service_api_keys (service_account_id, prefix, key_hash, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, accountID, key.Prefix, hashOpaqueToken(raw), key.CreatedAt).Scan(&key.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	return raw, key, nil
+}
+
+func listAPIKeys(accountID string) ([]APIKey, error) {
+	rows, err := db.DB.Query(context.Background(), `
+		SELECT id, prefix, created_at, last_used_at, revoked_at
+		FROM service_api_keys
+		WHERE service_account_id = $1
+		ORDER BY created_at
+	`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var key APIKey
+		if err := rows.Scan(&key.ID, &key.Prefix, &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// normalizeScopes validates, deduplicates and sorts scopes
+func normalizeScopes(scopes []string) ([]string, error) {
+	seen := make(map[string]bool, len(scopes))
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if scope == "" || seen[scope] {
+			continue
+		}
+		if !knownScopes[scope] {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+		seen[scope] = true
+		normalized = append(normalized, scope)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"TetriON.WebServer/server/internal/db"
+	"TetriON.WebServer/server/internal/logging"
+	redisnet "TetriON.WebServer/server/internal/net/redis"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is a logged-in device, backed by a refresh token family
+type Session struct {
+	ID         string    `json:"id"`
+	DeviceName string    `json:"device_name"`
+	IP         string    `json:"ip,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"`
+}
+
+// UpdateSessionClient records the device and IP a session was started from
+func UpdateSessionClient(sessionID, userAgent, ip string) error {
+	if db.DB == nil {
+		return ErrDatabaseError
+	}
+
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	_, err := db.DB.Exec(context.Background(), `
+		UPDATE user_sessions
+		SET device_name = $1, user_agent = $2, ip = $3
+		WHERE id = $4
+	`, deviceName(userAgent), userAgent, ip, sessionID)
+	return err
+}
+
+// ListSessions returns the active sessions of a user, most recently used first.
+// currentSessionID marks the caller's own session.
+func ListSessions(userID, currentSessionID string) ([]Session, error) {
+	if db.DB == nil {
+		return nil, ErrDatabaseError
+	}
+
+	ctx := context.Background()
+	rows, err := db.DB.Query(ctx, `
+		SELECT s.id, s.device_name, s.ip, s.created_at, s.last_used_at
+		FROM user_sessions s
+		WHERE s.user_id = $1 AND EXISTS (
+			SELECT 1 FROM refresh_tokens t
+			WHERE t.family_id = s.id AND t.used_at IS NULL AND t.revoked_at IS NULL AND t.expires_at > $2
+		)
+	`, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		var device, ip *string
+		if err := rows.Scan(&session.ID, &device, &ip, &session.CreatedAt, &session.LastUsedAt); err != nil {
+			return nil, err
+		}
+		if device != nil {
+			session.DeviceName = *device
+		}
+		if ip != nil {
+			session.IP = *ip
+		}
+		session.Current = session.ID == currentSessionID
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Requests between refreshes are only tracked in Redis
+	ids := make([]string, len(sessions))
+	for i, session := range sessions {
+		ids[i] = session.ID
+	}
+	seen, err := redisnet.SessionsLastSeen(ctx, ids)
+	if err != nil {
+		logging.LogError("Failed to read session activity: %v", err)
+	}
+	for i := range sessions {
+		if at, ok := seen[sessions[i].ID]; ok && at.After(sessions[i].LastUsedAt) {
+			sessions[i].LastUsedAt = at
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+// RevokeSession logs one of the user's sessions out
+func RevokeSession(userID, sessionID string) error {
+	if db.DB == nil {
+		return ErrDatabaseError
+	}
+
+	ctx := context.Background()
+	var owner string
+	err := db.DB.QueryRow(ctx, `SELECT user_id FROM user_sessions WHERE id = $1`, sessionID).Scan(&owner)
+	var pgErr *pgconn.PgError
+	if err == pgx.ErrNoRows || (errors.As(err, &pgErr) && pgErr.Code == "22P02") || (err == nil && owner != userID) {
+		// 22P02: the id is not a valid UUID
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := revokeRefreshFamily(ctx, sessionID); err != nil {
+		return err
+	}
+	if err := redisnet.RevokeSession(ctx, sessionID, accessTokenTTL()); err != nil {
+		return err
+	}
+
+	notifySessionRevoked(userID, sessionID)
+	return nil
+}
+
+// Helper functions
+
+var clientVersionPattern = regexp.MustCompile(`^TetriON[A-Za-z]*/[0-9.]+`)
+
+// deviceName turns a User-Agent into a short label such as "Firefox on Windows"
+func deviceName(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+	if client := clientVersionPattern.FindString(userAgent); client != "" {
+		if os := userAgentOS(userAgent); os != "" {
+			return client + " on " + os
+		}
+		return client
+	}
+
+	browser := ""
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(userAgent, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	}
+
+	os := userAgentOS(userAgent)
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "":
+		return browser
+	case os != "":
+		return os
+	}
+
+	if len(userAgent) > 64 {
+		return userAgent[:64]
+	}
+	return userAgent
+}
+
+func userAgentOS(userAgent string) string {
+	switch {
+	case strings.Contains(userAgent, "Android"):
+		return "Android"
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"):
+		return "iOS"
+	case strings.Contains(userAgent, "Windows"):
+		return "Windows"
+	case strings.Contains(userAgent, "Mac OS X"), strings.Contains(userAgent, "Macintosh"):
+		return "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		return "Linux"
+	}
+	return ""
+}
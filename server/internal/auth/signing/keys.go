@@ -0,0 +1,319 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"TetriON.WebServer/server/internal/auth/oidc"
+	"TetriON.WebServer/server/internal/config"
+	"TetriON.WebServer/server/internal/db"
+	"TetriON.WebServer/server/internal/logging"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmRS256 = "RS256"
+
+	// keyPrepublishWindow is how long a scheduled key is published in the JWKS before it signs tokens
+	keyPrepublishWindow = time.Hour
+	// unknownKidReloadInterval bounds how often an unknown kid may trigger a key reload
+	unknownKidReloadInterval = 30 * time.Second
+)
+
+var (
+	ErrNoSigningKey = errors.New("no active signing key")
+	ErrUnknownKey   = errors.New("unknown signing key")
+)
+
+// validMethods lists the algorithms accepted when parsing tokens; HMAC is never accepted
+var validMethods = []string{AlgorithmEdDSA, AlgorithmRS256}
+
+type signingKey struct {
+	kid         string
+	alg         string
+	private     crypto.Signer
+	public      crypto.PublicKey
+	activatesAt time.Time
+	expiresAt   *time.Time
+}
+
+var (
+	keysMu     sync.RWMutex
+	keys       = make(map[string]*signingKey)
+	lastLoaded time.Time
+)
+
+// Init loads the signing keys from the database and creates the first key if none is usable.
+// Without a database an in-memory key is generated; tokens then do not survive a restart.
+func Init() {
+	if db.DB == nil {
+		logging.LogWarning("Database unavailable, using an ephemeral JWT signing key")
+	}
+	if _, err := Rotate(false); err != nil {
+		logging.LogError("Failed to initialize JWT signing keys: %v", err)
+		return
+	}
+
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	logging.LogInfo("Loaded %d JWT signing key(s)", len(keys))
+}
+
+// Sign signs the claims with the active key and sets its kid in the token header
+func Sign(claims jwt.Claims) (string, error) {
+	key := activeKey(time.Now())
+	if key == nil {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(signingMethod(key.alg), claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
+}
+
+// Parse verifies a token against the key named by its kid and decodes it into claims
+func Parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, keyfunc, jwt.WithValidMethods(validMethods))
+}
+
+// PublicKeys returns every published key as a JWK, including keys scheduled for activation
+// and retired keys that still verify tokens.
+func PublicKeys() []oidc.JSONWebKey {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+
+	now := time.Now()
+	published := make([]*signingKey, 0, len(keys))
+	for _, key := range keys {
+		if key.usable(now) {
+			published = append(published, key)
+		}
+	}
+	sort.Slice(published, func(i, j int) bool {
+		return published[i].activatesAt.After(published[j].activatesAt)
+	})
+
+	set := make([]oidc.JSONWebKey, 0, len(published))
+	for _, key := range published {
+		jwk, err := oidc.NewJSONWebKey(key.kid, key.alg, key.public)
+		if err != nil {
+			logging.LogError("Failed to encode signing key %s: %v", key.kid, err)
+			continue
+		}
+		set = append(set, jwk)
+	}
+	return set
+}
+
+// Rotate creates a new signing key when the active one is due for rotation (jwt_key_rotation_days).
+// Scheduled keys are published keyPrepublishWindow before they start signing; force activates a
+// new key immediately. Previous keys keep verifying for jwt_key_overlap_hours after the switch.
+func Rotate(force bool) (bool, error) {
+	if db.DB == nil {
+		return rotateEphemeral(force)
+	}
+
+	rotated, err := rotateStored(force)
+	if err != nil {
+		return false, err
+	}
+	return rotated, Reload()
+}
+
+// keyfunc returns the verification key for the token's kid, reloading once on unknown kids
+func keyfunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, ErrUnknownKey
+	}
+
+	key, ok := lookupKey(kid)
+	if !ok && db.DB != nil && reloadDue() {
+		if err := Reload(); err != nil {
+			return nil, err
+		}
+		key, ok = lookupKey(kid)
+	}
+	if !ok || !key.usable(time.Now()) {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.alg {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.public, nil
+}
+
+func lookupKey(kid string) (*signingKey, bool) {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	key, ok := keys[kid]
+	return key, ok
+}
+
+func reloadDue() bool {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	return time.Since(lastLoaded) >= unknownKidReloadInterval
+}
+
+// activeKey returns the most recently activated key that can sign
+func activeKey(now time.Time) *signingKey {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+
+	var active *signingKey
+	for _, key := range keys {
+		if key.private == nil || key.activatesAt.After(now) || !key.usable(now) {
+			continue
+		}
+		if active == nil || key.activatesAt.After(active.activatesAt) {
+			active = key
+		}
+	}
+	return active
+}
+
+func (k *signingKey) usable(now time.Time) bool {
+	return k.expiresAt == nil || k.expiresAt.After(now)
+}
+
+// rotationSchedule decides whether a new key is needed and when it becomes active.
+// current is the activation time of the signing key, nil when there is none.
+func rotationSchedule(now time.Time, current *time.Time, pending, force bool) (time.Time, bool) {
+	switch {
+	case current == nil, force:
+		return now, true
+	case pending:
+		return time.Time{}, false
+	case now.Sub(*current) >= rotationInterval()-keyPrepublishWindow:
+		return now.Add(keyPrepublishWindow), true
+	}
+	return time.Time{}, false
+}
+
+func rotateEphemeral(force bool) (bool, error) {
+	now := time.Now()
+	current := activeKey(now)
+
+	keysMu.Lock()
+	defer keysMu.Unlock()
+
+	var currentAt *time.Time
+	if current != nil {
+		currentAt = &current.activatesAt
+	}
+	pending := false
+	for kid, key := range keys {
+		if !key.usable(now) {
+			delete(keys, kid)
+			continue
+		}
+		if key.activatesAt.After(now) {
+			pending = true
+		}
+	}
+
+	activatesAt, due := rotationSchedule(now, currentAt, pending, force)
+	if !due {
+		return false, nil
+	}
+
+	key, err := generateKey(configuredAlgorithm())
+	if err != nil {
+		return false, err
+	}
+	key.activatesAt = activatesAt
+
+	expiresAt := activatesAt.Add(keyOverlap())
+	for _, previous := range keys {
+		if previous.expiresAt == nil {
+			previous.expiresAt = &expiresAt
+		}
+	}
+	keys[key.kid] = key
+	lastLoaded = now
+	return true, nil
+}
+
+func generateKey(alg string) (*signingKey, error) {
+	var private crypto.Signer
+	var err error
+	switch alg {
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	kid, err := keyID(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &signingKey{kid: kid, alg: alg, private: private, public: private.Public()}, nil
+}
+
+// keyID derives a stable kid from the public key
+func keyID(public crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+func signingMethod(alg string) jwt.SigningMethod {
+	if alg == AlgorithmRS256 {
+		return jwt.SigningMethodRS256
+	}
+	return jwt.SigningMethodEdDSA
+}
+
+// configuredAlgorithm returns jwt_signing_algorithm from config.json (default EdDSA)
+func configuredAlgorithm() string {
+	if alg, ok := config.GetConfig(config.CONFIG_JWT_SIGNING_ALGORITHM).(string); ok {
+		switch strings.ToUpper(alg) {
+		case "RS256":
+			return AlgorithmRS256
+		case "EDDSA":
+			return AlgorithmEdDSA
+		}
+	}
+	return AlgorithmEdDSA
+}
+
+// rotationInterval returns jwt_key_rotation_days (default 30)
+func rotationInterval() time.Duration {
+	days := 30
+	if d, err := strconv.Atoi(fmt.Sprint(config.GetConfig(config.CONFIG_JWT_KEY_ROTATION_DAYS))); err == nil && d > 0 {
+		days = d
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// keyOverlap returns jwt_key_overlap_hours (default 24); it must exceed the access token lifetime
+func keyOverlap() time.Duration {
+	hours := 24
+	if h, err := strconv.Atoi(fmt.Sprint(config.GetConfig(config.CONFIG_JWT_KEY_OVERLAP_HOURS))); err == nil && h > 0 {
+		hours = h
+	}
+	return time.Duration(hours) * time.Hour
+}
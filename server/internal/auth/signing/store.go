@@ -0,0 +1,188 @@
+package signing
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"os"
+	"time"
+
+	"TetriON.WebServer/server/internal/db"
+)
+
+// rotationLockID is the advisory lock that serializes key rotation between server instances
+const rotationLockID = 0x7465747269 // "tetri"
+
+// Reload replaces the in-memory key ring with the usable keys stored in the database
+func Reload() error {
+	if db.DB == nil {
+		return errors.New("database not available")
+	}
+
+	rows, err := db.DB.Query(context.Background(), `
+		SELECT kid, algorithm, private_key, activates_at, expires_at
+		FROM jwt_signing_keys
+		WHERE expires_at IS NULL OR expires_at > $1
+	`, time.Now())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	loaded := make(map[string]*signingKey)
+	for rows.Next() {
+		var key signingKey
+		var sealed string
+		if err := rows.Scan(&key.kid, &key.alg, &sealed, &key.activatesAt, &key.expiresAt); err != nil {
+			return err
+		}
+		if key.private, err = openPrivateKey(sealed); err != nil {
+			return err
+		}
+		key.public = key.private.Public()
+		loaded[key.kid] = &key
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	keys = loaded
+	lastLoaded = time.Now()
+	return nil
+}
+
+func rotateStored(force bool) (bool, error) {
+	ctx := context.Background()
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, rotationLockID); err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	if _, err := tx.Exec(ctx, `DELETE FROM jwt_signing_keys WHERE expires_at <= $1`, now); err != nil {
+		return false, err
+	}
+
+	var current *time.Time
+	var pending bool
+	if err := tx.QueryRow(ctx, `
+		SELECT
+			MAX(activates_at) FILTER (WHERE activates_at <= $1),
+			COUNT(*) FILTER (WHERE activates_at > $1) > 0
+		FROM jwt_signing_keys
+	`, now).Scan(&current, &pending); err != nil {
+		return false, err
+	}
+
+	activatesAt, due := rotationSchedule(now, current, pending, force)
+	if !due {
+		return false, tx.Commit(ctx)
+	}
+
+	key, err := generateKey(configuredAlgorithm())
+	if err != nil {
+		return false, err
+	}
+	sealed, err := sealPrivateKey(key.private)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE jwt_signing_keys
+		SET expires_at = $1
+		WHERE expires_at IS NULL
+	`, activatesAt.Add(keyOverlap())); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO jwt_signing_keys (kid, algorithm, private_key, created_at, activates_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, key.kid, key.alg, sealed, now, activatesAt); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// sealPrivateKey encrypts the PKCS#8 encoding of a private key for storage
+func sealPrivateKey(private crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := keyCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, der, nil)), nil
+}
+
+// openPrivateKey decrypts a value produced by sealPrivateKey
+func openPrivateKey(sealed string) (crypto.Signer, error) {
+	gcm, err := keyCipher()
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < gcm.NonceSize() {
+		return nil, errors.New("sealed signing key is too short")
+	}
+	der, err := gcm.Open(nil, raw[:gcm.NonceSize()], raw[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("stored signing key cannot sign")
+	}
+	return private, nil
+}
+
+// keyCipher derives the AES key from JWT_KEY_ENCRYPTION_KEY, falling back to JWT_SECRET
+func keyCipher() (cipher.AEAD, error) {
+	material := os.Getenv("JWT_KEY_ENCRYPTION_KEY")
+	if material == "" {
+		material = os.Getenv("JWT_SECRET")
+	}
+	if material == "" {
+		return nil, errors.New("JWT_KEY_ENCRYPTION_KEY not configured")
+	}
+
+	key := sha256.Sum256([]byte(material))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,448 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"TetriON.WebServer/server/internal/auth/oidc"
+	"TetriON.WebServer/server/internal/db"
+	"TetriON.WebServer/server/internal/mail"
+	redisnet "TetriON.WebServer/server/internal/net/redis"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrIdentityAlreadyLinked = errors.New("this provider account is already linked to another user")
+	ErrProviderAlreadyLinked = errors.New("another account of this provider is already linked")
+	ErrIdentityNotLinked     = errors.New("provider is not linked to this account")
+	ErrLastLoginMethod       = errors.New("cannot unlink the only way to sign in")
+	ErrIdentityEmailInUse    = errors.New("an account with this email already exists; log in and link the provider instead")
+	ErrIdentityEmailMissing  = errors.New("identity provider did not share an email address")
+	ErrInvalidOIDCState      = errors.New("invalid or expired login state")
+)
+
+const (
+	oidcStateTicket = "oidc_state"
+	oidcStateTTL    = 10 * time.Minute
+
+	// OIDCBindingCookie holds a secret tying a pending provider flow to the browser that
+	// started it, so an authorization URL handed to someone else cannot be completed
+	OIDCBindingCookie = "oidc_binding"
+
+	OIDCModeLogin = "login"
+	OIDCModeLink  = "link"
+)
+
+var usernameSanitizer = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// LinkedIdentity is a provider account linked to a user
+type LinkedIdentity struct {
+	Provider    string     `json:"provider"`
+	Subject     string     `json:"subject"`
+	Email       string     `json:"email,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// OIDCResult is the outcome of a completed provider callback
+type OIDCResult struct {
+	User    *User
+	Tokens  *TokenPair
+	Mode    string
+	Created bool
+}
+
+type oidcState struct {
+	Provider string `json:"provider"`
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+	Mode     string `json:"mode"`
+	UserID   string `json:"user_id,omitempty"`
+	Binding  string `json:"binding"` // hash of the OIDCBindingCookie value
+}
+
+// Pending flows live in Redis; tests replace these with an in-memory store
+var (
+	putOIDCState = func(ctx context.Context, id, value string) error {
+		return redisnet.PutTicket(ctx, oidcStateTicket, id, value, oidcStateTTL)
+	}
+	takeOIDCState = func(ctx context.Context, id string) (string, bool, error) {
+		return redisnet.TakeTicket(ctx, oidcStateTicket, id)
+	}
+)
+
+// BeginOIDC starts an authorization code + PKCE flow and returns the provider URL to redirect to
+// together with the binding secret the caller stores in the OIDCBindingCookie of the browser.
+// In link mode the resulting identity is attached to userID instead of logging in.
+func BeginOIDC(providerName, mode, userID string) (string, string, error) {
+	provider, err := oidc.Lookup(providerName)
+	if err != nil {
+		return "", "", err
+	}
+
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.RandomString(16)
+	if err != nil {
+		return "", "", err
+	}
+	state, err := oidc.RandomString(24)
+	if err != nil {
+		return "", "", err
+	}
+	binding, err := oidc.RandomString(24)
+	if err != nil {
+		return "", "", err
+	}
+
+	payload, err := json.Marshal(oidcState{
+		Provider: provider.Name(),
+		Verifier: verifier,
+		Nonce:    nonce,
+		Mode:     mode,
+		UserID:   userID,
+		Binding:  hashOpaqueToken(binding),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	ctx := context.Background()
+	if err := putOIDCState(ctx, hashOpaqueToken(state), string(payload)); err != nil {
+		return "", "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, oidcRedirectURL(provider), state, nonce, challenge)
+	if err != nil {
+		return "", "", err
+	}
+	return authURL, binding, nil
+}
+
+// CompleteOIDC handles the provider callback: it exchanges the code, verifies the identity
+// and either links it or logs the matching user in (creating an account on first use).
+// binding is the OIDCBindingCookie of the browser that returned from the provider.
+// Accounts with 2FA get ErrMFARequired together with the user, like Login.
+func CompleteOIDC(providerName, state, binding, code string) (*OIDCResult, error) {
+	st, identity, err := verifyOIDCCallback(context.Background(), providerName, state, binding, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if st.Mode == OIDCModeLink {
+		if err := linkIdentity(st.UserID, identity); err != nil {
+			return nil, err
+		}
+		user, err := GetUserByID(st.UserID)
+		if err != nil {
+			return nil, err
+		}
+		return &OIDCResult{User: user, Mode: OIDCModeLink}, nil
+	}
+
+	user, created, err := userForIdentity(identity)
+	if err != nil {
+		return nil, err
+	}
+	result := &OIDCResult{User: user, Mode: OIDCModeLogin, Created: created}
+
+	if err := CheckBan(user.ID, BanScopeLogin); err != nil {
+		return nil, err
+	}
+
+	if user.TOTPEnabled {
+		return result, ErrMFARequired
+	}
+
+	result.Tokens, err = startSession(user)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListIdentities returns every provider linked to the user
+func ListIdentities(userID string) ([]LinkedIdentity, error) {
+	if db.DB == nil {
+		return nil, ErrDatabaseError
+	}
+
+	rows, err := db.DB.Query(context.Background(), `
+		SELECT provider, subject, email, created_at, last_login_at
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []LinkedIdentity{}
+	for rows.Next() {
+		var identity LinkedIdentity
+		var email *string
+		if err := rows.Scan(&identity.Provider, &identity.Subject, &email, &identity.CreatedAt, &identity.LastLoginAt); err != nil {
+			return nil, err
+		}
+		if email != nil {
+			identity.Email = *email
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
+
+// UnlinkIdentity removes a provider from the account, unless it is the only way left to sign in
+func UnlinkIdentity(userID, providerName string) error {
+	if db.DB == nil {
+		return ErrDatabaseError
+	}
+
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	identities, err := ListIdentities(userID)
+	if err != nil {
+		return err
+	}
+
+	providerName = strings.ToLower(providerName)
+	linked := false
+	for _, identity := range identities {
+		if identity.Provider == providerName {
+			linked = true
+		}
+	}
+	if !linked {
+		return ErrIdentityNotLinked
+	}
+	if user.PasswordHash == "" && len(identities) == 1 {
+		return ErrLastLoginMethod
+	}
+
+	_, err = db.DB.Exec(context.Background(), `
+		DELETE FROM user_identities
+		WHERE user_id = $1 AND provider = $2
+	`, userID, providerName)
+	return err
+}
+
+// Helper functions
+
+// verifyOIDCCallback consumes the pending flow named by state, checks that the browser holds
+// its binding and resolves the identity the provider vouches for
+func verifyOIDCCallback(ctx context.Context, providerName, state, binding, code string) (*oidcState, *oidc.Identity, error) {
+	if state == "" {
+		return nil, nil, ErrInvalidOIDCState
+	}
+	raw, ok, err := takeOIDCState(ctx, hashOpaqueToken(state))
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, ErrInvalidOIDCState
+	}
+
+	var st oidcState
+	if err := json.Unmarshal([]byte(raw), &st); err != nil {
+		return nil, nil, ErrInvalidOIDCState
+	}
+	if !strings.EqualFold(st.Provider, providerName) {
+		return nil, nil, ErrInvalidOIDCState
+	}
+	if binding == "" || st.Binding == "" || subtle.ConstantTimeCompare([]byte(hashOpaqueToken(binding)), []byte(st.Binding)) != 1 {
+		return nil, nil, ErrInvalidOIDCState
+	}
+
+	provider, err := oidc.Lookup(st.Provider)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := provider.Exchange(ctx, oidcRedirectURL(provider), code, st.Verifier)
+	if err != nil {
+		return nil, nil, err
+	}
+	identity, err := provider.Identity(ctx, tokens, st.Nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &st, identity, nil
+}
+
+func oidcRedirectURL(provider *oidc.Provider) string {
+	if redirect := provider.RedirectURL(); redirect != "" {
+		return redirect
+	}
+	return mail.PublicURL("/api/auth/oidc/" + provider.Name() + "/callback")
+}
+
+func linkIdentity(userID string, identity *oidc.Identity) error {
+	if db.DB == nil {
+		return ErrDatabaseError
+	}
+	if userID == "" {
+		return ErrInvalidOIDCState
+	}
+
+	var owner string
+	err := db.DB.QueryRow(context.Background(), `
+		SELECT user_id FROM user_identities
+		WHERE provider = $1 AND subject = $2
+	`, identity.Provider, identity.Subject).Scan(&owner)
+	if err == nil {
+		if owner == userID {
+			return nil
+		}
+		return ErrIdentityAlreadyLinked
+	}
+	if err != pgx.ErrNoRows {
+		return err
+	}
+
+	_, err = db.DB.Exec(context.Background(), `
+		INSERT INTO user_identities (user_id, provider, subject, email, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+	`, userID, identity.Provider, identity.Subject, identity.Email, time.Now())
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrProviderAlreadyLinked
+		}
+		return err
+	}
+	return nil
+}
+
+// userForIdentity returns the user linked to the identity, registering a new account on first login.
+// Existing accounts with the same email are never linked implicitly to prevent account takeover.
+func userForIdentity(identity *oidc.Identity) (*User, bool, error) {
+	if db.DB == nil {
+		return nil, false, ErrDatabaseError
+	}
+
+	ctx := context.Background()
+	var userID string
+	err := db.DB.QueryRow(ctx, `
+		UPDATE user_identities
+		SET last_login_at = $1
+		WHERE provider = $2 AND subject = $3
+		RETURNING user_id
+	`, time.Now(), identity.Provider, identity.Subject).Scan(&userID)
+	if err == nil {
+		user, err := GetUserByID(userID)
+		if err != nil {
+			return nil, false, err
+		}
+		if user.DeletedAt != nil {
+			return nil, false, ErrInvalidCredentials
+		}
+		return user, false, nil
+	}
+	if err != pgx.ErrNoRows {
+		return nil, false, err
+	}
+
+	if identity.Email == "" || validateEmail(identity.Email) != nil {
+		return nil, false, ErrIdentityEmailMissing
+	}
+	if existing, _ := GetUserByEmail(identity.Email); existing != nil {
+		return nil, false, ErrIdentityEmailInUse
+	}
+
+	user, err := createIdentityUser(ctx, identity)
+	if err != nil {
+		return nil, false, err
+	}
+	return user, true, nil
+}
+
+// createIdentityUser registers a password-less account together with its identity
+func createIdentityUser(ctx context.Context, identity *oidc.Identity) (*User, error) {
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	var userID string
+	for attempt := 0; ; attempt++ {
+		username, err := identityUsername(identity, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		err = tx.QueryRow(ctx, `
+			INSERT INTO users (username, email, password_hash, email_verified, email_verified_at, created_at, updated_at)
+			VALUES ($1, $2, '', $3, CASE WHEN $3 THEN $4::timestamp END, $4, $4)
+			ON CONFLICT (username) DO NOTHING
+			RETURNING id
+		`, username, identity.Email, identity.EmailVerified, now).Scan(&userID)
+		if err == nil {
+			break
+		}
+		if err != pgx.ErrNoRows {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return nil, ErrIdentityEmailInUse
+			}
+			return nil, err
+		}
+		if attempt >= 5 {
+			return nil, errors.New("could not find a free username")
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO user_identities (user_id, provider, subject, email, created_at, last_login_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+	`, userID, identity.Provider, identity.Subject, identity.Email, now); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return GetUserByID(userID)
+}
+
+// identityUsername derives a valid username from the provider profile; retries get a random suffix
+func identityUsername(identity *oidc.Identity, attempt int) (string, error) {
+	base := identity.PreferredUsername
+	if base == "" {
+		base = identity.Name
+	}
+	if base == "" {
+		base = strings.SplitN(identity.Email, "@", 2)[0]
+	}
+	base = usernameSanitizer.ReplaceAllString(strings.ReplaceAll(base, " ", "_"), "")
+	if len(base) > 40 {
+		base = base[:40]
+	}
+	if len(base) < 3 {
+		base = "player"
+	}
+	if attempt == 0 {
+		return base, nil
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(10000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s_%04d", base, n.Int64()), nil
+}
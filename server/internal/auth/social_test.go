@@ -0,0 +1,343 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"TetriON.WebServer/server/internal/auth/oidc"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	mockClientID    = "tetrion"
+	mockRedirectURL = "http://localhost:8080/api/auth/oidc/mock/callback"
+)
+
+// mockIssuer is a local OpenID provider serving discovery, JWKS, authorization and token endpoints
+type mockIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockGrant
+}
+
+type mockGrant struct {
+	challenge string
+	nonce     string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	issuer := &mockIssuer{key: key, codes: make(map[string]mockGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer.URL,
+			"authorization_endpoint": issuer.URL + "/authorize",
+			"token_endpoint":         issuer.URL + "/token",
+			"jwks_uri":               issuer.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []oidc.JSONWebKey{{
+			Kty: "RSA",
+			Kid: "mock",
+			Alg: "RS256",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	// The user approves at once: the browser is sent back with a code
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("client_id") != mockClientID || query.Get("code_challenge_method") != "S256" {
+			http.Error(w, "bad authorization request", http.StatusBadRequest)
+			return
+		}
+		code, _ := oidc.RandomString(16)
+		issuer.mu.Lock()
+		issuer.codes[code] = mockGrant{challenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+		issuer.mu.Unlock()
+
+		redirect := query.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+		http.Redirect(w, r, redirect, http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+			http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+			return
+		}
+		issuer.mu.Lock()
+		grant, ok := issuer.codes[r.PostForm.Get("code")]
+		delete(issuer.codes, r.PostForm.Get("code"))
+		issuer.mu.Unlock()
+
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":                issuer.URL,
+			"aud":                mockClientID,
+			"sub":                "mock-subject",
+			"nonce":              grant.nonce,
+			"email":              "player@example.com",
+			"email_verified":     true,
+			"preferred_username": "mockplayer",
+			"iat":                time.Now().Unix(),
+			"exp":                time.Now().Add(time.Minute).Unix(),
+		})
+		idToken.Header["kid"] = "mock"
+		signed, err := idToken.SignedString(key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "mock-access-token",
+			"token_type":   "Bearer",
+			"id_token":     signed,
+			"expires_in":   60,
+		})
+	})
+
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+	return issuer
+}
+
+// authorize follows an authorization URL the way a browser would and returns the callback query
+func (m *mockIssuer) authorize(t *testing.T, authURL string) url.Values {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: status %d", resp.StatusCode)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	if got := location.Scheme + "://" + location.Host + location.Path; got != mockRedirectURL {
+		t.Fatalf("authorize redirected to %s, want %s", got, mockRedirectURL)
+	}
+	return location.Query()
+}
+
+// setupMockOIDC registers a provider named "mock" against a local issuer and keeps pending
+// flows in memory instead of Redis
+func setupMockOIDC(t *testing.T) *mockIssuer {
+	t.Helper()
+
+	issuer := newMockIssuer(t)
+	oidc.Register(oidc.NewProvider(oidc.ProviderConfig{
+		Name:        "mock",
+		Issuer:      issuer.URL,
+		ClientID:    mockClientID,
+		RedirectURL: mockRedirectURL,
+	}, issuer.Client()))
+
+	var mu sync.Mutex
+	states := make(map[string]string)
+	putState, takeState := putOIDCState, takeOIDCState
+	putOIDCState = func(ctx context.Context, id, value string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		states[id] = value
+		return nil
+	}
+	takeOIDCState = func(ctx context.Context, id string) (string, bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		value, ok := states[id]
+		delete(states, id)
+		return value, ok, nil
+	}
+	t.Cleanup(func() {
+		putOIDCState, takeOIDCState = putState, takeState
+	})
+	return issuer
+}
+
+func TestOIDCStartRedirectsWithPKCEAndBindingCookie(t *testing.T) {
+	issuer := setupMockOIDC(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/mock/start", nil)
+	req.SetPathValue("provider", "mock")
+	rec := httptest.NewRecorder()
+	OIDCStartHandler(rec, req)
+
+	if rec.Code != http.StatusFound {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusFound, rec.Body.String())
+	}
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("location: %v", err)
+	}
+	if !strings.HasPrefix(location.String(), issuer.URL+"/authorize?") {
+		t.Fatalf("redirected to %s, want the issuer's authorization endpoint", location)
+	}
+	query := location.Query()
+	for _, param := range []string{"state", "nonce", "code_challenge"} {
+		if query.Get(param) == "" {
+			t.Errorf("authorization URL has no %s", param)
+		}
+	}
+	if query.Get("code_challenge_method") != "S256" {
+		t.Errorf("code_challenge_method = %q, want S256", query.Get("code_challenge_method"))
+	}
+
+	var binding *http.Cookie
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == OIDCBindingCookie {
+			binding = cookie
+		}
+	}
+	if binding == nil || binding.Value == "" {
+		t.Fatal("no binding cookie set")
+	}
+	if !binding.HttpOnly || binding.SameSite != http.SameSiteLaxMode {
+		t.Errorf("binding cookie must be HttpOnly and SameSite=Lax, got %+v", binding)
+	}
+}
+
+func TestOIDCCallbackResolvesIdentity(t *testing.T) {
+	issuer := setupMockOIDC(t)
+
+	authURL, binding, err := BeginOIDC("mock", OIDCModeLogin, "")
+	if err != nil {
+		t.Fatalf("BeginOIDC: %v", err)
+	}
+	callback := issuer.authorize(t, authURL)
+
+	st, identity, err := verifyOIDCCallback(context.Background(), "mock", callback.Get("state"), binding, callback.Get("code"))
+	if err != nil {
+		t.Fatalf("verifyOIDCCallback: %v", err)
+	}
+	if st.Mode != OIDCModeLogin {
+		t.Errorf("mode = %q, want %q", st.Mode, OIDCModeLogin)
+	}
+	if identity.Provider != "mock" || identity.Subject != "mock-subject" || identity.Email != "player@example.com" || !identity.EmailVerified {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+
+	// The state is single-use
+	if _, _, err := verifyOIDCCallback(context.Background(), "mock", callback.Get("state"), binding, callback.Get("code")); err != ErrInvalidOIDCState {
+		t.Errorf("replayed callback: err = %v, want %v", err, ErrInvalidOIDCState)
+	}
+}
+
+func TestOIDCCallbackRejectsOtherBrowsers(t *testing.T) {
+	issuer := setupMockOIDC(t)
+
+	tests := []struct {
+		name     string
+		provider string
+		binding  func(own string) string
+	}{
+		{"missing cookie", "mock", func(string) string { return "" }},
+		{"other browser's cookie", "mock", func(string) string {
+			_, other, err := BeginOIDC("mock", OIDCModeLogin, "")
+			if err != nil {
+				t.Fatalf("BeginOIDC: %v", err)
+			}
+			return other
+		}},
+		{"other provider", "other", func(own string) string { return own }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authURL, binding, err := BeginOIDC("mock", OIDCModeLogin, "")
+			if err != nil {
+				t.Fatalf("BeginOIDC: %v", err)
+			}
+			callback := issuer.authorize(t, authURL)
+
+			_, _, err = verifyOIDCCallback(context.Background(), tt.provider, callback.Get("state"), tt.binding(binding), callback.Get("code"))
+			if err != ErrInvalidOIDCState {
+				t.Errorf("err = %v, want %v", err, ErrInvalidOIDCState)
+			}
+		})
+	}
+}
+
+func TestOIDCLinkIsBoundToTheStartingBrowser(t *testing.T) {
+	issuer := setupMockOIDC(t)
+
+	// The attacker starts a link for their own account and hands the URL to a victim
+	authURL, _, err := BeginOIDC("mock", OIDCModeLink, "attacker-id")
+	if err != nil {
+		t.Fatalf("BeginOIDC: %v", err)
+	}
+	_, victimBinding, err := BeginOIDC("mock", OIDCModeLogin, "")
+	if err != nil {
+		t.Fatalf("BeginOIDC: %v", err)
+	}
+	callback := issuer.authorize(t, authURL)
+	if _, _, err := verifyOIDCCallback(context.Background(), "mock", callback.Get("state"), victimBinding, callback.Get("code")); err != ErrInvalidOIDCState {
+		t.Fatalf("victim's callback: err = %v, want %v", err, ErrInvalidOIDCState)
+	}
+
+	// The account owner completes their own link
+	authURL, binding, err := BeginOIDC("mock", OIDCModeLink, "owner-id")
+	if err != nil {
+		t.Fatalf("BeginOIDC: %v", err)
+	}
+	callback = issuer.authorize(t, authURL)
+	st, identity, err := verifyOIDCCallback(context.Background(), "mock", callback.Get("state"), binding, callback.Get("code"))
+	if err != nil {
+		t.Fatalf("verifyOIDCCallback: %v", err)
+	}
+	if st.Mode != OIDCModeLink || st.UserID != "owner-id" || identity.Subject != "mock-subject" {
+		t.Errorf("unexpected link state %+v for identity %+v", st, identity)
+	}
+}
+
+func TestOIDCCallbackHandlerRequiresBindingCookie(t *testing.T) {
+	issuer := setupMockOIDC(t)
+
+	authURL, _, err := BeginOIDC("mock", OIDCModeLogin, "")
+	if err != nil {
+		t.Fatalf("BeginOIDC: %v", err)
+	}
+	callback := issuer.authorize(t, authURL)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/mock/callback?"+callback.Encode(), nil)
+	req.SetPathValue("provider", "mock")
+	rec := httptest.NewRecorder()
+	OIDCCallbackHandler(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+}
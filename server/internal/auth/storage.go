@@ -17,12 +17,50 @@ var (
 )
 
 type User struct {
-	ID           string    `json:"id"`
-	Username     string    `json:"username"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"` // Never serialize password
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID            string    `json:"id"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	TOTPEnabled   bool      `json:"totp_enabled"`
+	Role          string    `json:"role"`
+	IsGuest       bool      `json:"is_guest"`
+	PasswordHash  string    `json:"-"` // Never serialize password
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+	DeletedAt           *time.Time `json:"-"`
+	GuestExpiresAt      *time.Time `json:"guest_expires_at,omitempty"`
+}
+
+// userColumns lists the users columns read by scanUser, in scan order
+const userColumns = `id, username, email, email_verified, totp_enabled, role, is_guest, password_hash, created_at, updated_at,
+	deletion_scheduled_at, deleted_at, guest_expires_at`
+
+func scanUser(row pgx.Row) (*User, error) {
+	user := &User{}
+	err := row.Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.EmailVerified,
+		&user.TOTPEnabled,
+		&user.Role,
+		&user.IsGuest,
+		&user.PasswordHash,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.DeletionScheduledAt,
+		&user.DeletedAt,
+		&user.GuestExpiresAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
 }
 
 // CreateUser inserts a new user into the database
@@ -34,7 +72,7 @@ func CreateUser(user *User) error {
 	query := `
 		INSERT INTO users (username, email, password_hash, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id
+		RETURNING id, role
 	`
 
 	now := time.Now()
@@ -48,7 +86,7 @@ func CreateUser(user *User) error {
 		user.PasswordHash,
 		user.CreatedAt,
 		user.UpdatedAt,
-	).Scan(&user.ID)
+	).Scan(&user.ID, &user.Role)
 
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -68,30 +106,13 @@ func GetUserByUsername(username string) (*User, error) {
 	}
 
 	query := `
-		SELECT id, username, email, password_hash, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM users
 		WHERE username = $1
 	`
 
-	user := &User{}
 	ctx := context.Background()
-	err := db.DB.QueryRow(ctx, query, username).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.PasswordHash,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, ErrUserNotFound
-		}
-		return nil, err
-	}
-
-	return user, nil
+	return scanUser(db.DB.QueryRow(ctx, query, username))
 }
 
 // GetUserByEmail retrieves a user by their email
@@ -101,30 +122,13 @@ func GetUserByEmail(email string) (*User, error) {
 	}
 
 	query := `
-		SELECT id, username, email, password_hash, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM users
 		WHERE email = $1
 	`
 
-	user := &User{}
 	ctx := context.Background()
-	err := db.DB.QueryRow(ctx, query, email).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.PasswordHash,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, ErrUserNotFound
-		}
-		return nil, err
-	}
-
-	return user, nil
+	return scanUser(db.DB.QueryRow(ctx, query, email))
 }
 
 // GetUserByID retrieves a user by their ID
@@ -134,30 +138,13 @@ func GetUserByID(id string) (*User, error) {
 	}
 
 	query := `
-		SELECT id, username, email, password_hash, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM users
 		WHERE id = $1
 	`
 
-	user := &User{}
 	ctx := context.Background()
-	err := db.DB.QueryRow(ctx, query, id).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.PasswordHash,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, ErrUserNotFound
-		}
-		return nil, err
-	}
-
-	return user, nil
+	return scanUser(db.DB.QueryRow(ctx, query, id))
 }
 
 // UpdateUser updates user information
@@ -166,9 +153,13 @@ func UpdateUser(user *User) error {
 		return ErrDatabaseError
 	}
 
+	// Changing the email address invalidates its verification
 	query := `
 		UPDATE users
-		SET username = $1, email = $2, updated_at = $3
+		SET username = $1,
+			email_verified = CASE WHEN email = $2 THEN email_verified ELSE FALSE END,
+			email = $2,
+			updated_at = $3
 		WHERE id = $4
 	`
 
@@ -183,3 +174,27 @@ func UpdateUser(user *User) error {
 
 	return err
 }
+
+// UpdatePasswordHash replaces the stored password hash of a user
+func UpdatePasswordHash(userID, passwordHash string) error {
+	if db.DB == nil {
+		return ErrDatabaseError
+	}
+
+	query := `
+		UPDATE users
+		SET password_hash = $1, updated_at = $2
+		WHERE id = $3
+	`
+
+	ctx := context.Background()
+	tag, err := db.DB.Exec(ctx, query, passwordHash, time.Now(), userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
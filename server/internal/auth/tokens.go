@@ -6,64 +6,65 @@ import (
 	"strconv"
 	"time"
 
+	"TetriON.WebServer/server/internal/auth/signing"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 var (
 	ErrInvalidToken = errors.New("invalid or expired token")
 	ErrTokenExpired = errors.New("token has expired")
+	ErrTokenRevoked = errors.New("token has been revoked")
 )
 
 type Claims struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	UserID    string `json:"user_id"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	SessionID string `json:"sid,omitempty"`
+	Role      string `json:"role,omitempty"`
+	Guest     bool   `json:"guest,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken creates a new JWT token for the given user
-func GenerateToken(userID, username, email string) (string, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		return "", errors.New("JWT_SECRET not configured")
+// accessTokenTTL returns the lifetime of access tokens (JWT_ACCESS_TTL_MINUTES, default 15)
+func accessTokenTTL() time.Duration {
+	expirationMinutes := 15
+	if minutes := os.Getenv("JWT_ACCESS_TTL_MINUTES"); minutes != "" {
+		if m, err := strconv.Atoi(minutes); err == nil && m > 0 {
+			expirationMinutes = m
+		}
 	}
+	return time.Minute * time.Duration(expirationMinutes)
+}
 
-	expirationHours := 24
-	if hours := os.Getenv("JWT_EXPIRATION_HOURS"); hours != "" {
-		if h, err := strconv.Atoi(hours); err == nil {
-			expirationHours = h
-		}
+// GenerateToken creates a new short-lived JWT access token for the given user and session
+func GenerateToken(user *User, sessionID string) (string, error) {
+	tokenID, _, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
 	}
 
 	claims := &Claims{
-		UserID:   userID,
-		Username: username,
-		Email:    email,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		SessionID: sessionID,
+		Role:      user.Role,
+		Guest:     user.IsGuest,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(expirationHours))),
+			ID:        tokenID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenTTL())),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return signing.Sign(claims)
 }
 
-// VerifyToken validates a JWT token and returns the claims
+// VerifyToken validates a JWT token against the signing key named by its kid and returns the claims
 func VerifyToken(tokenString string) (*Claims, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		return nil, errors.New("JWT_SECRET not configured")
-	}
-
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(secret), nil
-	})
-
+	token, err := signing.Parse(tokenString, &Claims{})
 	if err != nil {
 		return nil, ErrInvalidToken
 	}
@@ -77,18 +78,3 @@ func VerifyToken(tokenString string) (*Claims, error) {
 
 	return nil, ErrInvalidToken
 }
-
-// RefreshToken generates a new token from an existing valid token
-func RefreshToken(tokenString string) (string, error) {
-	claims, err := VerifyToken(tokenString)
-	if err != nil && err != ErrTokenExpired {
-		return "", err
-	}
-
-	// Allow refresh even if expired (within reasonable time)
-	if claims.ExpiresAt != nil && time.Since(claims.ExpiresAt.Time) > 24*time.Hour {
-		return "", errors.New("token too old to refresh")
-	}
-
-	return GenerateToken(claims.UserID, claims.Username, claims.Email)
-}
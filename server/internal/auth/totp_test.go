@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// The SHA-1 secret of RFC 6238 appendix B ("12345678901234567890"), base32 encoded
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeMatchesRFC6238(t *testing.T) {
+	// The RFC lists 8 digit codes; 6 digit codes are their last six digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := totpCode(rfc6238Secret, tt.unix/totpPeriod)
+		if err != nil {
+			t.Fatalf("totpCode at %d: %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("totpCode at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+	codeAt := func(step int64) string {
+		code, err := totpCode(rfc6238Secret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", rfc6238Secret, codeAt(current), current, true},
+		{"previous step is within skew", rfc6238Secret, codeAt(current - 1), current - 1, true},
+		{"next step is within skew", rfc6238Secret, codeAt(current + 1), current + 1, true},
+		{"two steps old", rfc6238Secret, codeAt(current - 2), 0, false},
+		{"two steps ahead", rfc6238Secret, codeAt(current + 2), 0, false},
+		{"spaces are ignored", rfc6238Secret, " 050 471 ", current, true},
+		{"lower-case secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "050471", current, true},
+		{"wrong code", rfc6238Secret, "123456", 0, false},
+		{"too short", rfc6238Secret, "05047", 0, false},
+		{"too long", rfc6238Secret, "0504710", 0, false},
+		{"empty", rfc6238Secret, "", 0, false},
+		{"invalid secret", "not base32!", "050471", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := matchTOTP(tt.secret, tt.code, now)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("matchTOTP(%q) = %d, %v, want %d, %v", tt.code, step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
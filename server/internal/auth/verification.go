@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"TetriON.WebServer/server/internal/db"
+	"TetriON.WebServer/server/internal/mail"
+	redisnet "TetriON.WebServer/server/internal/net/redis"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	ErrEmailAlreadyVerified     = errors.New("email is already verified")
+	ErrVerificationThrottled    = errors.New("verification email was sent recently")
+)
+
+const (
+	verificationTokenTTL       = 24 * time.Hour
+	verificationResendCooldown = time.Minute
+)
+
+// SendVerificationEmail issues a verification token for the user's current email and mails the link
+func SendVerificationEmail(user *User) error {
+	if db.DB == nil {
+		return ErrDatabaseError
+	}
+
+	rawToken, tokenHash, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+
+	// Only the most recent link stays valid
+	if _, err := db.DB.Exec(ctx, `
+		DELETE FROM email_verification_tokens
+		WHERE user_id = $1 AND used_at IS NULL
+	`, user.ID); err != nil {
+		return err
+	}
+
+	if _, err := db.DB.Exec(ctx, `
+		INSERT INTO email_verification_tokens (user_id, email, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, user.ID, user.Email, tokenHash, now.Add(verificationTokenTTL), now); err != nil {
+		return err
+	}
+
+	link := mail.PublicURL("/api/auth/verify?token=" + rawToken)
+	return mail.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Verify your TetriON email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %d hours. If you did not create a TetriON account you can ignore this email.\n",
+			user.Username, link, int(verificationTokenTTL.Hours())),
+	})
+}
+
+// ResendVerificationEmail sends a fresh verification link, at most once per cooldown window.
+// When throttled it returns ErrVerificationThrottled and the time left before the next attempt.
+func ResendVerificationEmail(userID string) (time.Duration, error) {
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return 0, err
+	}
+	if user.IsGuest {
+		return 0, ErrGuestAccount
+	}
+	if user.EmailVerified {
+		return 0, ErrEmailAlreadyVerified
+	}
+
+	acquired, retryAfter, err := redisnet.AcquireCooldown(context.Background(), "verify_resend", user.ID, verificationResendCooldown)
+	if err != nil {
+		return 0, err
+	}
+	if !acquired {
+		return retryAfter, ErrVerificationThrottled
+	}
+
+	return 0, SendVerificationEmail(user)
+}
+
+// VerifyEmail consumes a verification token and marks the address it was sent to as verified
+func VerifyEmail(rawToken string) (*User, error) {
+	if db.DB == nil {
+		return nil, ErrDatabaseError
+	}
+	if rawToken == "" {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	ctx := context.Background()
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	var userID, email string
+	err = tx.QueryRow(ctx, `
+		UPDATE email_verification_tokens
+		SET used_at = $1
+		WHERE token_hash = $2 AND used_at IS NULL AND expires_at > $1
+		RETURNING user_id, email
+	`, now, hashOpaqueToken(rawToken)).Scan(&userID, &email)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrInvalidVerificationToken
+		}
+		return nil, err
+	}
+
+	// The token is void if the account email changed after it was sent
+	tag, err := tx.Exec(ctx, `
+		UPDATE users
+		SET email_verified = TRUE, email_verified_at = $1, updated_at = $1
+		WHERE id = $2 AND email = $3
+	`, now, userID, email)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return GetUserByID(userID)
+}
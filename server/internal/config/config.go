@@ -24,20 +24,57 @@ var (
 	CONFIG_SESSION_TIMEOUT = "session_timeout"
 	CONFIG_EMAIL_SERVICE   = "email_service"
 	CONFIG_CACHE_TTL       = "cache_ttl"
+
+	CONFIG_ACCOUNT_DELETION_GRACE_DAYS = "account_deletion_grace_days"
+	CONFIG_OIDC_PROVIDERS              = "oidc_providers"
+	CONFIG_JWT_SIGNING_ALGORITHM       = "jwt_signing_algorithm"
+	CONFIG_JWT_KEY_ROTATION_DAYS       = "jwt_key_rotation_days"
+	CONFIG_JWT_KEY_OVERLAP_HOURS       = "jwt_key_overlap_hours"
+	CONFIG_PASSWORD_HASH_ALGORITHM     = "password_hash_algorithm"
+	CONFIG_ARGON2_MEMORY_KIB           = "argon2_memory_kib"
+	CONFIG_ARGON2_ITERATIONS           = "argon2_iterations"
+	CONFIG_ARGON2_PARALLELISM          = "argon2_parallelism"
+	CONFIG_BCRYPT_COST                 = "bcrypt_cost"
+	CONFIG_GUEST_ACCOUNT_TTL_DAYS      = "guest_account_ttl_days"
+	CONFIG_AUDIT_RETENTION_DAYS        = "audit_retention_days"
+	CONFIG_PASSWORD_MIN_LENGTH         = "password_min_length"
+	CONFIG_PASSWORD_MIN_ENTROPY_BITS   = "password_min_entropy_bits"
+	CONFIG_BREACHED_PASSWORDS_FILE     = "breached_passwords_file"
+	CONFIG_BLOB_STORE                  = "blob_store"
+	CONFIG_CHAT_REGIONS                = "chat_regions"
+	CONFIG_RANKED_QUEUES               = "ranked_queues"
+	CONFIG_TRUSTED_PROXIES             = "trusted_proxies"
 )
 
 // Environment variable keys
 var (
-	ENV_REDIS_ADDRESS        = "REDIS_ADDR"
-	ENV_REDIS_PASSWORD       = "REDIS_PASSWORD"
-	ENV_POSTGRES_USER        = "POSTGRES_USER"
-	ENV_POSTGRES_PASSWORD    = "POSTGRES_PASSWORD"
-	ENV_POSTGRES_HOST        = "POSTGRES_HOST"
-	ENV_POSTGRES_PORT        = "POSTGRES_PORT"
-	ENV_POSTGRES_DBNAME      = "POSTGRES_DBNAME"
-	ENV_POSTGRES_SSLMODE     = "POSTGRES_SSLMODE"
-	ENV_JWT_SECRET           = "JWT_SECRET"
-	ENV_JWT_EXPIRATION_HOURS = "JWT_EXPIRATION_HOURS"
+	ENV_REDIS_ADDRESS           = "REDIS_ADDR"
+	ENV_REDIS_PASSWORD          = "REDIS_PASSWORD"
+	ENV_POSTGRES_USER           = "POSTGRES_USER"
+	ENV_POSTGRES_PASSWORD       = "POSTGRES_PASSWORD"
+	ENV_POSTGRES_HOST           = "POSTGRES_HOST"
+	ENV_POSTGRES_PORT           = "POSTGRES_PORT"
+	ENV_POSTGRES_DBNAME         = "POSTGRES_DBNAME"
+	ENV_POSTGRES_SSLMODE        = "POSTGRES_SSLMODE"
+	ENV_JWT_SECRET              = "JWT_SECRET"
+	ENV_JWT_ACCESS_TTL_MINUTES  = "JWT_ACCESS_TTL_MINUTES"
+	ENV_JWT_KEY_ENCRYPTION_KEY  = "JWT_KEY_ENCRYPTION_KEY"
+	ENV_REFRESH_TOKEN_TTL_HOURS = "REFRESH_TOKEN_TTL_HOURS"
+	ENV_MFA_ENCRYPTION_KEY      = "MFA_ENCRYPTION_KEY"
+	ENV_PUBLIC_BASE_URL         = "PUBLIC_BASE_URL"
+	ENV_PASSWORD_RESET_URL      = "PASSWORD_RESET_URL"
+	ENV_SMTP_HOST               = "SMTP_HOST"
+	ENV_SMTP_PORT               = "SMTP_PORT"
+	ENV_SMTP_USERNAME           = "SMTP_USERNAME"
+	ENV_SMTP_PASSWORD           = "SMTP_PASSWORD"
+	ENV_SMTP_FROM               = "SMTP_FROM"
+	ENV_MAIL_FILE_PATH          = "MAIL_FILE_PATH"
+	ENV_BLOB_LOCAL_PATH         = "BLOB_LOCAL_PATH"
+	ENV_S3_ENDPOINT             = "S3_ENDPOINT"
+	ENV_S3_REGION               = "S3_REGION"
+	ENV_S3_BUCKET               = "S3_BUCKET"
+	ENV_S3_ACCESS_KEY_ID        = "S3_ACCESS_KEY_ID"
+	ENV_S3_SECRET_ACCESS_KEY    = "S3_SECRET_ACCESS_KEY"
 )
 
 func LoadEnv() {
@@ -0,0 +1,559 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/config"
+	"TetriON.WebServer/server/internal/domain/matchmaking"
+	"TetriON.WebServer/server/internal/logging"
+	redisnet "TetriON.WebServer/server/internal/net/redis"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Channel kinds. A channel is named "global" or "<kind>:<name>", e.g. "region:eu",
+// "room:<match id>" or "clan:<clan id>".
+const (
+	KindGlobal = "global"
+	KindRegion = "region"
+	KindRoom   = "room"
+	KindClan   = "clan"
+)
+
+const (
+	MaxBodyLength   = 500
+	HistorySize     = 100
+	MaxSlowMode     = 10 * time.Minute
+	MaxMuteDuration = 30 * 24 * time.Hour
+	MaxReasonLength = 500
+
+	// Every user may send rateLimitMax messages per rateLimitWindow across all channels
+	rateLimitWindow = 10 * time.Second
+	rateLimitMax    = 5
+
+	// kickRejoinDelay is how long a kicked user stays out of the channel
+	kickRejoinDelay = 5 * time.Minute
+
+	restrictionMute = "mute"
+	restrictionKick = "kick"
+
+	defaultRegions = "eu,na,sa,asia,oce"
+)
+
+var (
+	ErrInvalidChannel     = errors.New("channel must be global or region:, room: or clan: followed by a name")
+	ErrChannelUnavailable = errors.New("channels of this kind are not available")
+	ErrNotAllowed         = errors.New("you cannot join this channel")
+	ErrEmptyBody          = errors.New("message must not be empty")
+	ErrBodyTooLong        = fmt.Errorf("message must be at most %d characters", MaxBodyLength)
+	ErrRateLimited        = errors.New("you are sending messages too quickly")
+	ErrSlowMode           = errors.New("slow mode is on in this channel")
+	ErrMuted              = errors.New("you are muted in this channel")
+	ErrKicked             = errors.New("you were kicked from this channel")
+	ErrMessageNotFound    = errors.New("message not found")
+	ErrNotAuthor          = errors.New("you can only delete your own messages")
+	ErrNotMuted           = errors.New("this player is not muted in this channel")
+	ErrInvalidSlowMode    = fmt.Errorf("slow mode must be between 0 and %d seconds", int(MaxSlowMode.Seconds()))
+	ErrInvalidDuration    = fmt.Errorf("mute duration must be between 0 (until lifted) and %d days", int(MaxMuteDuration.Hours()/24))
+	ErrReasonTooLong      = fmt.Errorf("reason must be at most %d characters", MaxReasonLength)
+)
+
+var channelNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// WaitError is returned when a user has to wait before acting in a channel again. It matches
+// the wrapped error (ErrRateLimited, ErrSlowMode, ErrMuted or ErrKicked) with errors.Is.
+type WaitError struct {
+	Err        error
+	RetryAfter time.Duration // 0 when the restriction has no expiry
+}
+
+func (e *WaitError) Error() string {
+	if e.RetryAfter <= 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s, try again in %d seconds", e.Err, retrySeconds(e.RetryAfter))
+}
+
+func (e *WaitError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfterSeconds rounds the remaining wait up to whole seconds
+func (e *WaitError) RetryAfterSeconds() int {
+	return retrySeconds(e.RetryAfter)
+}
+
+// Message is a message posted to a channel
+type Message struct {
+	ID        int64     `json:"id"`
+	Channel   string    `json:"channel"`
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Event is a WebSocket payload for the members of a channel, published through Redis so
+// that every server instance hands it to the members connected to it
+type Event struct {
+	Channel string          `json:"channel"`
+	Payload json.RawMessage `json:"payload"`
+	// Kick names a user whose sockets leave the channel after receiving the payload
+	Kick string `json:"kick,omitempty"`
+}
+
+// Authorizer decides whether a user may join the channel of one kind with the given name
+type Authorizer func(ctx context.Context, userID, name string) error
+
+var (
+	authorizersMu sync.RWMutex
+	authorizers   = map[string]Authorizer{
+		KindRegion: authorizeRegion,
+		KindRoom:   authorizeRoom,
+	}
+)
+
+// SetAuthorizer registers who may join channels of a kind. Clan channels stay unavailable
+// until the clan feature registers its membership check here.
+func SetAuthorizer(kind string, authorize Authorizer) {
+	authorizersMu.Lock()
+	defer authorizersMu.Unlock()
+	authorizers[kind] = authorize
+}
+
+// Join checks that a user may enter a channel and returns its history, oldest first
+func Join(ctx context.Context, userID, channel string) ([]Message, error) {
+	kind, name, err := ParseChannel(channel)
+	if err != nil {
+		return nil, err
+	}
+	if kind != KindGlobal {
+		authorizersMu.RLock()
+		authorize, ok := authorizers[kind]
+		authorizersMu.RUnlock()
+		if !ok {
+			return nil, ErrChannelUnavailable
+		}
+		if err := authorize(ctx, userID, name); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := checkRestriction(ctx, restrictionKick, channel, userID, ErrKicked); err != nil {
+		return nil, err
+	}
+	return History(ctx, channel)
+}
+
+// Post checks the chat ban, mutes and the rate and slow mode limits, then stores the message
+// in the channel history and sends it to every member. Membership is checked by the caller.
+func Post(ctx context.Context, userID, username, channel, body string) (*Message, error) {
+	if _, _, err := ParseChannel(channel); err != nil {
+		return nil, err
+	}
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, ErrEmptyBody
+	}
+	if utf8.RuneCountInString(body) > MaxBodyLength {
+		return nil, ErrBodyTooLong
+	}
+
+	if err := auth.CheckBan(userID, auth.BanScopeChat); err != nil {
+		return nil, err
+	}
+	if err := checkRestriction(ctx, restrictionMute, channel, userID, ErrMuted); err != nil {
+		return nil, err
+	}
+
+	allowed, err := redisnet.HitRateLimit(ctx, "chat:rate:"+userID, rateLimitWindow, rateLimitMax)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, &WaitError{Err: ErrRateLimited, RetryAfter: rateLimitWindow}
+	}
+
+	interval, err := redisnet.ChatSlowMode(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+	if interval > 0 {
+		acquired, remaining, err := redisnet.AcquireCooldown(ctx, "chat_slow_mode:"+channel, userID, interval)
+		if err != nil {
+			return nil, err
+		}
+		if !acquired {
+			return nil, &WaitError{Err: ErrSlowMode, RetryAfter: remaining}
+		}
+	}
+
+	id, err := redisnet.NextChatMessageID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	msg := &Message{
+		ID:        id,
+		Channel:   channel,
+		UserID:    userID,
+		Username:  username,
+		Body:      body,
+		CreatedAt: time.Now(),
+	}
+
+	encoded, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	if err := redisnet.AppendChatHistory(ctx, channel, encoded, HistorySize); err != nil {
+		return nil, err
+	}
+
+	publish(ctx, Event{Channel: channel}, map[string]any{
+		"type":    "chat_message",
+		"message": msg,
+	})
+	return msg, nil
+}
+
+// History returns the retained messages of a channel, oldest first
+func History(ctx context.Context, channel string) ([]Message, error) {
+	entries, err := redisnet.ChatHistory(ctx, channel, HistorySize)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]Message, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		var msg Message
+		if err := json.Unmarshal([]byte(entries[i]), &msg); err != nil {
+			logging.LogWarning("Skipping unreadable chat message in %s: %v", channel, err)
+			continue
+		}
+		history = append(history, msg)
+	}
+	return history, nil
+}
+
+// Delete removes a message from the channel history and from the screens of every member.
+// Players may delete their own messages; moderators may delete any message, which is audited.
+func Delete(ctx context.Context, actorID, channel string, messageID int64, reason string) error {
+	if _, _, err := ParseChannel(channel); err != nil {
+		return err
+	}
+	if utf8.RuneCountInString(reason) > MaxReasonLength {
+		return ErrReasonTooLong
+	}
+
+	entries, err := redisnet.ChatHistory(ctx, channel, HistorySize)
+	if err != nil {
+		return err
+	}
+
+	var entry string
+	var msg Message
+	for _, candidate := range entries {
+		if err := json.Unmarshal([]byte(candidate), &msg); err == nil && msg.ID == messageID {
+			entry = candidate
+			break
+		}
+	}
+	if entry == "" {
+		return ErrMessageNotFound
+	}
+
+	moderated := msg.UserID != actorID
+	if moderated {
+		ok, err := isModerator(actorID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrNotAuthor
+		}
+	}
+
+	removed, err := redisnet.RemoveChatHistory(ctx, channel, entry)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrMessageNotFound
+	}
+
+	if moderated {
+		auth.RecordAudit(auth.AuditEvent{
+			Type:      auth.AuditChatMessageDeleted,
+			ActorID:   actorID,
+			SubjectID: msg.UserID,
+			Metadata: map[string]any{
+				"channel":    channel,
+				"message_id": messageID,
+				"body":       msg.Body,
+				"reason":     reason,
+			},
+		})
+	}
+
+	publish(ctx, Event{Channel: channel}, map[string]any{
+		"type":       "chat_deleted",
+		"channel":    channel,
+		"message_id": messageID,
+	})
+	return nil
+}
+
+// Mute stops a user from posting in a channel for duration, or until unmuted when it is 0
+func Mute(ctx context.Context, moderatorID, channel, userID string, duration time.Duration, reason string) error {
+	if duration < 0 || duration > MaxMuteDuration {
+		return ErrInvalidDuration
+	}
+	if err := checkModeration(channel, userID, reason); err != nil {
+		return err
+	}
+
+	if err := redisnet.SetChatRestriction(ctx, restrictionMute, channel, userID, duration); err != nil {
+		return err
+	}
+
+	var until *time.Time
+	if duration > 0 {
+		t := time.Now().Add(duration)
+		until = &t
+	}
+	auth.RecordAudit(auth.AuditEvent{
+		Type:      auth.AuditChatMuted,
+		ActorID:   moderatorID,
+		SubjectID: userID,
+		Metadata: map[string]any{
+			"channel": channel,
+			"until":   until,
+			"reason":  reason,
+		},
+	})
+
+	publish(ctx, Event{Channel: channel}, map[string]any{
+		"type":    "chat_muted",
+		"channel": channel,
+		"user_id": userID,
+		"until":   until,
+	})
+	return nil
+}
+
+// Unmute lifts a mute early
+func Unmute(ctx context.Context, moderatorID, channel, userID string) error {
+	if _, _, err := ParseChannel(channel); err != nil {
+		return err
+	}
+
+	removed, err := redisnet.ClearChatRestriction(ctx, restrictionMute, channel, userID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrNotMuted
+	}
+
+	auth.RecordAudit(auth.AuditEvent{
+		Type:      auth.AuditChatUnmuted,
+		ActorID:   moderatorID,
+		SubjectID: userID,
+		Metadata:  map[string]any{"channel": channel},
+	})
+
+	publish(ctx, Event{Channel: channel}, map[string]any{
+		"type":    "chat_unmuted",
+		"channel": channel,
+		"user_id": userID,
+	})
+	return nil
+}
+
+// Kick removes every socket of a user from a channel and keeps them out for a few minutes
+func Kick(ctx context.Context, moderatorID, channel, userID, reason string) error {
+	if err := checkModeration(channel, userID, reason); err != nil {
+		return err
+	}
+
+	if err := redisnet.SetChatRestriction(ctx, restrictionKick, channel, userID, kickRejoinDelay); err != nil {
+		return err
+	}
+
+	auth.RecordAudit(auth.AuditEvent{
+		Type:      auth.AuditChatKicked,
+		ActorID:   moderatorID,
+		SubjectID: userID,
+		Metadata: map[string]any{
+			"channel": channel,
+			"reason":  reason,
+		},
+	})
+
+	publish(ctx, Event{Channel: channel, Kick: userID}, map[string]any{
+		"type":    "chat_kicked",
+		"channel": channel,
+		"user_id": userID,
+		"reason":  reason,
+	})
+	return nil
+}
+
+// SetSlowMode makes every user wait interval between messages in a channel; 0 turns it off
+func SetSlowMode(ctx context.Context, moderatorID, channel string, interval time.Duration) error {
+	if _, _, err := ParseChannel(channel); err != nil {
+		return err
+	}
+	if interval < 0 || interval > MaxSlowMode {
+		return ErrInvalidSlowMode
+	}
+
+	seconds := int(interval.Seconds())
+	if err := redisnet.SetChatSlowMode(ctx, channel, seconds); err != nil {
+		return err
+	}
+
+	auth.RecordAudit(auth.AuditEvent{
+		Type:    auth.AuditChatSlowMode,
+		ActorID: moderatorID,
+		Metadata: map[string]any{
+			"channel": channel,
+			"seconds": seconds,
+		},
+	})
+
+	publish(ctx, Event{Channel: channel}, map[string]any{
+		"type":    "chat_slow_mode",
+		"channel": channel,
+		"seconds": seconds,
+	})
+	return nil
+}
+
+// ParseChannel splits a channel into its kind and name
+func ParseChannel(channel string) (string, string, error) {
+	if channel == KindGlobal {
+		return KindGlobal, "", nil
+	}
+
+	kind, name, ok := strings.Cut(channel, ":")
+	if !ok || !channelNameRegex.MatchString(name) {
+		return "", "", ErrInvalidChannel
+	}
+	switch kind {
+	case KindRegion, KindRoom, KindClan:
+		return kind, name, nil
+	}
+	return "", "", ErrInvalidChannel
+}
+
+// DecodeEvent parses a chat event received from Redis
+func DecodeEvent(data []byte) (*Event, error) {
+	var event Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// Helper functions
+
+func publish(ctx context.Context, event Event, payload any) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		logging.LogError("Failed to encode chat event: %v", err)
+		return
+	}
+	event.Payload = encoded
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		logging.LogError("Failed to encode chat event: %v", err)
+		return
+	}
+	if err := redisnet.PublishChatEvent(ctx, data); err != nil {
+		logging.LogError("Failed to publish chat event to %s: %v", event.Channel, err)
+	}
+}
+
+// checkRestriction returns a *WaitError wrapping err while the user has the restriction
+func checkRestriction(ctx context.Context, kind, channel, userID string, err error) error {
+	restricted, remaining, lookupErr := redisnet.ChatRestriction(ctx, kind, channel, userID)
+	if lookupErr != nil {
+		return lookupErr
+	}
+	if restricted {
+		return &WaitError{Err: err, RetryAfter: remaining}
+	}
+	return nil
+}
+
+// checkModeration validates the channel, the reason and the user a moderator acts on
+func checkModeration(channel, userID, reason string) error {
+	if _, _, err := ParseChannel(channel); err != nil {
+		return err
+	}
+	if utf8.RuneCountInString(reason) > MaxReasonLength {
+		return ErrReasonTooLong
+	}
+
+	user, err := auth.GetUserByID(userID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "22P02" {
+			return auth.ErrUserNotFound
+		}
+		return err
+	}
+	if user.DeletedAt != nil {
+		return auth.ErrUserNotFound
+	}
+	return nil
+}
+
+func isModerator(userID string) (bool, error) {
+	role, err := auth.GetUserRole(userID)
+	if err != nil {
+		return false, err
+	}
+	return role == auth.RoleModerator || role == auth.RoleAdmin, nil
+}
+
+// authorizeRegion admits everyone to the regions listed in chat_regions
+func authorizeRegion(ctx context.Context, userID, name string) error {
+	regions, ok := config.GetConfig(config.CONFIG_CHAT_REGIONS).(string)
+	if !ok || regions == "" {
+		regions = defaultRegions
+	}
+	for _, region := range strings.Split(regions, ",") {
+		if strings.TrimSpace(region) == name {
+			return nil
+		}
+	}
+	return ErrInvalidChannel
+}
+
+// authorizeRoom admits the players matchmaking put into the match and the spectators its game
+// server reported; presence is set by clients and never trusted here
+func authorizeRoom(ctx context.Context, userID, name string) error {
+	role, err := matchmaking.MatchRole(ctx, name, userID)
+	if err != nil {
+		return err
+	}
+	if role == "" {
+		return ErrNotAllowed
+	}
+	return nil
+}
+
+func retrySeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
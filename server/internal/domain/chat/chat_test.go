@@ -0,0 +1,47 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseChannel(t *testing.T) {
+	tests := []struct {
+		channel  string
+		wantKind string
+		wantName string
+		wantErr  bool
+	}{
+		{"global", KindGlobal, "", false},
+		{"region:eu", KindRegion, "eu", false},
+		{"room:3f2a-match_01", KindRoom, "3f2a-match_01", false},
+		{"clan:tetris", KindClan, "tetris", false},
+		{"room:" + strings.Repeat("a", 64), KindRoom, strings.Repeat("a", 64), false},
+		{"room:" + strings.Repeat("a", 65), "", "", true},
+		{"", "", "", true},
+		{"global:eu", "", "", true},
+		{"region", "", "", true},
+		{"region:", "", "", true},
+		{"team:red", "", "", true},
+		{"region:eu west", "", "", true},
+		{"room:a:b", "", "", true},
+		{"Region:eu", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.channel, func(t *testing.T) {
+			kind, name, err := ParseChannel(tt.channel)
+			if tt.wantErr {
+				if err != ErrInvalidChannel {
+					t.Errorf("ParseChannel(%q) error = %v, want ErrInvalidChannel", tt.channel, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseChannel(%q) error = %v", tt.channel, err)
+			}
+			if kind != tt.wantKind || name != tt.wantName {
+				t.Errorf("ParseChannel(%q) = %q, %q, want %q, %q", tt.channel, kind, name, tt.wantKind, tt.wantName)
+			}
+		})
+	}
+}
@@ -0,0 +1,489 @@
+package friends
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/db"
+	"TetriON.WebServer/server/internal/domain/presence"
+	"TetriON.WebServer/server/internal/logging"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Friendship states; see migrations/017_create_friendships_table.sql for the row layout
+const (
+	StatusPending  = "pending"
+	StatusAccepted = "accepted"
+	StatusBlocked  = "blocked"
+)
+
+var (
+	ErrNoTarget        = errors.New("username or user_id is required")
+	ErrSelf            = errors.New("you cannot add or block yourself")
+	ErrAlreadyFriends  = errors.New("already friends with this player")
+	ErrRequestExists   = errors.New("friend request already sent")
+	ErrRequestNotFound = errors.New("friend request not found")
+	ErrNotFriends      = errors.New("not friends with this player")
+	ErrBlocked         = errors.New("this player cannot be added")
+	ErrNotBlocked      = errors.New("this player is not blocked")
+)
+
+// Friend is another player on a user's friends, request or block list
+type Friend struct {
+	UserID      string             `json:"user_id"`
+	Username    string             `json:"username"`
+	DisplayName string             `json:"display_name"`
+	Since       time.Time          `json:"since"`
+	Online      bool               `json:"online"`
+	Presence    *presence.Presence `json:"presence,omitempty"` // friends only
+}
+
+// List is everything on a user's friends page
+type List struct {
+	Friends  []Friend `json:"friends"`
+	Incoming []Friend `json:"incoming"`
+	Outgoing []Friend `json:"outgoing"`
+}
+
+// SendRequest asks target to become userID's friend. If target already asked userID, the
+// friendship is accepted right away and the returned status is StatusAccepted.
+func SendRequest(userID, targetID string) (string, error) {
+	if db.DB == nil {
+		return "", auth.ErrDatabaseError
+	}
+	if userID == targetID {
+		return "", ErrSelf
+	}
+	if err := requireActiveUser(targetID); err != nil {
+		return "", err
+	}
+
+	blocked, err := IsBlocked(userID, targetID)
+	if err != nil {
+		return "", err
+	}
+	if blocked {
+		return "", ErrBlocked
+	}
+
+	outgoing, err := relation(userID, targetID)
+	if err != nil {
+		return "", err
+	}
+	switch outgoing {
+	case StatusAccepted:
+		return "", ErrAlreadyFriends
+	case StatusPending:
+		return "", ErrRequestExists
+	}
+
+	incoming, err := relation(targetID, userID)
+	if err != nil {
+		return "", err
+	}
+	if incoming == StatusPending {
+		if err := AcceptRequest(userID, targetID); err != nil {
+			return "", err
+		}
+		return StatusAccepted, nil
+	}
+
+	now := time.Now()
+	_, err = db.DB.Exec(context.Background(), `
+		INSERT INTO friendships (user_id, friend_id, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (user_id, friend_id) DO NOTHING
+	`, userID, targetID, StatusPending, now)
+	if err != nil {
+		return "", err
+	}
+	return StatusPending, nil
+}
+
+// AcceptRequest accepts the pending request requesterID sent to userID
+func AcceptRequest(userID, requesterID string) error {
+	if db.DB == nil {
+		return auth.ErrDatabaseError
+	}
+
+	ctx := context.Background()
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	tag, err := tx.Exec(ctx, `
+		UPDATE friendships
+		SET status = $1, updated_at = $2
+		WHERE user_id = $3 AND friend_id = $4 AND status = $5
+	`, StatusAccepted, now, requesterID, userID, StatusPending)
+	if err != nil {
+		return notFoundOnInvalidID(err, ErrRequestNotFound)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRequestNotFound
+	}
+
+	// A request the other way round becomes the reverse row
+	_, err = tx.Exec(ctx, `
+		INSERT INTO friendships (user_id, friend_id, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (user_id, friend_id) DO UPDATE
+		SET status = EXCLUDED.status, updated_at = EXCLUDED.updated_at
+	`, userID, requesterID, StatusAccepted, now)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// DeclineRequest rejects the pending request requesterID sent to userID
+func DeclineRequest(userID, requesterID string) error {
+	if db.DB == nil {
+		return auth.ErrDatabaseError
+	}
+
+	tag, err := db.DB.Exec(context.Background(), `
+		DELETE FROM friendships
+		WHERE user_id = $1 AND friend_id = $2 AND status = $3
+	`, requesterID, userID, StatusPending)
+	if err != nil {
+		return notFoundOnInvalidID(err, ErrRequestNotFound)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRequestNotFound
+	}
+	return nil
+}
+
+// Remove ends a friendship, or cancels a request userID sent to otherID
+func Remove(userID, otherID string) error {
+	if db.DB == nil {
+		return auth.ErrDatabaseError
+	}
+
+	tag, err := db.DB.Exec(context.Background(), `
+		DELETE FROM friendships
+		WHERE (user_id = $1 AND friend_id = $2 AND status IN ($3, $4))
+			OR (user_id = $2 AND friend_id = $1 AND status = $3)
+	`, userID, otherID, StatusAccepted, StatusPending)
+	if err != nil {
+		return notFoundOnInvalidID(err, ErrNotFriends)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFriends
+	}
+	return nil
+}
+
+// Block blocks targetID for userID. Any friendship or request between the two is removed;
+// a block targetID placed on userID stays in place.
+func Block(userID, targetID string) error {
+	if db.DB == nil {
+		return auth.ErrDatabaseError
+	}
+	if userID == targetID {
+		return ErrSelf
+	}
+	if err := requireActiveUser(targetID); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM friendships
+		WHERE user_id = $1 AND friend_id = $2 AND status <> $3
+	`, targetID, userID, StatusBlocked)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(ctx, `
+		INSERT INTO friendships (user_id, friend_id, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (user_id, friend_id) DO UPDATE
+		SET status = EXCLUDED.status, created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at
+	`, userID, targetID, StatusBlocked, now)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Unblock lifts a block userID placed on targetID
+func Unblock(userID, targetID string) error {
+	if db.DB == nil {
+		return auth.ErrDatabaseError
+	}
+
+	tag, err := db.DB.Exec(context.Background(), `
+		DELETE FROM friendships
+		WHERE user_id = $1 AND friend_id = $2 AND status = $3
+	`, userID, targetID, StatusBlocked)
+	if err != nil {
+		return notFoundOnInvalidID(err, ErrNotBlocked)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotBlocked
+	}
+	return nil
+}
+
+// IsBlocked reports whether either user has blocked the other. Messaging, invites and custom
+// rooms must refuse to bring the two together when it does.
+func IsBlocked(userID, otherID string) (bool, error) {
+	if db.DB == nil {
+		return false, auth.ErrDatabaseError
+	}
+
+	var blocked bool
+	err := db.DB.QueryRow(context.Background(), `
+		SELECT EXISTS (
+			SELECT 1 FROM friendships
+			WHERE status = $3
+				AND ((user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1))
+		)
+	`, userID, otherID, StatusBlocked).Scan(&blocked)
+	if err != nil {
+		return false, notFoundOnInvalidID(err, auth.ErrUserNotFound)
+	}
+	return blocked, nil
+}
+
+// blockedAmong returns which of otherIDs have blocked userID or been blocked by them, in one query
+func blockedAmong(userID string, otherIDs []string) (map[string]bool, error) {
+	if db.DB == nil {
+		return nil, auth.ErrDatabaseError
+	}
+
+	rows, err := db.DB.Query(context.Background(), `
+		SELECT CASE WHEN user_id = $1 THEN friend_id ELSE user_id END
+		FROM friendships
+		WHERE status = $2
+			AND ((user_id = $1 AND friend_id::text = ANY($3)) OR (friend_id = $1 AND user_id::text = ANY($3)))
+	`, userID, StatusBlocked, otherIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocked := map[string]bool{}
+	for rows.Next() {
+		var otherID string
+		if err := rows.Scan(&otherID); err != nil {
+			return nil, err
+		}
+		blocked[otherID] = true
+	}
+	return blocked, rows.Err()
+}
+
+// LookupPresence returns the presence of several players as viewerID may see it. Players with
+// a block between them and the viewer are reported as offline, like invisible ones.
+func LookupPresence(ctx context.Context, viewerID string, userIDs []string) (map[string]presence.Presence, error) {
+	blocked, err := blockedAmong(viewerID, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	presences, err := presence.Lookup(ctx, viewerID, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	for userID := range blocked {
+		if _, ok := presences[userID]; ok {
+			presences[userID] = presence.Visible(nil, false)
+		}
+	}
+	return presences, nil
+}
+
+// AreFriends reports whether the two users are friends
+func AreFriends(userID, otherID string) (bool, error) {
+	status, err := relation(userID, otherID)
+	if err != nil {
+		return false, err
+	}
+	return status == StatusAccepted, nil
+}
+
+// FriendIDs returns the user IDs of a user's friends
+func FriendIDs(userID string) ([]string, error) {
+	if db.DB == nil {
+		return nil, auth.ErrDatabaseError
+	}
+
+	rows, err := db.DB.Query(context.Background(), `
+		SELECT friend_id
+		FROM friendships
+		WHERE user_id = $1 AND status = $2
+	`, userID, StatusAccepted)
+	if err != nil {
+		return nil, notFoundOnInvalidID(err, auth.ErrUserNotFound)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ListFriends returns a user's friends with their presence, read from Redis in one round trip, and the pending requests in both directions
+func ListFriends(userID string) (*List, error) {
+	if db.DB == nil {
+		return nil, auth.ErrDatabaseError
+	}
+
+	friends, err := query(`
+		SELECT u.id, u.username, p.display_name, f.updated_at
+		FROM friendships f
+		JOIN users u ON u.id = f.friend_id
+		LEFT JOIN profiles p ON p.user_id = u.id
+		WHERE f.user_id = $1 AND f.status = $2 AND u.deleted_at IS NULL
+		ORDER BY lower(u.username)
+	`, userID, StatusAccepted)
+	if err != nil {
+		return nil, err
+	}
+	incoming, err := query(`
+		SELECT u.id, u.username, p.display_name, f.created_at
+		FROM friendships f
+		JOIN users u ON u.id = f.user_id
+		LEFT JOIN profiles p ON p.user_id = u.id
+		WHERE f.friend_id = $1 AND f.status = $2 AND u.deleted_at IS NULL
+		ORDER BY f.created_at DESC
+	`, userID, StatusPending)
+	if err != nil {
+		return nil, err
+	}
+	outgoing, err := query(`
+		SELECT u.id, u.username, p.display_name, f.created_at
+		FROM friendships f
+		JOIN users u ON u.id = f.friend_id
+		LEFT JOIN profiles p ON p.user_id = u.id
+		WHERE f.user_id = $1 AND f.status = $2 AND u.deleted_at IS NULL
+		ORDER BY f.created_at DESC
+	`, userID, StatusPending)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(friends))
+	for i, friend := range friends {
+		ids[i] = friend.UserID
+	}
+	presences, err := presence.Lookup(context.Background(), userID, ids)
+	if err != nil {
+		// The list is still useful without presence
+		logging.LogError("Failed to read presence of friends of %s: %v", userID, err)
+	}
+	for i := range friends {
+		if current, ok := presences[friends[i].UserID]; ok {
+			friends[i].Presence = &current
+			friends[i].Online = current.Status != presence.StatusOffline
+		}
+	}
+
+	return &List{Friends: friends, Incoming: incoming, Outgoing: outgoing}, nil
+}
+
+// ListBlocked returns the players userID has blocked, newest first
+func ListBlocked(userID string) ([]Friend, error) {
+	if db.DB == nil {
+		return nil, auth.ErrDatabaseError
+	}
+
+	return query(`
+		SELECT u.id, u.username, p.display_name, f.created_at
+		FROM friendships f
+		JOIN users u ON u.id = f.friend_id
+		LEFT JOIN profiles p ON p.user_id = u.id
+		WHERE f.user_id = $1 AND f.status = $2 AND u.deleted_at IS NULL
+		ORDER BY f.created_at DESC
+	`, userID, StatusBlocked)
+}
+
+// Helper functions
+
+// relation returns the status of the row from userID to otherID, or "" when there is none
+func relation(userID, otherID string) (string, error) {
+	if db.DB == nil {
+		return "", auth.ErrDatabaseError
+	}
+
+	var status string
+	err := db.DB.QueryRow(context.Background(), `
+		SELECT status FROM friendships
+		WHERE user_id = $1 AND friend_id = $2
+	`, userID, otherID).Scan(&status)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", notFoundOnInvalidID(err, auth.ErrUserNotFound)
+	}
+	return status, nil
+}
+
+func query(sql string, args ...any) ([]Friend, error) {
+	rows, err := db.DB.Query(context.Background(), sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	friends := []Friend{}
+	for rows.Next() {
+		var friend Friend
+		var displayName *string
+		if err := rows.Scan(&friend.UserID, &friend.Username, &displayName, &friend.Since); err != nil {
+			return nil, err
+		}
+		friend.DisplayName = friend.Username
+		if displayName != nil && *displayName != "" {
+			friend.DisplayName = *displayName
+		}
+		friends = append(friends, friend)
+	}
+	return friends, rows.Err()
+}
+
+func requireActiveUser(userID string) error {
+	user, err := auth.GetUserByID(userID)
+	if err != nil {
+		return notFoundOnInvalidID(err, auth.ErrUserNotFound)
+	}
+	if user.DeletedAt != nil {
+		return auth.ErrUserNotFound
+	}
+	return nil
+}
+
+// notFoundOnInvalidID turns the error Postgres raises for a malformed UUID into notFound
+func notFoundOnInvalidID(err, notFound error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "22P02" {
+		return notFound
+	}
+	return err
+}
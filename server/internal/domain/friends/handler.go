@@ -0,0 +1,304 @@
+package friends
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/logging"
+	"TetriON.WebServer/server/internal/middleware"
+)
+
+const maxPresenceLookup = 100
+
+// targetRequest names another player by username or user ID
+type targetRequest struct {
+	Username string `json:"username"`
+	UserID   string `json:"user_id"`
+}
+
+// FriendsHandler lists the caller's friends with online status and pending requests (GET /api/friends)
+func FriendsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, "missing authorization token", http.StatusUnauthorized)
+		return
+	}
+
+	list, err := ListFriends(user.UserID)
+	if err != nil {
+		respondFriendError(w, "list friends of "+user.Username, err)
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"success":  true,
+		"friends":  list.Friends,
+		"incoming": list.Incoming,
+		"outgoing": list.Outgoing,
+	}, http.StatusOK)
+}
+
+// FriendRequestsHandler sends a friend request (POST /api/friends/requests) to the player named
+// by username or user_id
+func FriendRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, "missing authorization token", http.StatusUnauthorized)
+		return
+	}
+
+	targetID, err := decodeTarget(r)
+	if err != nil {
+		respondFriendError(w, "send friend request", err)
+		return
+	}
+
+	status, err := SendRequest(user.UserID, targetID)
+	if err != nil {
+		respondFriendError(w, "send friend request", err)
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"success": true,
+		"user_id": targetID,
+		"status":  status,
+	}, http.StatusOK)
+}
+
+// FriendRequestActionHandler accepts or declines a received friend request
+// (POST /api/friends/requests/{user_id}/{action}, action is accept or decline)
+func FriendRequestActionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, "missing authorization token", http.StatusUnauthorized)
+		return
+	}
+
+	requesterID := r.PathValue("user_id")
+	var err error
+	switch r.PathValue("action") {
+	case "accept":
+		err = AcceptRequest(user.UserID, requesterID)
+	case "decline":
+		err = DeclineRequest(user.UserID, requesterID)
+	default:
+		writeError(w, "Unknown action", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		respondFriendError(w, "answer friend request", err)
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"success": true,
+	}, http.StatusOK)
+}
+
+// FriendHandler removes a friend or cancels a sent request (DELETE /api/friends/{user_id})
+func FriendHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, "missing authorization token", http.StatusUnauthorized)
+		return
+	}
+
+	if err := Remove(user.UserID, r.PathValue("user_id")); err != nil {
+		respondFriendError(w, "remove friend", err)
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"success": true,
+	}, http.StatusOK)
+}
+
+// BlocksHandler lists (GET) the players the caller blocked or blocks (POST) the player named by
+// username or user_id (/api/blocks)
+func BlocksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, "missing authorization token", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		blocked, err := ListBlocked(user.UserID)
+		if err != nil {
+			respondFriendError(w, "list blocks of "+user.Username, err)
+			return
+		}
+		writeJSON(w, map[string]any{
+			"success": true,
+			"blocked": blocked,
+		}, http.StatusOK)
+		return
+	}
+
+	targetID, err := decodeTarget(r)
+	if err != nil {
+		respondFriendError(w, "block player", err)
+		return
+	}
+	if err := Block(user.UserID, targetID); err != nil {
+		respondFriendError(w, "block player", err)
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"success": true,
+		"user_id": targetID,
+	}, http.StatusOK)
+}
+
+// BlockHandler unblocks a player (DELETE /api/blocks/{user_id})
+func BlockHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, "missing authorization token", http.StatusUnauthorized)
+		return
+	}
+
+	if err := Unblock(user.UserID, r.PathValue("user_id")); err != nil {
+		respondFriendError(w, "unblock player", err)
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"success": true,
+	}, http.StatusOK)
+}
+
+// PresenceHandler returns the presence of up to 100 players
+// (GET /api/presence?user_ids=<id>,<id>,...). Invisible players are reported as offline,
+// except to themselves, and so are players with a block between them and the caller.
+func PresenceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, "missing authorization token", http.StatusUnauthorized)
+		return
+	}
+
+	userIDs := []string{}
+	seen := map[string]bool{}
+	for _, id := range strings.Split(r.URL.Query().Get("user_ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			userIDs = append(userIDs, id)
+		}
+	}
+	if len(userIDs) == 0 {
+		writeError(w, "user_ids is required", http.StatusBadRequest)
+		return
+	}
+	if len(userIDs) > maxPresenceLookup {
+		writeError(w, "at most 100 user_ids can be looked up at once", http.StatusBadRequest)
+		return
+	}
+
+	presences, err := LookupPresence(r.Context(), user.UserID, userIDs)
+	if err != nil {
+		logging.LogError("Failed to look up presence: %v", err)
+		writeError(w, "Failed to look up presence", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"success":  true,
+		"presence": presences,
+	}, http.StatusOK)
+}
+
+// Helper functions
+
+// decodeTarget resolves the player named in the request body to a user ID
+func decodeTarget(r *http.Request) (string, error) {
+	var req targetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return "", ErrNoTarget
+	}
+
+	if req.UserID != "" {
+		return strings.TrimSpace(req.UserID), nil
+	}
+	if req.Username == "" {
+		return "", ErrNoTarget
+	}
+	target, err := auth.GetUserByUsername(strings.TrimSpace(req.Username))
+	if err != nil {
+		return "", err
+	}
+	if target.DeletedAt != nil {
+		return "", auth.ErrUserNotFound
+	}
+	return target.ID, nil
+}
+
+func respondFriendError(w http.ResponseWriter, action string, err error) {
+	switch err {
+	case ErrNoTarget, ErrSelf:
+		writeError(w, err.Error(), http.StatusBadRequest)
+	case auth.ErrUserNotFound:
+		writeError(w, "Player not found", http.StatusNotFound)
+	case ErrRequestNotFound, ErrNotFriends, ErrNotBlocked:
+		writeError(w, err.Error(), http.StatusNotFound)
+	case ErrAlreadyFriends, ErrRequestExists:
+		writeError(w, err.Error(), http.StatusConflict)
+	case ErrBlocked:
+		writeError(w, err.Error(), http.StatusForbidden)
+	default:
+		logging.LogError("Failed to %s: %v", action, err)
+		writeError(w, "Friend operation failed", http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, data any, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, message string, status int) {
+	writeJSON(w, map[string]any{
+		"success": false,
+		"error":   message,
+	}, status)
+}
@@ -0,0 +1,80 @@
+package gameserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"TetriON.WebServer/server/internal/logging"
+)
+
+// nodes holds the game servers that registered with this web server instance
+var nodes = NewRegistry()
+
+type nodeRequest struct {
+	Address     string `json:"address"`
+	Capacity    int    `json:"capacity"`
+	CurrentLoad int    `json:"current_load"`
+}
+
+// NodesHandler lists the registered game servers (GET /api/gameservers)
+func NodesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"success": true,
+		"nodes":   nodes.List(),
+	}, http.StatusOK)
+}
+
+// NodeHandler registers a game server or records its heartbeat (PUT /api/gameservers/{id})
+// and deregisters it (DELETE /api/gameservers/{id})
+func NodeHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSpace(r.PathValue("id"))
+	if id == "" || len(id) > 100 {
+		writeError(w, "invalid game server id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var req nodeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Address == "" || req.Capacity < 0 || req.CurrentLoad < 0 {
+			writeError(w, "address is required and capacity and current_load cannot be negative", http.StatusBadRequest)
+			return
+		}
+
+		nodes.Upsert(Node{ID: id, Address: req.Address, Capacity: req.Capacity, CurrentLoad: req.CurrentLoad})
+		writeJSON(w, map[string]any{
+			"success": true,
+		}, http.StatusOK)
+	case http.MethodDelete:
+		nodes.Remove(id)
+		logging.LogInfo("Game server %s deregistered", id)
+		writeJSON(w, map[string]any{
+			"success": true,
+		}, http.StatusOK)
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, data any, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, message string, status int) {
+	writeJSON(w, map[string]any{
+		"success": false,
+		"error":   message,
+	}, status)
+}
@@ -0,0 +1,88 @@
+package matchmaking
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"TetriON.WebServer/server/internal/logging"
+)
+
+// maxMatchPlayers bounds the players a single match report may name
+const maxMatchPlayers = 64
+
+type matchEndRequest struct {
+	Queue   string   `json:"queue"`
+	Players []string `json:"players"`
+}
+
+// MatchEndHandler lets a game server report that a match finished (POST /api/matches/{id}/end);
+// its players return to the menu
+func MatchEndHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req matchEndRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Players) == 0 || len(req.Players) > maxMatchPlayers {
+		writeError(w, "players must name 1 to 64 user IDs", http.StatusBadRequest)
+		return
+	}
+
+	matchID := r.PathValue("id")
+	NewManager(req.Queue).MatchEnded(r.Context(), matchID, req.Players...)
+
+	logging.LogInfo("Match %s ended with %d player(s)", matchID, len(req.Players))
+	writeJSON(w, map[string]any{
+		"success": true,
+	}, http.StatusOK)
+}
+
+// MatchSpectatorHandler lets a game server report a spectator joining (PUT) or leaving (DELETE)
+// a running match (/api/matches/{id}/spectators/{user_id})
+func MatchSpectatorHandler(w http.ResponseWriter, r *http.Request) {
+	matchID, userID := r.PathValue("id"), r.PathValue("user_id")
+
+	var err error
+	switch r.Method {
+	case http.MethodPut:
+		err = SpectatorJoined(r.Context(), matchID, userID)
+	case http.MethodDelete:
+		err = SpectatorLeft(r.Context(), matchID, userID)
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err == ErrMatchNotFound {
+		writeError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.LogError("Failed to update spectators of match %s: %v", matchID, err)
+		writeError(w, "Failed to update spectators", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"success": true,
+	}, http.StatusOK)
+}
+
+// Helper functions
+
+func writeJSON(w http.ResponseWriter, data any, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, message string, status int) {
+	writeJSON(w, map[string]any{
+		"success": false,
+		"error":   message,
+	}, status)
+}
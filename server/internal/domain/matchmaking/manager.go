@@ -2,27 +2,287 @@ package matchmaking
 
 import (
 	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
 
+	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/config"
+	"TetriON.WebServer/server/internal/db"
+	"TetriON.WebServer/server/internal/domain/party"
+	"TetriON.WebServer/server/internal/domain/presence"
+	"TetriON.WebServer/server/internal/logging"
 	redisnet "TetriON.WebServer/server/internal/net/redis"
 )
 
+var (
+	ErrEmailNotVerified = errors.New("email must be verified to join ranked matchmaking")
+	ErrGuestNotAllowed  = errors.New("guest accounts can only join unranked matchmaking")
+	ErrInParty          = errors.New("your party leader queues for the whole party")
+	ErrInvalidQueue     = errors.New("queue names are 1 to 64 letters, digits, '-' or '_'")
+	ErrMatchNotFound    = errors.New("match not found")
+	ErrMemberQueued     = errors.New("a party member is already queued alone")
+)
+
+// Roles in a running match
+const (
+	RolePlayer    = "player"
+	RoleSpectator = "spectator"
+)
+
+const (
+	// defaultRankedQueues applies when ranked_queues is not configured
+	defaultRankedQueues = "ranked"
+	// matchStateTTL bounds how long a match whose end is never reported is kept
+	matchStateTTL = 6 * time.Hour
+	// defaultRating is the skill of players without a rating in the queue's mode; it matches
+	// the player_ratings column default
+	defaultRating = 1500
+)
+
+var queueNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidQueueName reports whether a queue name taken from a client is acceptable
+func ValidQueueName(name string) bool {
+	return queueNameRegex.MatchString(name)
+}
+
 type Manager struct {
 	queueName string
+	ranked    bool
 }
 
+// NewManager creates a manager for a queue. Queues named in ranked_queues are ranked, so the
+// ranked gate applies however the manager was created.
 func NewManager(queueName string) *Manager {
 	if queueName == "" {
 		queueName = "default"
 	}
-	return &Manager{queueName: queueName}
+	return &Manager{queueName: queueName, ranked: isRankedQueue(queueName)}
+}
+
+// NewRankedManager creates a manager for a ranked queue, which only accepts verified, registered accounts.
+func NewRankedManager(queueName string) *Manager {
+	m := NewManager(queueName)
+	m.ranked = true
+	return m
+}
+
+func (m *Manager) Ranked() bool {
+	return m.ranked
 }
 
 func (m *Manager) Enqueue(ctx context.Context, userID string, skill int) error {
-	return redisnet.EnqueuePlayer(ctx, m.queueName, userID, skill)
+	if err := m.checkEligible(userID); err != nil {
+		return err
+	}
+	p, err := party.Get(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if p != nil && len(p.Members) > 1 {
+		return ErrInParty
+	}
+	if err := redisnet.EnqueueSoloPlayer(ctx, m.queueName, userID, skill); err != nil {
+		return err
+	}
+	if err := presence.SetActivity(ctx, userID, presence.ActivityQueueing, m.queueName, ""); err != nil {
+		logging.LogError("Failed to update presence of %s after joining %s: %v", userID, m.queueName, err)
+	}
+	return nil
 }
 
 func (m *Manager) Dequeue(ctx context.Context, userID string) error {
-	return redisnet.RemovePlayerFromQueue(ctx, m.queueName, userID)
+	if err := redisnet.RemoveSoloPlayer(ctx, m.queueName, userID); err != nil {
+		return err
+	}
+	if err := presence.EndActivity(ctx, userID, presence.ActivityQueueing); err != nil {
+		logging.LogError("Failed to update presence of %s after leaving %s: %v", userID, m.queueName, err)
+	}
+	return nil
+}
+
+// EnqueueParty puts the leader's whole party into the queue as one ticket. The ticket's skill
+// aggregates the members' ratings in the queue's mode. The ticket leaves the queue when the
+// roster changes or any member disconnects.
+func (m *Manager) EnqueueParty(ctx context.Context, leaderID string) (*party.Party, error) {
+	p, err := party.Get(ctx, leaderID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, party.ErrNotInParty
+	}
+	if p.LeaderID != leaderID {
+		return nil, party.ErrNotLeader
+	}
+
+	ratings, err := m.ratings(ctx, p.Members)
+	if err != nil {
+		return nil, err
+	}
+
+	memberSkills := make([]int, 0, len(p.Members))
+	for _, memberID := range p.Members {
+		if err := m.checkEligible(memberID); err != nil {
+			return nil, err
+		}
+		// A member queued alone would otherwise be matched twice
+		queues, err := redisnet.SoloQueues(ctx, memberID)
+		if err != nil {
+			return nil, err
+		}
+		if len(queues) > 0 {
+			return nil, ErrMemberQueued
+		}
+		memberSkills = append(memberSkills, ratings[memberID])
+	}
+
+	p, err = party.MarkQueued(ctx, leaderID, m.queueName, p.Members)
+	if err != nil {
+		return nil, err
+	}
+	if err := redisnet.EnqueuePlayer(ctx, m.queueName, p.Ticket(), PartySkill(memberSkills)); err != nil {
+		if _, leaveErr := party.LeaveQueue(ctx, p.ID, party.DequeueLeader); leaveErr != nil {
+			logging.LogError("Failed to reset queue state of party %s: %v", p.ID, leaveErr)
+		}
+		return nil, err
+	}
+
+	for _, memberID := range p.Members {
+		if err := presence.SetActivity(ctx, memberID, presence.ActivityQueueing, m.queueName, ""); err != nil {
+			logging.LogError("Failed to update presence of %s after joining %s: %v", memberID, m.queueName, err)
+		}
+	}
+	return p, nil
+}
+
+// DequeueParty takes the leader's party out of the queue
+func (m *Manager) DequeueParty(ctx context.Context, leaderID string) error {
+	p, err := party.Get(ctx, leaderID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return party.ErrNotInParty
+	}
+	if p.LeaderID != leaderID {
+		return party.ErrNotLeader
+	}
+	if p.Queue != m.queueName {
+		return party.ErrNotQueued
+	}
+
+	_, err = party.LeaveQueue(ctx, p.ID, party.DequeueLeader)
+	return err
+}
+
+// MatchFound moves matched tickets, as returned by Candidates, from the queue into the match.
+// The match's players are recorded so that only they can join its chat room.
+func (m *Manager) MatchFound(ctx context.Context, matchID string, tickets ...string) error {
+	userIDs, err := m.takeTickets(ctx, tickets)
+	if err != nil {
+		return err
+	}
+	if err := redisnet.SetMatchParticipants(ctx, matchID, userIDs, RolePlayer, matchStateTTL); err != nil {
+		logging.LogError("Failed to record the players of match %s: %v", matchID, err)
+	}
+	for _, userID := range userIDs {
+		if err := presence.SetActivity(ctx, userID, presence.ActivityInMatch, m.queueName, matchID); err != nil {
+			logging.LogError("Failed to update presence of %s for match %s: %v", userID, matchID, err)
+		}
+	}
+	return nil
+}
+
+// TicketPlayers returns the players a queue ticket stands for: the user for a solo ticket,
+// every member for a party ticket
+func (m *Manager) TicketPlayers(ctx context.Context, ticket string) ([]string, error) {
+	partyID, ok := strings.CutPrefix(ticket, party.TicketPrefix)
+	if !ok {
+		return []string{ticket}, nil
+	}
+	p, err := party.Load(ctx, partyID)
+	if err != nil {
+		return nil, err
+	}
+	return p.Members, nil
+}
+
+// PartySkill aggregates member skills into a ticket skill halfway between the mean and the
+// strongest member, so a strong player cannot be matched low by bringing weaker friends
+func PartySkill(skills []int) int {
+	if len(skills) == 0 {
+		return 0
+	}
+	sum, highest := 0, skills[0]
+	for _, skill := range skills {
+		sum += skill
+		highest = max(highest, skill)
+	}
+	mean := float64(sum) / float64(len(skills))
+	return int(mean + (float64(highest)-mean)/2 + 0.5)
+}
+
+// MatchEnded forgets a finished match and returns its players to the menu
+func (m *Manager) MatchEnded(ctx context.Context, matchID string, userIDs ...string) {
+	if err := redisnet.EndMatch(ctx, matchID); err != nil {
+		logging.LogError("Failed to end match %s: %v", matchID, err)
+	}
+	for _, userID := range userIDs {
+		if err := presence.EndActivity(ctx, userID, presence.ActivityInMatch); err != nil {
+			logging.LogError("Failed to update presence of %s after a match: %v", userID, err)
+		}
+	}
+}
+
+// SpectatorJoined records a spectator of a running match, as reported by its game server
+func SpectatorJoined(ctx context.Context, matchID, userID string) error {
+	exists, err := redisnet.MatchExists(ctx, matchID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrMatchNotFound
+	}
+	role, err := redisnet.MatchRole(ctx, matchID, userID)
+	if err != nil {
+		return err
+	}
+	if role == RolePlayer {
+		return nil
+	}
+
+	if err := redisnet.SetMatchParticipants(ctx, matchID, []string{userID}, RoleSpectator, matchStateTTL); err != nil {
+		return err
+	}
+	if err := presence.SetActivity(ctx, userID, presence.ActivitySpectating, "", matchID); err != nil {
+		logging.LogError("Failed to update presence of %s spectating %s: %v", userID, matchID, err)
+	}
+	return nil
+}
+
+// SpectatorLeft forgets a spectator of a match
+func SpectatorLeft(ctx context.Context, matchID, userID string) error {
+	role, err := redisnet.MatchRole(ctx, matchID, userID)
+	if err != nil || role != RoleSpectator {
+		return err
+	}
+
+	if err := redisnet.RemoveMatchParticipant(ctx, matchID, userID); err != nil {
+		return err
+	}
+	if err := presence.EndActivity(ctx, userID, presence.ActivitySpectating); err != nil {
+		logging.LogError("Failed to update presence of %s after spectating %s: %v", userID, matchID, err)
+	}
+	return nil
+}
+
+// MatchRole returns what a user does in a running match: RolePlayer, RoleSpectator, or "" when
+// they take no part in it
+func MatchRole(ctx context.Context, matchID, userID string) (string, error) {
+	return redisnet.MatchRole(ctx, matchID, userID)
 }
 
 func (m *Manager) Candidates(ctx context.Context, limit int64) ([]string, error) {
@@ -32,3 +292,93 @@ func (m *Manager) Candidates(ctx context.Context, limit int64) ([]string, error)
 func (m *Manager) Size(ctx context.Context) (int64, error) {
 	return redisnet.QueueSize(ctx, m.queueName)
 }
+
+// takeTickets removes tickets from the queue and returns the players they stood for
+func (m *Manager) takeTickets(ctx context.Context, tickets []string) ([]string, error) {
+	userIDs := []string{}
+	for _, ticket := range tickets {
+		partyID, ok := strings.CutPrefix(ticket, party.TicketPrefix)
+		if !ok {
+			if err := redisnet.RemoveSoloPlayer(ctx, m.queueName, ticket); err != nil {
+				return nil, err
+			}
+			userIDs = append(userIDs, ticket)
+			continue
+		}
+
+		p, err := party.LeaveQueue(ctx, partyID, party.DequeueMatchFound)
+		if err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, p.Members...)
+	}
+	return userIDs, nil
+}
+
+// ratings returns the rating of every user in the queue's mode, defaultRating when they have none
+func (m *Manager) ratings(ctx context.Context, userIDs []string) (map[string]int, error) {
+	if db.DB == nil {
+		return nil, auth.ErrDatabaseError
+	}
+
+	ratings := make(map[string]int, len(userIDs))
+	for _, userID := range userIDs {
+		ratings[userID] = defaultRating
+	}
+
+	rows, err := db.DB.Query(ctx, `
+		SELECT user_id, rating
+		FROM player_ratings
+		WHERE mode = $1 AND user_id::text = ANY($2)
+	`, m.queueName, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID string
+		var rating int
+		if err := rows.Scan(&userID, &rating); err != nil {
+			return nil, err
+		}
+		ratings[userID] = rating
+	}
+	return ratings, rows.Err()
+}
+
+// isRankedQueue reports whether ranked_queues names the queue
+func isRankedQueue(queueName string) bool {
+	queues, ok := config.GetConfig(config.CONFIG_RANKED_QUEUES).(string)
+	if !ok || queues == "" {
+		queues = defaultRankedQueues
+	}
+	for _, queue := range strings.Split(queues, ",") {
+		if strings.TrimSpace(queue) == queueName {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) checkEligible(userID string) error {
+	if !m.ranked {
+		// Login bans cover every queue; returns an *auth.BanError
+		return auth.CheckBan(userID, auth.BanScopeLogin)
+	}
+	if err := auth.CheckBan(userID, auth.BanScopeRanked); err != nil {
+		return err
+	}
+
+	user, err := auth.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.IsGuest {
+		return ErrGuestNotAllowed
+	}
+	if !user.EmailVerified {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package matchmaking
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPartySkill(t *testing.T) {
+	tests := []struct {
+		name   string
+		skills []int
+		want   int
+	}{
+		{"no members", nil, 0},
+		{"solo", []int{1500}, 1500},
+		{"equal members", []int{1200, 1200, 1200}, 1200},
+		{"halfway between mean and strongest", []int{1000, 2000}, 1750},
+		{"weak friends do not drag a strong player down", []int{2400, 800, 800, 800}, 1800},
+		{"rounds to nearest", []int{1000, 1001}, 1001},
+		{"order does not matter", []int{800, 800, 2400, 800}, 1800},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PartySkill(tt.skills); got != tt.want {
+				t.Errorf("PartySkill(%v) = %d, want %d", tt.skills, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidQueueName(t *testing.T) {
+	tests := map[string]bool{
+		"ranked":                true,
+		"casual_2v2":            true,
+		"blitz-eu":              true,
+		"":                      false,
+		"ranked queue":          false,
+		"mm:queue:ranked":       false,
+		"party:abc":             false,
+		"ранг":                  false,
+		strings.Repeat("a", 64): true,
+		strings.Repeat("a", 65): false,
+	}
+	for name, want := range tests {
+		if got := ValidQueueName(name); got != want {
+			t.Errorf("ValidQueueName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package messages
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/logging"
+	"TetriON.WebServer/server/internal/middleware"
+)
+
+// ConversationHandler returns the history with another player (GET) or sends them a message
+// (POST) (/api/messages/{user_id}). History is paged with ?before=<message id>&limit=<n>.
+func ConversationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, "missing authorization token", http.StatusUnauthorized)
+		return
+	}
+	otherID := r.PathValue("user_id")
+
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		before, _ := strconv.ParseInt(query.Get("before"), 10, 64)
+		limit, _ := strconv.Atoi(query.Get("limit"))
+
+		history, err := History(user.UserID, otherID, before, limit)
+		if err != nil {
+			respondMessageError(w, "load messages of "+user.Username, err)
+			return
+		}
+
+		response := map[string]any{
+			"success":  true,
+			"messages": history,
+		}
+		if len(history) > 0 {
+			response["next_before"] = history[len(history)-1].ID
+		}
+		writeJSON(w, response, http.StatusOK)
+		return
+	}
+
+	var req struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	msg, err := Send(user.UserID, otherID, req.Body)
+	if err != nil {
+		respondMessageError(w, "send message from "+user.Username, err)
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"success": true,
+		"message": msg,
+	}, http.StatusCreated)
+}
+
+// ReadHandler marks the messages received from another player as read
+// (POST /api/messages/{user_id}/read). An optional up_to message ID limits the receipt.
+func ReadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, "missing authorization token", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		UpTo int64 `json:"up_to"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	count, lastID, err := MarkRead(user.UserID, r.PathValue("user_id"), req.UpTo)
+	if err != nil {
+		respondMessageError(w, "mark messages read for "+user.Username, err)
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"success":      true,
+		"read":         count,
+		"last_read_id": lastID,
+	}, http.StatusOK)
+}
+
+// Helper functions
+
+func respondMessageError(w http.ResponseWriter, action string, err error) {
+	var banErr *auth.BanError
+	if errors.As(err, &banErr) {
+		writeJSON(w, map[string]any{
+			"success": false,
+			"error":   auth.ErrBanned.Error(),
+			"ban":     banErr,
+		}, http.StatusForbidden)
+		return
+	}
+
+	switch err {
+	case ErrEmptyBody, ErrBodyTooLong, ErrSelf:
+		writeError(w, err.Error(), http.StatusBadRequest)
+	case auth.ErrUserNotFound:
+		writeError(w, "Player not found", http.StatusNotFound)
+	case ErrBlocked:
+		writeError(w, err.Error(), http.StatusForbidden)
+	default:
+		logging.LogError("Failed to %s: %v", action, err)
+		writeError(w, "Message operation failed", http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, data any, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, message string, status int) {
+	writeJSON(w, map[string]any{
+		"success": false,
+		"error":   message,
+	}, status)
+}
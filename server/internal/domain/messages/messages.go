@@ -0,0 +1,225 @@
+package messages
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/db"
+	"TetriON.WebServer/server/internal/domain/friends"
+	"TetriON.WebServer/server/internal/domain/notify"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	MaxBodyLength       = 2000
+	DefaultHistoryLimit = 50
+	MaxHistoryLimit     = 100
+)
+
+var (
+	ErrEmptyBody   = errors.New("message must not be empty")
+	ErrBodyTooLong = fmt.Errorf("message must be at most %d characters", MaxBodyLength)
+	ErrSelf        = errors.New("you cannot message yourself")
+	ErrBlocked     = errors.New("you cannot message this player")
+)
+
+// Message is a direct message between two players
+type Message struct {
+	ID          int64      `json:"id"`
+	SenderID    string     `json:"sender_id"`
+	RecipientID string     `json:"recipient_id"`
+	Body        string     `json:"body"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	ReadAt      *time.Time `json:"read_at,omitempty"`
+}
+
+// Send stores a direct message and pushes it to every socket of the recipient and the sender.
+// A recipient without a socket receives it when they next connect.
+func Send(senderID, recipientID, body string) (*Message, error) {
+	if db.DB == nil {
+		return nil, auth.ErrDatabaseError
+	}
+
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, ErrEmptyBody
+	}
+	if utf8.RuneCountInString(body) > MaxBodyLength {
+		return nil, ErrBodyTooLong
+	}
+	if senderID == recipientID {
+		return nil, ErrSelf
+	}
+
+	if err := auth.CheckBan(senderID, auth.BanScopeChat); err != nil {
+		return nil, err
+	}
+	if err := requireActiveUser(recipientID); err != nil {
+		return nil, err
+	}
+	blocked, err := friends.IsBlocked(senderID, recipientID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrBlocked
+	}
+
+	msg := &Message{SenderID: senderID, RecipientID: recipientID, Body: body}
+	err = db.DB.QueryRow(context.Background(), `
+		INSERT INTO direct_messages (sender_id, recipient_id, body)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`, senderID, recipientID, body).Scan(&msg.ID, &msg.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// A recipient who misses the event gets the message when they next connect
+	notify.Publish(notify.Event{UserIDs: []string{recipientID, senderID}, DeliveryOf: msg.ID}, map[string]any{
+		"type":    "dm",
+		"message": msg,
+	})
+	return msg, nil
+}
+
+// History returns the conversation between two users, newest first. Passing the ID of the
+// oldest message received as before pages further back; 0 starts at the newest.
+func History(userID, otherID string, before int64, limit int) ([]Message, error) {
+	if db.DB == nil {
+		return nil, auth.ErrDatabaseError
+	}
+	if limit <= 0 || limit > MaxHistoryLimit {
+		limit = DefaultHistoryLimit
+	}
+
+	rows, err := db.DB.Query(context.Background(), `
+		SELECT id, sender_id, recipient_id, body, created_at, delivered_at, read_at
+		FROM direct_messages
+		WHERE LEAST(sender_id, recipient_id) = LEAST($1::uuid, $2::uuid)
+			AND GREATEST(sender_id, recipient_id) = GREATEST($1::uuid, $2::uuid)
+			AND ($3::bigint = 0 OR id < $3)
+		ORDER BY id DESC
+		LIMIT $4
+	`, userID, otherID, before, limit)
+	if err != nil {
+		return nil, notFoundOnInvalidID(err)
+	}
+	return scanMessages(rows)
+}
+
+// MarkRead marks the messages otherID sent to userID up to and including upTo as read
+// (every unread one when upTo is 0) and sends a read receipt to both users. It returns how
+// many messages were marked and the highest ID among them.
+func MarkRead(userID, otherID string, upTo int64) (int64, int64, error) {
+	if db.DB == nil {
+		return 0, 0, auth.ErrDatabaseError
+	}
+
+	now := time.Now()
+	var count, lastID int64
+	err := db.DB.QueryRow(context.Background(), `
+		WITH updated AS (
+			UPDATE direct_messages
+			SET read_at = $4, delivered_at = COALESCE(delivered_at, $4)
+			WHERE recipient_id = $1 AND sender_id = $2 AND read_at IS NULL
+				AND ($3::bigint = 0 OR id <= $3)
+			RETURNING id
+		)
+		SELECT COUNT(*), COALESCE(MAX(id), 0) FROM updated
+	`, userID, otherID, upTo, now).Scan(&count, &lastID)
+	if err != nil {
+		return 0, 0, notFoundOnInvalidID(err)
+	}
+
+	if count > 0 {
+		notify.Users([]string{otherID, userID}, map[string]any{
+			"type":         "dm_read",
+			"reader_id":    userID,
+			"sender_id":    otherID,
+			"last_read_id": lastID,
+			"read_at":      now,
+		})
+	}
+	return count, lastID, nil
+}
+
+// Pending returns up to limit messages that were sent to a user while none of their sockets
+// was connected, oldest first
+func Pending(userID string, limit int) ([]Message, error) {
+	if db.DB == nil {
+		return nil, auth.ErrDatabaseError
+	}
+
+	rows, err := db.DB.Query(context.Background(), `
+		SELECT id, sender_id, recipient_id, body, created_at, delivered_at, read_at
+		FROM direct_messages
+		WHERE recipient_id = $1 AND delivered_at IS NULL
+		ORDER BY id
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, notFoundOnInvalidID(err)
+	}
+	return scanMessages(rows)
+}
+
+// MarkDelivered records that messages reached a socket of their recipient
+func MarkDelivered(recipientID string, ids ...int64) error {
+	if db.DB == nil {
+		return auth.ErrDatabaseError
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := db.DB.Exec(context.Background(), `
+		UPDATE direct_messages
+		SET delivered_at = $3
+		WHERE recipient_id = $1 AND id = ANY($2) AND delivered_at IS NULL
+	`, recipientID, ids, time.Now())
+	return err
+}
+
+// Helper functions
+
+func scanMessages(rows pgx.Rows) ([]Message, error) {
+	defer rows.Close()
+
+	messages := []Message{}
+	for rows.Next() {
+		var msg Message
+		if err := rows.Scan(&msg.ID, &msg.SenderID, &msg.RecipientID, &msg.Body, &msg.CreatedAt, &msg.DeliveredAt, &msg.ReadAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+func requireActiveUser(userID string) error {
+	user, err := auth.GetUserByID(userID)
+	if err != nil {
+		return notFoundOnInvalidID(err)
+	}
+	if user.DeletedAt != nil {
+		return auth.ErrUserNotFound
+	}
+	return nil
+}
+
+// notFoundOnInvalidID turns the error Postgres raises for a malformed UUID into auth.ErrUserNotFound
+func notFoundOnInvalidID(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "22P02" {
+		return auth.ErrUserNotFound
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"TetriON.WebServer/server/internal/logging"
+	redisnet "TetriON.WebServer/server/internal/net/redis"
+)
+
+// Event is a WebSocket payload for the sockets of some users. It is published through Redis
+// so that every server instance hands it to the clients it holds.
+type Event struct {
+	UserIDs []string        `json:"user_ids"`
+	Payload json.RawMessage `json:"payload"`
+	// DeliveryOf is the ID of a direct message to UserIDs[0]; the instance that hands the
+	// event to a socket of that user marks the message delivered
+	DeliveryOf int64 `json:"delivery_of,omitempty"`
+}
+
+// Users sends payload to every socket of the given users on every server instance
+func Users(userIDs []string, payload any) {
+	Publish(Event{UserIDs: userIDs}, payload)
+}
+
+// Publish encodes payload into the event and publishes it. A failure is logged: events are
+// best effort, and anything that must not be lost is persisted by the caller.
+func Publish(event Event, payload any) {
+	if len(event.UserIDs) == 0 {
+		return
+	}
+
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		logging.LogError("Failed to encode user event: %v", err)
+		return
+	}
+	event.Payload = encoded
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		logging.LogError("Failed to encode user event: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := redisnet.PublishUserEvent(ctx, data); err != nil {
+		logging.LogError("Failed to publish user event: %v", err)
+	}
+}
+
+// Decode parses a user event received from Redis
+func Decode(data []byte) (*Event, error) {
+	var event Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
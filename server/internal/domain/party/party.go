@@ -0,0 +1,586 @@
+package party
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/domain/friends"
+	"TetriON.WebServer/server/internal/domain/notify"
+	"TetriON.WebServer/server/internal/domain/presence"
+	"TetriON.WebServer/server/internal/logging"
+	redisnet "TetriON.WebServer/server/internal/net/redis"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	MaxSize   = 4
+	InviteTTL = 5 * time.Minute
+
+	// TicketPrefix marks a matchmaking queue entry that stands for a whole party
+	TicketPrefix = "party:"
+)
+
+// Reasons a party leaves its matchmaking queue
+const (
+	DequeueLeader       = "leader"
+	DequeueRosterChange = "roster_changed"
+	DequeueDisconnect   = "member_disconnected"
+	DequeueMatchFound   = "match_found"
+)
+
+var (
+	ErrNotInParty     = errors.New("you are not in a party")
+	ErrAlreadyInParty = errors.New("leave your current party first")
+	ErrNotLeader      = errors.New("only the party leader can do this")
+	ErrNotMember      = errors.New("this player is not in your party")
+	ErrPartyFull      = fmt.Errorf("a party can have at most %d players", MaxSize)
+	ErrInviteNotFound = errors.New("party invite not found or expired")
+	ErrAlreadyInvited = errors.New("this player is already in or invited to your party")
+	ErrSelf           = errors.New("you cannot do this to yourself")
+	ErrBlocked        = errors.New("you cannot invite this player")
+	ErrQueued         = errors.New("the party is in a matchmaking queue")
+	ErrNotQueued      = errors.New("the party is not in a matchmaking queue")
+	ErrPartyNotFound  = errors.New("party not found")
+	ErrRosterChanged  = errors.New("the party changed, try again")
+	ErrSoloQueued     = errors.New("leave the matchmaking queue first")
+)
+
+// Party is a group of players who queue into matchmaking together
+type Party struct {
+	ID        string               `json:"id"`
+	LeaderID  string               `json:"leader_id"`
+	Members   []string             `json:"members"`           // user IDs in join order, leader included
+	Invites   map[string]time.Time `json:"invites,omitempty"` // invited user ID -> expiry
+	Queue     string               `json:"queue,omitempty"`   // queue the party's ticket waits in
+	CreatedAt time.Time            `json:"created_at"`
+}
+
+// Ticket is the matchmaking queue entry of the party
+func (p *Party) Ticket() string {
+	return TicketPrefix + p.ID
+}
+
+// IsMember reports whether the user is in the party
+func (p *Party) IsMember(userID string) bool {
+	return slices.Contains(p.Members, userID)
+}
+
+// Get returns the party of a user, or nil when they are not in one
+func Get(ctx context.Context, userID string) (*Party, error) {
+	partyID, err := redisnet.PartyOf(ctx, userID)
+	if err != nil || partyID == "" {
+		return nil, err
+	}
+	p, err := load(ctx, partyID)
+	if err != nil || p == nil || !p.IsMember(userID) {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Load returns a party by ID, or ErrPartyNotFound
+func Load(ctx context.Context, partyID string) (*Party, error) {
+	p, err := load(ctx, partyID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrPartyNotFound
+	}
+	return p, nil
+}
+
+// Invite invites a player to the party of leaderID, creating a party when the inviter has none
+func Invite(ctx context.Context, leaderID, leaderName, targetID string) (*Party, error) {
+	if leaderID == targetID {
+		return nil, ErrSelf
+	}
+	if err := requireActiveUser(targetID); err != nil {
+		return nil, err
+	}
+	if err := requireNotSoloQueued(ctx, leaderID); err != nil {
+		return nil, err
+	}
+	blocked, err := friends.IsBlocked(leaderID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrBlocked
+	}
+
+	partyID, err := redisnet.PartyOf(ctx, leaderID)
+	if err != nil {
+		return nil, err
+	}
+	if partyID == "" {
+		if partyID, err = newPartyID(); err != nil {
+			return nil, err
+		}
+	}
+
+	var invited *Party
+	err = update(ctx, partyID, []string{leaderID}, func(p *Party, memberships map[string]string) (*Party, map[string]string, error) {
+		if p == nil {
+			if memberships[leaderID] != "" && memberships[leaderID] != partyID {
+				return nil, nil, ErrAlreadyInParty
+			}
+			p = &Party{ID: partyID, LeaderID: leaderID, Members: []string{leaderID}, CreatedAt: time.Now()}
+		}
+		if p.LeaderID != leaderID {
+			return nil, nil, ErrNotLeader
+		}
+		if p.Queue != "" {
+			return nil, nil, ErrQueued
+		}
+		pruneInvites(p)
+		if p.IsMember(targetID) {
+			return nil, nil, ErrAlreadyInvited
+		}
+		if _, ok := p.Invites[targetID]; ok {
+			return nil, nil, ErrAlreadyInvited
+		}
+		if len(p.Members)+len(p.Invites) >= MaxSize {
+			return nil, nil, ErrPartyFull
+		}
+
+		if p.Invites == nil {
+			p.Invites = make(map[string]time.Time)
+		}
+		p.Invites[targetID] = time.Now().Add(InviteTTL)
+		invited = p
+		return p, nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	notify.Users([]string{targetID}, map[string]any{
+		"type":          "party_invite",
+		"party_id":      invited.ID,
+		"from":          leaderID,
+		"from_username": leaderName,
+		"expires_at":    invited.Invites[targetID],
+	})
+	broadcast(invited)
+	return invited, nil
+}
+
+// Accept joins the party that invited the user
+func Accept(ctx context.Context, userID, partyID string) (*Party, error) {
+	current, err := load(ctx, partyID)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, ErrInviteNotFound
+	}
+	if err := requireNotSoloQueued(ctx, userID); err != nil {
+		return nil, err
+	}
+	// A block placed after the invite still keeps the players apart
+	for _, memberID := range current.Members {
+		blocked, err := friends.IsBlocked(userID, memberID)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			return nil, ErrBlocked
+		}
+	}
+
+	var joined *Party
+	err = update(ctx, partyID, []string{userID}, func(p *Party, memberships map[string]string) (*Party, map[string]string, error) {
+		if p == nil {
+			return nil, nil, ErrInviteNotFound
+		}
+		pruneInvites(p)
+		if _, ok := p.Invites[userID]; !ok {
+			return nil, nil, ErrInviteNotFound
+		}
+		if memberships[userID] != "" && memberships[userID] != partyID {
+			return nil, nil, ErrAlreadyInParty
+		}
+		if p.Queue != "" {
+			return nil, nil, ErrQueued
+		}
+		if len(p.Members) >= MaxSize {
+			return nil, nil, ErrPartyFull
+		}
+
+		delete(p.Invites, userID)
+		p.Members = append(p.Members, userID)
+		joined = p
+		return p, map[string]string{userID: partyID}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	broadcast(joined)
+	return joined, nil
+}
+
+// Decline turns down a party invite
+func Decline(ctx context.Context, userID, partyID string) error {
+	var declined *Party
+	err := update(ctx, partyID, nil, func(p *Party, _ map[string]string) (*Party, map[string]string, error) {
+		if p == nil {
+			return nil, nil, ErrInviteNotFound
+		}
+		if _, ok := p.Invites[userID]; !ok {
+			return nil, nil, ErrInviteNotFound
+		}
+		delete(p.Invites, userID)
+		declined = p
+		return p, nil, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	notify.Users(declined.Members, map[string]any{
+		"type":     "party_invite_declined",
+		"party_id": declined.ID,
+		"user_id":  userID,
+	})
+	return nil
+}
+
+// Leave removes the user from their party. A leaving leader hands over to the longest member;
+// the last member leaving disbands the party. A queued party leaves its queue.
+func Leave(ctx context.Context, userID string) (*Party, error) {
+	partyID, err := redisnet.PartyOf(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if partyID == "" {
+		return nil, ErrNotInParty
+	}
+	return removeMember(ctx, partyID, userID, "", "party_left")
+}
+
+// Kick removes a member from the leader's party
+func Kick(ctx context.Context, leaderID, targetID string) (*Party, error) {
+	if leaderID == targetID {
+		return nil, ErrSelf
+	}
+	partyID, err := redisnet.PartyOf(ctx, leaderID)
+	if err != nil {
+		return nil, err
+	}
+	if partyID == "" {
+		return nil, ErrNotInParty
+	}
+	return removeMember(ctx, partyID, targetID, leaderID, "party_kicked")
+}
+
+// Promote makes another member the party leader
+func Promote(ctx context.Context, leaderID, targetID string) (*Party, error) {
+	if leaderID == targetID {
+		return nil, ErrSelf
+	}
+	partyID, err := redisnet.PartyOf(ctx, leaderID)
+	if err != nil {
+		return nil, err
+	}
+	if partyID == "" {
+		return nil, ErrNotInParty
+	}
+
+	var promoted *Party
+	err = update(ctx, partyID, nil, func(p *Party, _ map[string]string) (*Party, map[string]string, error) {
+		if p == nil || !p.IsMember(leaderID) {
+			return nil, nil, ErrNotInParty
+		}
+		if p.LeaderID != leaderID {
+			return nil, nil, ErrNotLeader
+		}
+		if !p.IsMember(targetID) {
+			return nil, nil, ErrNotMember
+		}
+		p.LeaderID = targetID
+		promoted = p
+		return p, nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	broadcast(promoted)
+	return promoted, nil
+}
+
+// MarkQueued records that the leader puts the party's ticket into queue. members are the
+// players checked for eligibility; ErrRosterChanged is returned when the party changed since.
+func MarkQueued(ctx context.Context, leaderID, queue string, members []string) (*Party, error) {
+	partyID, err := redisnet.PartyOf(ctx, leaderID)
+	if err != nil {
+		return nil, err
+	}
+	if partyID == "" {
+		return nil, ErrNotInParty
+	}
+
+	var queued *Party
+	err = update(ctx, partyID, nil, func(p *Party, _ map[string]string) (*Party, map[string]string, error) {
+		if p == nil || !p.IsMember(leaderID) {
+			return nil, nil, ErrNotInParty
+		}
+		if p.LeaderID != leaderID {
+			return nil, nil, ErrNotLeader
+		}
+		if p.Queue != "" {
+			return nil, nil, ErrQueued
+		}
+		if !slices.Equal(p.Members, members) {
+			return nil, nil, ErrRosterChanged
+		}
+		p.Queue = queue
+		queued = p
+		return p, nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	notify.Users(queued.Members, map[string]any{
+		"type":     "party_queued",
+		"party_id": queued.ID,
+		"queue":    queue,
+	})
+	return queued, nil
+}
+
+// LeaveQueue takes a queued party's ticket out of its queue and tells the members why.
+// It returns ErrNotQueued when the party is not queued, e.g. because another server already
+// dequeued it.
+func LeaveQueue(ctx context.Context, partyID, reason string) (*Party, error) {
+	var dequeued *Party
+	var queue string
+	err := update(ctx, partyID, nil, func(p *Party, _ map[string]string) (*Party, map[string]string, error) {
+		if p == nil {
+			return nil, nil, ErrPartyNotFound
+		}
+		if p.Queue == "" {
+			return nil, nil, ErrNotQueued
+		}
+		queue = p.Queue
+		p.Queue = ""
+		dequeued = p
+		return p, nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	finishQueue(ctx, dequeued, queue, reason)
+	return dequeued, nil
+}
+
+// MemberDisconnected takes the party of a player whose last connection closed out of its queue
+func MemberDisconnected(ctx context.Context, userID string) {
+	p, err := Get(ctx, userID)
+	if err != nil {
+		logging.LogError("Failed to load the party of %s after a disconnect: %v", userID, err)
+		return
+	}
+	if p == nil || p.Queue == "" {
+		return
+	}
+	if _, err := LeaveQueue(ctx, p.ID, DequeueDisconnect); err != nil && err != ErrNotQueued {
+		logging.LogError("Failed to take party %s out of its queue: %v", p.ID, err)
+	}
+}
+
+// Helper functions
+
+// removeMember takes userID out of a party; kickedBy is the leader for a kick, empty otherwise
+func removeMember(ctx context.Context, partyID, userID, kickedBy, event string) (*Party, error) {
+	var left *Party
+	var queue string
+	disbanded := false
+	err := update(ctx, partyID, nil, func(p *Party, _ map[string]string) (*Party, map[string]string, error) {
+		if p == nil {
+			return nil, nil, ErrNotInParty
+		}
+		if kickedBy != "" {
+			if !p.IsMember(kickedBy) {
+				return nil, nil, ErrNotInParty
+			}
+			if p.LeaderID != kickedBy {
+				return nil, nil, ErrNotLeader
+			}
+		}
+		if !p.IsMember(userID) {
+			if kickedBy != "" {
+				return nil, nil, ErrNotMember
+			}
+			return nil, nil, ErrNotInParty
+		}
+
+		p.Members = slices.DeleteFunc(p.Members, func(id string) bool { return id == userID })
+		if p.LeaderID == userID && len(p.Members) > 0 {
+			p.LeaderID = p.Members[0]
+		}
+		queue = p.Queue
+		p.Queue = ""
+		left = p
+
+		memberships := map[string]string{userID: ""}
+		if len(p.Members) == 0 {
+			disbanded = true
+			return nil, memberships, nil
+		}
+		return p, memberships, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if queue != "" {
+		finishQueue(ctx, left, queue, DequeueRosterChange)
+		// The removed player queued with the party as well
+		if err := presence.EndActivity(ctx, userID, presence.ActivityQueueing); err != nil {
+			logging.LogError("Failed to update presence of %s after leaving a party: %v", userID, err)
+		}
+	}
+
+	notify.Users([]string{userID}, map[string]any{
+		"type":     event,
+		"party_id": partyID,
+	})
+	if disbanded {
+		return nil, nil
+	}
+	broadcast(left)
+	return left, nil
+}
+
+// finishQueue removes the party ticket from queue and returns the members to the menu
+func finishQueue(ctx context.Context, p *Party, queue, reason string) {
+	if err := redisnet.RemovePlayerFromQueue(ctx, queue, p.Ticket()); err != nil {
+		logging.LogError("Failed to remove party %s from %s: %v", p.ID, queue, err)
+	}
+	if reason == DequeueMatchFound {
+		return
+	}
+
+	for _, memberID := range p.Members {
+		if err := presence.EndActivity(ctx, memberID, presence.ActivityQueueing); err != nil {
+			logging.LogError("Failed to update presence of %s after leaving %s: %v", memberID, queue, err)
+		}
+	}
+	notify.Users(p.Members, map[string]any{
+		"type":     "party_dequeued",
+		"party_id": p.ID,
+		"queue":    queue,
+		"reason":   reason,
+	})
+}
+
+// update runs fn on a party inside a Redis transaction. fn returns the party to store (nil
+// deletes it) and the memberships to change; the memberships of all remaining members are
+// refreshed so they expire together with the party.
+func update(ctx context.Context, partyID string, userIDs []string, fn func(p *Party, memberships map[string]string) (*Party, map[string]string, error)) error {
+	return redisnet.UpdateParty(ctx, partyID, userIDs, func(data []byte, memberships map[string]string) (*redisnet.PartyWrite, error) {
+		var current *Party
+		if data != nil {
+			current = &Party{}
+			if err := json.Unmarshal(data, current); err != nil {
+				return nil, err
+			}
+		}
+
+		next, changed, err := fn(current, memberships)
+		if err != nil {
+			return nil, err
+		}
+
+		write := &redisnet.PartyWrite{Memberships: make(map[string]string)}
+		for userID, membership := range changed {
+			write.Memberships[userID] = membership
+		}
+		if next != nil {
+			if write.Party, err = json.Marshal(next); err != nil {
+				return nil, err
+			}
+			for _, memberID := range next.Members {
+				write.Memberships[memberID] = next.ID
+			}
+		}
+		return write, nil
+	})
+}
+
+func load(ctx context.Context, partyID string) (*Party, error) {
+	data, err := redisnet.GetParty(ctx, partyID)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var p Party
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// broadcast sends the current party to every member
+func broadcast(p *Party) {
+	notify.Users(p.Members, map[string]any{
+		"type":  "party_updated",
+		"party": p,
+	})
+}
+
+func newPartyID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func pruneInvites(p *Party) {
+	now := time.Now()
+	for userID, expiry := range p.Invites {
+		if now.After(expiry) {
+			delete(p.Invites, userID)
+		}
+	}
+}
+
+// requireNotSoloQueued refuses players holding a solo matchmaking ticket, which would
+// otherwise be matched alongside their party's ticket
+func requireNotSoloQueued(ctx context.Context, userID string) error {
+	queues, err := redisnet.SoloQueues(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if len(queues) > 0 {
+		return ErrSoloQueued
+	}
+	return nil
+}
+
+func requireActiveUser(userID string) error {
+	user, err := auth.GetUserByID(userID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "22P02" {
+			return auth.ErrUserNotFound
+		}
+		return err
+	}
+	if user.DeletedAt != nil {
+		return auth.ErrUserNotFound
+	}
+	return nil
+}
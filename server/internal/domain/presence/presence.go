@@ -0,0 +1,292 @@
+package presence
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	redisnet "TetriON.WebServer/server/internal/net/redis"
+)
+
+// Statuses a player can choose; StatusOffline is only ever reported, never stored.
+// Invisible players are reported as offline to everyone but themselves.
+const (
+	StatusOnline    = "online"
+	StatusAway      = "away"
+	StatusInvisible = "invisible"
+	StatusOffline   = "offline"
+)
+
+// Activities
+const (
+	ActivityMenu       = "menu"
+	ActivityQueueing   = "queueing"
+	ActivityInMatch    = "in_match"
+	ActivitySpectating = "spectating"
+)
+
+// TTL is how long presence survives without a heartbeat
+const TTL = 60 * time.Second
+
+const maxFieldLength = 64
+
+var (
+	ErrInvalidStatus   = errors.New("status must be online, away or invisible")
+	ErrInvalidActivity = errors.New("activity must be menu, queueing, in_match or spectating")
+	ErrInvalidField    = errors.New("mode and match_id must be at most 64 characters")
+)
+
+// Presence is what a player is doing right now
+type Presence struct {
+	Status   string     `json:"status"`
+	Activity string     `json:"activity,omitempty"`
+	Mode     string     `json:"mode,omitempty"`
+	MatchID  string     `json:"match_id,omitempty"`
+	Since    *time.Time `json:"since,omitempty"` // when the current activity started
+}
+
+// Update holds the presence fields a client changes; nil fields are left unchanged.
+// Changing the activity clears mode and match ID unless they are given as well.
+type Update struct {
+	Status   *string `json:"status"`
+	Activity *string `json:"activity"`
+	Mode     *string `json:"mode"`
+	MatchID  *string `json:"match_id"`
+}
+
+var offline = Presence{Status: StatusOffline}
+
+// Connect marks a user online and returns the status in effect. Without a status the user keeps
+// the one stored by their other connections, so an invisible player stays invisible; a user
+// with no presence yet starts online in the menu. Activities are always kept.
+func Connect(ctx context.Context, userID, status string) (string, error) {
+	if status != "" && !ValidStatus(status) {
+		return "", ErrInvalidStatus
+	}
+
+	current, ok, err := Get(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		if status == "" || status == current.Status {
+			_, err := redisnet.HeartbeatUser(ctx, userID, TTL)
+			return current.Status, err
+		}
+		return status, redisnet.SetPresence(ctx, userID, map[string]string{"status": status}, TTL)
+	}
+
+	if status == "" {
+		status = StatusOnline
+	}
+	return status, redisnet.SetPresence(ctx, userID, fields(Presence{
+		Status:   status,
+		Activity: ActivityMenu,
+		Since:    now(),
+	}), TTL)
+}
+
+// Heartbeat keeps a connected user's presence alive, recreating it with status if it expired
+func Heartbeat(ctx context.Context, userID, status string) error {
+	if err := redisnet.RefreshUserConnections(ctx, userID, TTL); err != nil {
+		return err
+	}
+	alive, err := redisnet.HeartbeatUser(ctx, userID, TTL)
+	if err != nil || alive {
+		return err
+	}
+	_, err = Connect(ctx, userID, status)
+	return err
+}
+
+// AddConnection counts a newly authenticated connection of a user on any server instance
+func AddConnection(ctx context.Context, userID string) error {
+	_, err := redisnet.AddUserConnection(ctx, userID, TTL)
+	return err
+}
+
+// RemoveConnection uncounts a closed connection and reports whether it was the user's last
+// one across all server instances
+func RemoveConnection(ctx context.Context, userID string) (bool, error) {
+	left, err := redisnet.ReleaseUserConnection(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return left == 0, nil
+}
+
+// Disconnect removes the presence of a user whose last connection closed
+func Disconnect(ctx context.Context, userID string) error {
+	return redisnet.SetUserOffline(ctx, userID)
+}
+
+// Apply validates and stores a client's presence update and returns the new presence
+func Apply(ctx context.Context, userID string, update Update) (*Presence, error) {
+	if update.Status != nil && !ValidStatus(*update.Status) {
+		return nil, ErrInvalidStatus
+	}
+	if update.Activity != nil && !validActivity(*update.Activity) {
+		return nil, ErrInvalidActivity
+	}
+	if (update.Mode != nil && len(*update.Mode) > maxFieldLength) || (update.MatchID != nil && len(*update.MatchID) > maxFieldLength) {
+		return nil, ErrInvalidField
+	}
+
+	current, ok, err := Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		current = &Presence{Status: StatusOnline, Activity: ActivityMenu, Since: now()}
+	}
+
+	if update.Status != nil {
+		current.Status = *update.Status
+	}
+	if update.Activity != nil && *update.Activity != current.Activity {
+		current.Activity = *update.Activity
+		current.Mode = ""
+		current.MatchID = ""
+		current.Since = now()
+	}
+	if update.Mode != nil {
+		current.Mode = *update.Mode
+	}
+	if update.MatchID != nil {
+		current.MatchID = *update.MatchID
+	}
+
+	if err := redisnet.SetPresence(ctx, userID, fields(*current), TTL); err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+// SetActivity changes the activity of a user who is online; offline users are left alone.
+// It is used by server-side transitions such as joining a matchmaking queue.
+func SetActivity(ctx context.Context, userID, activity, mode, matchID string) error {
+	current, ok, err := Get(ctx, userID)
+	if err != nil || !ok {
+		return err
+	}
+	if current.Activity == activity && current.Mode == mode && current.MatchID == matchID {
+		return nil
+	}
+
+	_, err = Apply(ctx, userID, Update{Activity: &activity, Mode: &mode, MatchID: &matchID})
+	return err
+}
+
+// EndActivity returns a user to the menu if they are still doing activity
+func EndActivity(ctx context.Context, userID, activity string) error {
+	current, ok, err := Get(ctx, userID)
+	if err != nil || !ok || current.Activity != activity {
+		return err
+	}
+	return SetActivity(ctx, userID, ActivityMenu, "", "")
+}
+
+// Get returns the stored presence of a user, including the invisible status
+func Get(ctx context.Context, userID string) (*Presence, bool, error) {
+	stored, err := redisnet.GetPresence(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(stored) == 0 {
+		return nil, false, nil
+	}
+	return parse(stored), true, nil
+}
+
+// Lookup returns the presence of several users as viewerID may see it, in one round trip.
+// Every requested user is in the result; offline and invisible users are reported as offline.
+func Lookup(ctx context.Context, viewerID string, userIDs []string) (map[string]Presence, error) {
+	stored, err := redisnet.GetPresences(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]Presence, len(userIDs))
+	for _, userID := range userIDs {
+		fields, ok := stored[userID]
+		if !ok {
+			result[userID] = offline
+			continue
+		}
+		result[userID] = Visible(parse(fields), userID == viewerID)
+	}
+	return result, nil
+}
+
+// IsOnline reports whether other players see the user as online
+func IsOnline(ctx context.Context, userID string) (bool, error) {
+	current, ok, err := Get(ctx, userID)
+	if err != nil || !ok {
+		return false, err
+	}
+	return Visible(current, false).Status != StatusOffline, nil
+}
+
+// Visible returns the presence as shown to others (self=false) or to the player themselves
+func Visible(p *Presence, self bool) Presence {
+	if p == nil || (p.Status == StatusInvisible && !self) {
+		return offline
+	}
+	return *p
+}
+
+// ValidStatus reports whether a player may choose status
+func ValidStatus(status string) bool {
+	switch status {
+	case StatusOnline, StatusAway, StatusInvisible:
+		return true
+	}
+	return false
+}
+
+// Helper functions
+
+func fields(p Presence) map[string]string {
+	since := ""
+	if p.Since != nil {
+		since = strconv.FormatInt(p.Since.Unix(), 10)
+	}
+	return map[string]string{
+		"status":   p.Status,
+		"activity": p.Activity,
+		"mode":     p.Mode,
+		"match_id": p.MatchID,
+		"since":    since,
+	}
+}
+
+func parse(stored map[string]string) *Presence {
+	p := &Presence{
+		Status:   stored["status"],
+		Activity: stored["activity"],
+		Mode:     stored["mode"],
+		MatchID:  stored["match_id"],
+	}
+	if !ValidStatus(p.Status) {
+		p.Status = StatusOnline
+	}
+	if unix, err := strconv.ParseInt(stored["since"], 10, 64); err == nil {
+		since := time.Unix(unix, 0)
+		p.Since = &since
+	}
+	return p
+}
+
+func validActivity(activity string) bool {
+	switch activity {
+	case ActivityMenu, ActivityQueueing, ActivityInMatch, ActivitySpectating:
+		return true
+	}
+	return false
+}
+
+func now() *time.Time {
+	t := time.Now()
+	return &t
+}
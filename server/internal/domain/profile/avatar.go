@@ -0,0 +1,276 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/db"
+	"TetriON.WebServer/server/internal/logging"
+	"TetriON.WebServer/server/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	maxAvatarBytes     = 5 << 20
+	maxAvatarDimension = 4096
+	avatarHashLength   = 16
+)
+
+// avatarSizes are the square edge lengths every avatar is stored in, largest first
+var avatarSizes = []int{256, 128, 64}
+
+var (
+	ErrAvatarUpload       = errors.New("expected a multipart/form-data upload with an avatar file")
+	ErrAvatarTooLarge     = fmt.Errorf("avatar must be at most %d MB", maxAvatarBytes>>20)
+	ErrUnsupportedAvatar  = errors.New("avatar must be a PNG, JPEG or GIF image")
+	ErrAvatarDimensions   = fmt.Errorf("avatar must be at most %dx%d pixels", maxAvatarDimension, maxAvatarDimension)
+	ErrAvatarFileNotFound = errors.New("avatar not found")
+)
+
+var (
+	avatarHashRegex    = regexp.MustCompile(`^[0-9a-f]{16}$`)
+	avatarUserIDRegex  = regexp.MustCompile(`^[0-9a-f-]{36}$`)
+	avatarContentTypes = map[string]bool{"image/png": true, "image/jpeg": true, "image/gif": true}
+)
+
+// SetAvatar validates and decodes an uploaded PNG, JPEG or GIF (first frame), crops it to a
+// centred square and stores it re-encoded as PNG in every avatar size. Re-encoding drops all
+// metadata such as EXIF location data. The previous avatar is deleted.
+func SetAvatar(userID string, data []byte) (*Profile, error) {
+	if db.DB == nil {
+		return nil, auth.ErrDatabaseError
+	}
+
+	img, err := decodeAvatar(data)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := auth.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt != nil {
+		return nil, auth.ErrUserNotFound
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])[:avatarHashLength]
+
+	ctx := context.Background()
+	square := cropSquare(img)
+	for _, size := range avatarSizes {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, resizeSquare(square, size)); err != nil {
+			return nil, err
+		}
+		if err := storage.Put(ctx, avatarKey(user.ID, hash, size), buf.Bytes(), "image/png"); err != nil {
+			return nil, err
+		}
+	}
+
+	// WITH sub-statements see the row as it was before the upsert
+	var previous *string
+	err = db.DB.QueryRow(ctx, `
+		WITH old AS (
+			SELECT avatar_hash FROM profiles WHERE user_id = $1
+		)
+		INSERT INTO profiles (user_id, avatar_hash, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET avatar_hash = EXCLUDED.avatar_hash,
+			updated_at = EXCLUDED.updated_at
+		RETURNING (SELECT avatar_hash FROM old)
+	`, user.ID, hash, time.Now()).Scan(&previous)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil && *previous != hash {
+		deleteAvatarFiles(ctx, user.ID, *previous)
+	}
+
+	return load(user)
+}
+
+// RemoveAvatar deletes the uploaded avatar of a user
+func RemoveAvatar(userID string) (*Profile, error) {
+	if db.DB == nil {
+		return nil, auth.ErrDatabaseError
+	}
+
+	user, err := auth.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt != nil {
+		return nil, auth.ErrUserNotFound
+	}
+
+	ctx := context.Background()
+	var previous *string
+	err = db.DB.QueryRow(ctx, `
+		UPDATE profiles p
+		SET avatar_hash = NULL, updated_at = $2
+		FROM profiles old
+		WHERE p.user_id = $1 AND old.user_id = p.user_id
+		RETURNING old.avatar_hash
+	`, user.ID, time.Now()).Scan(&previous)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, err
+	}
+	if previous != nil {
+		deleteAvatarFiles(ctx, user.ID, *previous)
+	}
+
+	return load(user)
+}
+
+// DeleteAllAvatarFiles removes every stored avatar image of a user; it is run when an account is purged
+func DeleteAllAvatarFiles(userID string) {
+	removed, err := storage.DeletePrefix(context.Background(), "avatars/"+userID+"/")
+	if err != nil {
+		logging.LogError("Failed to delete avatars of user %s: %v", userID, err)
+		return
+	}
+	if removed > 0 {
+		logging.LogInfo("Deleted %d avatar image(s) of purged user %s", removed, userID)
+	}
+}
+
+// AvatarFileKey returns the blob key of a served avatar image, or ErrAvatarFileNotFound when
+// the path parts do not name one
+func AvatarFileKey(userID, hash, file string) (string, error) {
+	if !avatarUserIDRegex.MatchString(userID) || !avatarHashRegex.MatchString(hash) || !validAvatarFile(file) {
+		return "", ErrAvatarFileNotFound
+	}
+	return "avatars/" + userID + "/" + hash + "/" + file, nil
+}
+
+// Helper functions
+
+// decodeAvatar checks the size, the sniffed type and the dimensions before decoding the whole image
+func decodeAvatar(data []byte) (image.Image, error) {
+	if len(data) > maxAvatarBytes {
+		return nil, ErrAvatarTooLarge
+	}
+	if !avatarContentTypes[http.DetectContentType(data)] {
+		return nil, ErrUnsupportedAvatar
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedAvatar
+	}
+	if config.Width < 1 || config.Height < 1 || config.Width > maxAvatarDimension || config.Height > maxAvatarDimension {
+		return nil, ErrAvatarDimensions
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedAvatar
+	}
+	return img, nil
+}
+
+// cropSquare copies the largest centred square of img into an RGBA image
+func cropSquare(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	origin := image.Pt(bounds.Min.X+(bounds.Dx()-side)/2, bounds.Min.Y+(bounds.Dy()-side)/2)
+
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), img, origin, draw.Src)
+	return square
+}
+
+// resizeSquare scales a square image to size x size with a box filter: every output pixel is
+// the average of the source pixels it covers. Upscaling degrades to nearest neighbour.
+func resizeSquare(src *image.RGBA, size int) *image.RGBA {
+	side := src.Bounds().Dx()
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+
+	for dy := 0; dy < size; dy++ {
+		y0, y1 := boxSpan(dy, side, size)
+		for dx := 0; dx < size; dx++ {
+			x0, x1 := boxSpan(dx, side, size)
+
+			var r, g, b, a, n uint64
+			for y := y0; y < y1; y++ {
+				row := src.Pix[y*src.Stride:]
+				for x := x0; x < x1; x++ {
+					pixel := row[x*4 : x*4+4]
+					r += uint64(pixel[0])
+					g += uint64(pixel[1])
+					b += uint64(pixel[2])
+					a += uint64(pixel[3])
+					n++
+				}
+			}
+
+			i := dst.PixOffset(dx, dy)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// boxSpan returns the source range [from, to) covered by output pixel i
+func boxSpan(i, side, size int) (int, int) {
+	from := i * side / size
+	to := (i + 1) * side / size
+	if to <= from {
+		to = from + 1
+	}
+	return from, to
+}
+
+func deleteAvatarFiles(ctx context.Context, userID, hash string) {
+	for _, size := range avatarSizes {
+		if err := storage.Delete(ctx, avatarKey(userID, hash, size)); err != nil {
+			logging.LogError("Failed to delete avatar %s of user %s: %v", hash, userID, err)
+		}
+	}
+}
+
+func avatarKey(userID, hash string, size int) string {
+	return "avatars/" + userID + "/" + hash + "/" + avatarFileName(size)
+}
+
+func avatarFileName(size int) string {
+	return strconv.Itoa(size) + ".png"
+}
+
+func validAvatarFile(file string) bool {
+	for _, size := range avatarSizes {
+		if file == avatarFileName(size) {
+			return true
+		}
+	}
+	return false
+}
+
+// avatarURLs returns the URL of every size of an uploaded avatar, keyed by the edge length
+func avatarURLs(userID, hash string) map[string]string {
+	urls := make(map[string]string, len(avatarSizes))
+	for _, size := range avatarSizes {
+		urls[strconv.Itoa(size)] = "/api/" + avatarKey(userID, hash, size)
+	}
+	return urls
+}
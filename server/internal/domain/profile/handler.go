@@ -0,0 +1,234 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/logging"
+	"TetriON.WebServer/server/internal/middleware"
+	"TetriON.WebServer/server/internal/storage"
+)
+
+// ProfileHandler returns the public profile of a player (GET /api/users/{username})
+func ProfileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	username := r.PathValue("username")
+	profile, err := GetByUsername(username)
+	if err != nil {
+		if err == auth.ErrUserNotFound {
+			writeError(w, "Player not found", http.StatusNotFound)
+			return
+		}
+		logging.LogError("Failed to load profile of %s: %v", username, err)
+		writeError(w, "Failed to load profile", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"success": true,
+		"profile": profile,
+	}, http.StatusOK)
+}
+
+// MeHandler returns (GET) or edits (PATCH) the caller's public profile (/api/users/me).
+// Editable fields are display_name, country and bio.
+func MeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPatch {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, "missing authorization token", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		profile, err := GetByID(user.UserID)
+		if err != nil {
+			if err == auth.ErrUserNotFound {
+				writeError(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			logging.LogError("Failed to load profile of %s: %v", user.Username, err)
+			writeError(w, "Failed to load profile", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]any{
+			"success": true,
+			"profile": profile,
+		}, http.StatusOK)
+		return
+	}
+
+	var update Update
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		writeError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := UpdateProfile(user.UserID, update)
+	if err != nil {
+		switch err {
+		case ErrInvalidDisplayName, ErrInvalidCountry, ErrInvalidBio:
+			writeError(w, err.Error(), http.StatusBadRequest)
+		case auth.ErrUserNotFound:
+			writeError(w, err.Error(), http.StatusNotFound)
+		default:
+			logging.LogError("Failed to update profile of %s: %v", user.Username, err)
+			writeError(w, "Failed to update profile", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"success": true,
+		"profile": profile,
+	}, http.StatusOK)
+}
+
+// AvatarHandler uploads (POST, multipart field "avatar") or removes (DELETE) the caller's
+// avatar (/api/users/me/avatar)
+func AvatarHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, "missing authorization token", http.StatusUnauthorized)
+		return
+	}
+
+	var profile *Profile
+	var err error
+	if r.Method == http.MethodDelete {
+		profile, err = RemoveAvatar(user.UserID)
+	} else {
+		var data []byte
+		if data, err = readAvatarUpload(w, r); err == nil {
+			profile, err = SetAvatar(user.UserID, data)
+		}
+	}
+	if err != nil {
+		switch err {
+		case ErrAvatarUpload, ErrUnsupportedAvatar, ErrAvatarDimensions:
+			writeError(w, err.Error(), http.StatusBadRequest)
+		case ErrAvatarTooLarge:
+			writeError(w, err.Error(), http.StatusRequestEntityTooLarge)
+		case auth.ErrUserNotFound:
+			writeError(w, err.Error(), http.StatusNotFound)
+		default:
+			logging.LogError("Failed to update avatar of %s: %v", user.Username, err)
+			writeError(w, "Failed to update avatar", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"success": true,
+		"profile": profile,
+	}, http.StatusOK)
+}
+
+// AvatarFileHandler serves a stored avatar image (GET /api/avatars/{user_id}/{hash}/{file}).
+// The content hash in the path changes with every upload, so responses are cached forever.
+func AvatarFileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	hash := r.PathValue("hash")
+	key, err := AvatarFileKey(r.PathValue("user_id"), hash, r.PathValue("file"))
+	if err != nil {
+		writeError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	etag := `"` + hash + "-" + r.PathValue("file") + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body, info, err := storage.Get(r.Context(), key)
+	if err != nil {
+		if err == storage.ErrBlobNotFound {
+			writeError(w, ErrAvatarFileNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		logging.LogError("Failed to read avatar %s: %v", key, err)
+		writeError(w, "Failed to read avatar", http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", etag)
+	if info.Size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		io.Copy(w, body)
+	}
+}
+
+// Helper functions
+
+// readAvatarUpload reads the "avatar" file of a multipart upload
+func readAvatarUpload(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	// Leave room for the multipart framing around the file itself
+	r.Body = http.MaxBytesReader(w, r.Body, maxAvatarBytes+64<<10)
+	if err := r.ParseMultipartForm(maxAvatarBytes); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, ErrAvatarTooLarge
+		}
+		return nil, ErrAvatarUpload
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("avatar")
+	if err != nil {
+		return nil, ErrAvatarUpload
+	}
+	defer file.Close()
+
+	if header.Size > maxAvatarBytes {
+		return nil, ErrAvatarTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxAvatarBytes+1))
+	if err != nil {
+		return nil, ErrAvatarUpload
+	}
+	return data, nil
+}
+
+func writeJSON(w http.ResponseWriter, data any, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, message string, status int) {
+	writeJSON(w, map[string]any{
+		"success": false,
+		"error":   message,
+	}, status)
+}
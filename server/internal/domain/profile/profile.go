@@ -0,0 +1,277 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/db"
+	"TetriON.WebServer/server/internal/domain/presence"
+	"TetriON.WebServer/server/internal/logging"
+	"github.com/jackc/pgx/v5"
+)
+
+const recentMatchesLimit = 10
+
+var (
+	ErrInvalidDisplayName = errors.New("display name must be 1-32 printable characters")
+	ErrInvalidCountry     = errors.New("country must be an ISO 3166-1 alpha-2 code")
+	ErrInvalidBio         = errors.New("bio must be at most 500 characters")
+)
+
+var countryRegex = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// Profile is the public view of a player; it never contains the email address
+type Profile struct {
+	UserID        string            `json:"user_id"`
+	Username      string            `json:"username"`
+	DisplayName   string            `json:"display_name"`
+	AvatarURL     string            `json:"avatar_url,omitempty"`
+	Avatars       map[string]string `json:"avatars,omitempty"` // uploaded avatar URLs keyed by edge length
+	Country       string            `json:"country,omitempty"`
+	Bio           string            `json:"bio,omitempty"`
+	Guest         bool              `json:"guest,omitempty"`
+	JoinedAt      time.Time         `json:"joined_at"`
+	Ratings       []Rating          `json:"ratings"`
+	RecentMatches []Match           `json:"recent_matches"`
+	Online        bool              `json:"online"`
+}
+
+// Rating is a player's rating in one game mode
+type Rating struct {
+	Mode        string    `json:"mode"`
+	Rating      int       `json:"rating"`
+	GamesPlayed int       `json:"games_played"`
+	Wins        int       `json:"wins"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Match is one entry of a player's match history
+type Match struct {
+	MatchID      string    `json:"match_id"`
+	Mode         string    `json:"mode"`
+	Placement    int       `json:"placement"`
+	Result       string    `json:"result"`
+	RatingChange int       `json:"rating_change"`
+	PlayedAt     time.Time `json:"played_at"`
+}
+
+// Update holds the editable profile fields; nil fields are left unchanged and
+// empty strings clear the field
+type Update struct {
+	DisplayName *string `json:"display_name"`
+	Country     *string `json:"country"`
+	Bio         *string `json:"bio"`
+}
+
+// GetByUsername returns the public profile of an active user
+func GetByUsername(username string) (*Profile, error) {
+	user, err := auth.GetUserByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt != nil {
+		return nil, auth.ErrUserNotFound
+	}
+	return load(user)
+}
+
+// GetByID returns the public profile of an active user by ID
+func GetByID(userID string) (*Profile, error) {
+	user, err := auth.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt != nil {
+		return nil, auth.ErrUserNotFound
+	}
+	return load(user)
+}
+
+// UpdateProfile applies the changed fields to the user's profile and returns the new public profile
+func UpdateProfile(userID string, update Update) (*Profile, error) {
+	if db.DB == nil {
+		return nil, auth.ErrDatabaseError
+	}
+
+	user, err := auth.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt != nil {
+		return nil, auth.ErrUserNotFound
+	}
+
+	profile, err := load(user)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.DisplayName != nil {
+		name := strings.TrimSpace(*update.DisplayName)
+		if name != "" && !validDisplayName(name) {
+			return nil, ErrInvalidDisplayName
+		}
+		profile.DisplayName = name
+	}
+	if update.Country != nil {
+		country := strings.ToUpper(strings.TrimSpace(*update.Country))
+		if country != "" && !countryRegex.MatchString(country) {
+			return nil, ErrInvalidCountry
+		}
+		profile.Country = country
+	}
+	if update.Bio != nil {
+		bio := strings.TrimSpace(*update.Bio)
+		if utf8.RuneCountInString(bio) > 500 {
+			return nil, ErrInvalidBio
+		}
+		profile.Bio = bio
+	}
+
+	// The display name falls back to the username, so that one is stored as NULL
+	displayName := profile.DisplayName
+	if displayName == user.Username {
+		displayName = ""
+	}
+	_, err = db.DB.Exec(context.Background(), `
+		INSERT INTO profiles (user_id, display_name, country, bio, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE
+		SET display_name = EXCLUDED.display_name,
+			country = EXCLUDED.country,
+			bio = EXCLUDED.bio,
+			updated_at = EXCLUDED.updated_at
+	`, user.ID, nullString(displayName), nullString(profile.Country), nullString(profile.Bio), time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if displayName == "" {
+		profile.DisplayName = user.Username
+	}
+	return profile, nil
+}
+
+// Helper functions
+
+// load assembles the public profile of a user from the profiles, ratings and match tables
+func load(user *auth.User) (*Profile, error) {
+	if db.DB == nil {
+		return nil, auth.ErrDatabaseError
+	}
+
+	ctx := context.Background()
+	profile := &Profile{
+		UserID:        user.ID,
+		Username:      user.Username,
+		DisplayName:   user.Username,
+		Guest:         user.IsGuest,
+		JoinedAt:      user.CreatedAt,
+		Ratings:       []Rating{},
+		RecentMatches: []Match{},
+	}
+
+	var displayName, avatarURL, avatarHash, country, bio *string
+	err := db.DB.QueryRow(ctx, `
+		SELECT display_name, avatar_url, avatar_hash, country, bio
+		FROM profiles
+		WHERE user_id = $1
+	`, user.ID).Scan(&displayName, &avatarURL, &avatarHash, &country, &bio)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, err
+	}
+	if displayName != nil && *displayName != "" {
+		profile.DisplayName = *displayName
+	}
+	if avatarHash != nil {
+		profile.Avatars = avatarURLs(user.ID, *avatarHash)
+		profile.AvatarURL = profile.Avatars[strconv.Itoa(avatarSizes[0])]
+	} else if avatarURL != nil {
+		profile.AvatarURL = *avatarURL
+	}
+	if country != nil {
+		profile.Country = *country
+	}
+	if bio != nil {
+		profile.Bio = *bio
+	}
+
+	rows, err := db.DB.Query(ctx, `
+		SELECT mode, rating, games_played, wins, updated_at
+		FROM player_ratings
+		WHERE user_id = $1
+		ORDER BY mode
+	`, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var rating Rating
+		if err := rows.Scan(&rating.Mode, &rating.Rating, &rating.GamesPlayed, &rating.Wins, &rating.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		profile.Ratings = append(profile.Ratings, rating)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.DB.Query(ctx, `
+		SELECT match_id, mode, placement, result, rating_change, played_at
+		FROM match_history
+		WHERE user_id = $1
+		ORDER BY played_at DESC
+		LIMIT $2
+	`, user.ID, recentMatchesLimit)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var match Match
+		if err := rows.Scan(&match.MatchID, &match.Mode, &match.Placement, &match.Result, &match.RatingChange, &match.PlayedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		profile.RecentMatches = append(profile.RecentMatches, match)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	online, err := presence.IsOnline(ctx, user.ID)
+	if err != nil {
+		logging.LogError("Failed to read presence of user %s: %v", user.ID, err)
+	}
+	profile.Online = online
+
+	return profile, nil
+}
+
+func validDisplayName(name string) bool {
+	if utf8.RuneCountInString(name) > 32 {
+		return false
+	}
+	for _, r := range name {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func nullString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
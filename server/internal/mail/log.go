@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"TetriON.WebServer/server/internal/logging"
+)
+
+// LogMailer is a development mailer that appends messages to a file. Without a Path it only
+// logs the recipient and subject: bodies carry verification and reset links, which must never
+// reach the logs.
+type LogMailer struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (m *LogMailer) Send(_ context.Context, msg Message) error {
+	if m.Path == "" {
+		logging.LogInfoC(logging.Cyan, "📧 Outgoing email to %s: %s", msg.To, msg.Subject)
+		return nil
+	}
+
+	entry := fmt.Sprintf("----- %s -----\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(m.Path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(entry)
+	return err
+}
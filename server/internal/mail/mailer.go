@@ -0,0 +1,80 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"TetriON.WebServer/server/internal/config"
+	"TetriON.WebServer/server/internal/logging"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages through a concrete transport
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// ErrMailerUnavailable is returned by Send when the configured mailer could not be set up
+var ErrMailerUnavailable = errors.New("mailer is not available")
+
+var mailer Mailer = &LogMailer{}
+
+// Init selects the mailer configured by the email_service key ("smtp", "file" or "stdout")
+func Init() {
+	service := strings.ToLower(strings.TrimSpace(fmt.Sprint(config.GetConfig(config.CONFIG_EMAIL_SERVICE))))
+
+	switch service {
+	case "smtp":
+		smtpMailer, err := NewSMTPMailer()
+		if err != nil {
+			// Falling back to a development mailer would leak verification and reset links
+			logging.LogError("SMTP mailer unavailable, emails will not be sent: %v", err)
+			mailer = unavailableMailer{}
+			return
+		}
+		mailer = smtpMailer
+	case "file":
+		mailer = &LogMailer{Path: config.GetEnvOrDefault(config.ENV_MAIL_FILE_PATH, "logs/mail.log")}
+	default:
+		mailer = &LogMailer{}
+	}
+
+	logging.LogInfo("Mailer initialized (%T)", mailer)
+}
+
+// Send delivers a message through the configured mailer
+func Send(ctx context.Context, msg Message) error {
+	return mailer.Send(ctx, msg)
+}
+
+// SetMailer replaces the active mailer
+func SetMailer(m Mailer) {
+	if m != nil {
+		mailer = m
+	}
+}
+
+// unavailableMailer refuses every message; it stands in for a configured mailer that failed
+type unavailableMailer struct{}
+
+func (unavailableMailer) Send(_ context.Context, msg Message) error {
+	logging.LogError("Dropped email %q to %s: %v", msg.Subject, msg.To, ErrMailerUnavailable)
+	return ErrMailerUnavailable
+}
+
+// PublicURL builds an absolute URL to this server for links embedded in emails
+func PublicURL(path string) string {
+	base := config.GetEnv(config.ENV_PUBLIC_BASE_URL)
+	if base == "" {
+		base = "http://localhost:" + fmt.Sprint(config.GetConfig(config.CONFIG_SERVER_PORT))
+	}
+	return strings.TrimRight(base, "/") + path
+}
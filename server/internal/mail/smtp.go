@@ -0,0 +1,67 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"TetriON.WebServer/server/internal/config"
+)
+
+// SMTPMailer sends mail through an SMTP relay
+type SMTPMailer struct {
+	addr string
+	host string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer builds an SMTP mailer from the SMTP_* environment variables
+func NewSMTPMailer() (*SMTPMailer, error) {
+	host := config.GetEnv(config.ENV_SMTP_HOST)
+	if host == "" {
+		return nil, errors.New("SMTP_HOST not configured")
+	}
+	from := config.GetEnv(config.ENV_SMTP_FROM)
+	if from == "" {
+		return nil, errors.New("SMTP_FROM not configured")
+	}
+
+	m := &SMTPMailer{
+		addr: net.JoinHostPort(host, config.GetEnvOrDefault(config.ENV_SMTP_PORT, "587")),
+		host: host,
+		from: from,
+	}
+	if username := config.GetEnv(config.ENV_SMTP_USERNAME); username != "" {
+		m.auth = smtp.PlainAuth("", username, config.GetEnv(config.ENV_SMTP_PASSWORD), host)
+	}
+	return m, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, []byte(b.String()))
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-done:
+		return err
+	}
+}
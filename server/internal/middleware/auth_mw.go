@@ -5,9 +5,10 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
-	"os"
 	"time"
 
+	"TetriON.WebServer/server/internal/auth/signing"
+	redisnet "TetriON.WebServer/server/internal/net/redis"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -16,15 +17,21 @@ type contextKey string
 const userContextKey contextKey = "authenticated_user"
 
 type AuthUser struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	UserID    string `json:"user_id"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	SessionID string `json:"session_id,omitempty"`
+	Role      string `json:"role,omitempty"`
+	Guest     bool   `json:"guest,omitempty"`
 }
 
 type tokenClaims struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	UserID    string `json:"user_id"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	SessionID string `json:"sid,omitempty"`
+	Role      string `json:"role,omitempty"`
+	Guest     bool   `json:"guest,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -43,8 +50,8 @@ func RequireAuth(next http.Handler) http.Handler {
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), userContextKey, user)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		recordSessionActivity(user.SessionID)
+		next.ServeHTTP(w, r.WithContext(withUser(r.Context(), user)))
 	})
 }
 
@@ -66,17 +73,7 @@ func extractBearerToken(authHeader string) string {
 }
 
 func validateToken(tokenString string) (*AuthUser, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		return nil, errors.New("JWT_SECRET not configured")
-	}
-
-	token, err := jwt.ParseWithClaims(tokenString, &tokenClaims{}, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(secret), nil
-	})
+	token, err := signing.Parse(tokenString, &tokenClaims{})
 	if err != nil {
 		return nil, err
 	}
@@ -89,10 +86,25 @@ func validateToken(tokenString string) (*AuthUser, error) {
 		return nil, errors.New("token expired")
 	}
 
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+	revoked, err := redisnet.IsTokenRevoked(context.Background(), claims.ID, claims.SessionID, claims.UserID, issuedAt)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errors.New("token revoked")
+	}
+
 	return &AuthUser{
-		UserID:   claims.UserID,
-		Username: claims.Username,
-		Email:    claims.Email,
+		UserID:    claims.UserID,
+		Username:  claims.Username,
+		Email:     claims.Email,
+		SessionID: claims.SessionID,
+		Role:      claims.Role,
+		Guest:     claims.Guest,
 	}, nil
 }
 
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+const principalContextKey contextKey = "authenticated_principal"
+
+// PrincipalType tells which kind of credential authenticated a request.
+type PrincipalType string
+
+const (
+	PrincipalUser    PrincipalType = "user"
+	PrincipalService PrincipalType = "service"
+)
+
+// ServicePrincipal is a service account authenticated by API key.
+type ServicePrincipal struct {
+	AccountID string   `json:"account_id"`
+	Name      string   `json:"name"`
+	KeyID     string   `json:"key_id"`
+	Scopes    []string `json:"scopes"`
+}
+
+// Principal is the caller of an authenticated request: either a user (JWT) or a service (API key).
+type Principal struct {
+	Type    PrincipalType     `json:"type"`
+	User    *AuthUser         `json:"user,omitempty"`
+	Service *ServicePrincipal `json:"service,omitempty"`
+}
+
+// HasScope reports whether the principal is a service granted the scope.
+func (p *Principal) HasScope(scope string) bool {
+	if p == nil || p.Service == nil {
+		return false
+	}
+	for _, granted := range p.Service.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ServiceKeyAuthenticator resolves a raw API key to a service principal.
+type ServiceKeyAuthenticator func(key string) (*ServicePrincipal, error)
+
+var (
+	serviceKeyAuthenticator ServiceKeyAuthenticator
+	serviceKeyPrefix        string
+)
+
+// SetServiceKeyAuthenticator registers how API keys starting with prefix are verified.
+func SetServiceKeyAuthenticator(prefix string, fn ServiceKeyAuthenticator) {
+	serviceKeyPrefix = prefix
+	serviceKeyAuthenticator = fn
+}
+
+// Authenticate accepts either a user JWT or a service API key (Bearer or X-API-Key header)
+// and stores the resulting principal in request context. User JWTs also set AuthUser.
+func Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		credential := r.Header.Get("X-API-Key")
+		if credential == "" {
+			credential = extractBearerToken(r.Header.Get("Authorization"))
+		}
+		if credential == "" {
+			writeJSONError(w, "missing authorization token", http.StatusUnauthorized)
+			return
+		}
+
+		if serviceKeyPrefix != "" && strings.HasPrefix(credential, serviceKeyPrefix) {
+			if serviceKeyAuthenticator == nil {
+				writeJSONError(w, "invalid api key", http.StatusUnauthorized)
+				return
+			}
+			service, err := serviceKeyAuthenticator(credential)
+			if err != nil {
+				writeJSONError(w, "invalid api key", http.StatusUnauthorized)
+				return
+			}
+			principal := &Principal{Type: PrincipalService, Service: service}
+			ctx := context.WithValue(r.Context(), principalContextKey, principal)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		user, err := validateToken(credential)
+		if err != nil {
+			writeJSONError(w, "invalid or expired token", http.StatusUnauthorized)
+			return
+		}
+		recordSessionActivity(user.SessionID)
+		next.ServeHTTP(w, r.WithContext(withUser(r.Context(), user)))
+	})
+}
+
+// RequireScope only lets through service principals granted the scope; it must run after Authenticate.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				writeJSONError(w, "missing authorization token", http.StatusUnauthorized)
+				return
+			}
+			if !principal.HasScope(scope) {
+				writeJSONError(w, "insufficient scope", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// PrincipalFromContext returns the principal set by Authenticate or RequireAuth.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey).(*Principal)
+	return principal, ok
+}
+
+// withUser stores a user both as AuthUser and as a user principal.
+func withUser(ctx context.Context, user *AuthUser) context.Context {
+	ctx = context.WithValue(ctx, userContextKey, user)
+	return context.WithValue(ctx, principalContextKey, &Principal{Type: PrincipalUser, User: user})
+}
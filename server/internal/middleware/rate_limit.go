@@ -7,6 +7,9 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"TetriON.WebServer/server/internal/config"
+	"TetriON.WebServer/server/internal/logging"
 )
 
 type visitor struct {
@@ -35,7 +38,7 @@ func RateLimitMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		if !allowRequest(clientIP(r)) {
+		if !allowRequest(ClientIP(r)) {
 			writeRateLimitError(w)
 			return
 		}
@@ -67,19 +70,94 @@ func allowRequest(ip string) bool {
 	return v.count <= rateLimitMaxHits
 }
 
-func clientIP(r *http.Request) string {
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		parts := strings.Split(xff, ",")
-		if len(parts) > 0 {
-			return strings.TrimSpace(parts[0])
+// ClientIP returns the caller IP. X-Forwarded-For is only honoured when the request comes from
+// a proxy listed in trusted_proxies: the right-most hop that is not a trusted proxy is the
+// client, since every hop to the left of it could have been written by the client itself. A
+// header with a malformed hop or no untrusted hop is ignored, so a trusted proxy is never
+// reported as the client.
+func ClientIP(r *http.Request) string {
+	return clientIP(r, trustedProxies())
+}
+
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+
+	if len(trusted) == 0 || !isTrustedProxy(trusted, peer) {
+		return peer
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			// Anything left of a malformed hop cannot be attributed
+			return peer
+		}
+		if !isTrustedProxy(trusted, hop) {
+			return hop
 		}
 	}
+	return peer
+}
+
+var (
+	trustedProxiesMu  sync.Mutex
+	trustedProxiesRaw string
+	trustedProxyNets  []*net.IPNet
+)
+
+// trustedProxies parses trusted_proxies, a comma-separated list of IPs and CIDR ranges,
+// again whenever the configuration changes
+func trustedProxies() []*net.IPNet {
+	raw, _ := config.GetConfig(config.CONFIG_TRUSTED_PROXIES).(string)
 
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err == nil {
-		return host
+	trustedProxiesMu.Lock()
+	defer trustedProxiesMu.Unlock()
+	if raw != trustedProxiesRaw || trustedProxyNets == nil {
+		trustedProxiesRaw = raw
+		trustedProxyNets = parseTrustedProxies(raw)
+	}
+	return trustedProxyNets
+}
+
+func parseTrustedProxies(raw string) []*net.IPNet {
+	nets := []*net.IPNet{}
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			logging.LogWarning("Ignoring invalid trusted proxy %q: %v", entry, err)
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+func isTrustedProxy(trusted []*net.IPNet, addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
 	}
-	return r.RemoteAddr
+	return false
 }
 
 func cleanupVisitors() {
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := parseTrustedProxies("10.0.0.0/8, 192.168.1.5, fd00::/8")
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		trusted    bool
+		want       string
+	}{
+		{"no proxies configured ignores the header", "203.0.113.7:5000", []string{"198.51.100.1"}, false, "203.0.113.7"},
+		{"untrusted peer ignores the header", "203.0.113.7:5000", []string{"198.51.100.1"}, true, "203.0.113.7"},
+		{"trusted peer without header", "10.1.2.3:5000", nil, true, "10.1.2.3"},
+		{"single hop", "10.1.2.3:5000", []string{"198.51.100.1"}, true, "198.51.100.1"},
+		{"spoofed left-most entry is skipped", "10.1.2.3:5000", []string{"1.1.1.1, 198.51.100.1"}, true, "198.51.100.1"},
+		{"trusted hops are skipped from the right", "10.1.2.3:5000", []string{"1.1.1.1, 198.51.100.1, 192.168.1.5, 10.9.9.9"}, true, "198.51.100.1"},
+		{"repeated headers are joined", "10.1.2.3:5000", []string{"1.1.1.1", "198.51.100.1"}, true, "198.51.100.1"},
+		{"all hops trusted falls back to the peer", "10.1.2.3:5000", []string{"10.0.0.1, 10.0.0.2"}, true, "10.1.2.3"},
+		{"malformed hop falls back to the peer", "10.1.2.3:5000", []string{"198.51.100.1, garbage, 10.0.0.2"}, true, "10.1.2.3"},
+		{"malformed right-most hop falls back to the peer", "10.1.2.3:5000", []string{"198.51.100.1, garbage"}, true, "10.1.2.3"},
+		{"IPv6 proxy", "[fd00::1]:5000", []string{"2001:db8::42"}, true, "2001:db8::42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.xff {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			proxies := trusted
+			if !tt.trusted {
+				proxies = nil
+			}
+			if got := clientIP(r, proxies); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxiesSkipsInvalidEntries(t *testing.T) {
+	nets := parseTrustedProxies("10.0.0.0/8,,not-an-ip, 2001:db8::1")
+	if len(nets) != 2 {
+		t.Fatalf("parsed %d ranges, want 2: %v", len(nets), nets)
+	}
+	if got := nets[1].String(); got != "2001:db8::1/128" {
+		t.Errorf("single IPv6 address parsed as %s, want 2001:db8::1/128", got)
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"TetriON.WebServer/server/internal/logging"
+	redisnet "TetriON.WebServer/server/internal/net/redis"
+)
+
+var (
+	activityMu      sync.Mutex
+	pendingActivity = map[string]time.Time{}
+
+	activityRetention = 30 * 24 * time.Hour
+)
+
+// recordSessionActivity notes that a session was used; FlushSessionActivity writes the batch to Redis.
+func recordSessionActivity(sessionID string) {
+	if sessionID == "" {
+		return
+	}
+
+	activityMu.Lock()
+	pendingActivity[sessionID] = time.Now()
+	activityMu.Unlock()
+}
+
+// FlushSessionActivity writes the session activity recorded since the last flush to Redis.
+func FlushSessionActivity() {
+	activityMu.Lock()
+	batch := pendingActivity
+	pendingActivity = map[string]time.Time{}
+	activityMu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+	if err := redisnet.TouchSessions(context.Background(), batch, activityRetention); err != nil {
+		logging.LogError("Failed to record activity of %d session(s): %v", len(batch), err)
+	}
+}
@@ -0,0 +1,185 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	chatHistoryPrefix     = "chat:history:"
+	chatSlowModePrefix    = "chat:slow_mode:"
+	chatRestrictionPrefix = "chat:restriction:"
+	chatMessageSeqKey     = "chat:message_seq"
+
+	// chatEventsChannel carries chat events; every server instance hands them to the members
+	// of the channel connected to it
+	chatEventsChannel = "websocket_chat"
+
+	// chatHistoryTTL drops the history of channels nobody wrote to for a week, such as rooms
+	chatHistoryTTL = 7 * 24 * time.Hour
+)
+
+// NextChatMessageID returns a new chat message ID, unique across server instances
+func NextChatMessageID(ctx context.Context) (int64, error) {
+	if redisClient == nil {
+		return 0, fmt.Errorf("redis client is not initialized")
+	}
+
+	return redisClient.Incr(ctx, chatMessageSeqKey).Result()
+}
+
+// AppendChatHistory adds an encoded message to the front of a channel's history and keeps
+// only the newest size entries
+func AppendChatHistory(ctx context.Context, channel string, entry []byte, size int) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	key := chatHistoryPrefix + channel
+	pipe := redisClient.TxPipeline()
+	pipe.LPush(ctx, key, entry)
+	pipe.LTrim(ctx, key, 0, int64(size-1))
+	pipe.Expire(ctx, key, chatHistoryTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ChatHistory returns up to limit encoded messages of a channel, newest first
+func ChatHistory(ctx context.Context, channel string, limit int) ([]string, error) {
+	if redisClient == nil {
+		return nil, fmt.Errorf("redis client is not initialized")
+	}
+
+	return redisClient.LRange(ctx, chatHistoryPrefix+channel, 0, int64(limit-1)).Result()
+}
+
+// RemoveChatHistory removes one encoded message from a channel's history and reports whether
+// it was still there
+func RemoveChatHistory(ctx context.Context, channel, entry string) (bool, error) {
+	if redisClient == nil {
+		return false, fmt.Errorf("redis client is not initialized")
+	}
+
+	removed, err := redisClient.LRem(ctx, chatHistoryPrefix+channel, 1, entry).Result()
+	if err != nil {
+		return false, err
+	}
+	return removed > 0, nil
+}
+
+// SetChatSlowMode sets the seconds a user has to wait between messages in a channel; 0 turns
+// slow mode off
+func SetChatSlowMode(ctx context.Context, channel string, seconds int) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	key := chatSlowModePrefix + channel
+	if seconds <= 0 {
+		return redisClient.Del(ctx, key).Err()
+	}
+	return redisClient.Set(ctx, key, seconds, 0).Err()
+}
+
+// ChatSlowMode returns the slow mode interval of a channel, 0 when it is off
+func ChatSlowMode(ctx context.Context, channel string) (time.Duration, error) {
+	if redisClient == nil {
+		return 0, fmt.Errorf("redis client is not initialized")
+	}
+
+	value, err := redisClient.Get(ctx, chatSlowModePrefix+channel).Result()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	seconds, _ := strconv.Atoi(value)
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// SetChatRestriction restricts a user in a channel, such as a mute or a kick, for ttl;
+// a ttl of 0 keeps the restriction until it is cleared
+func SetChatRestriction(ctx context.Context, kind, channel, userID string, ttl time.Duration) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	return redisClient.Set(ctx, chatRestrictionKey(kind, channel, userID), 1, ttl).Err()
+}
+
+// ClearChatRestriction lifts a restriction and reports whether there was one
+func ClearChatRestriction(ctx context.Context, kind, channel, userID string) (bool, error) {
+	if redisClient == nil {
+		return false, fmt.Errorf("redis client is not initialized")
+	}
+
+	removed, err := redisClient.Del(ctx, chatRestrictionKey(kind, channel, userID)).Result()
+	if err != nil {
+		return false, err
+	}
+	return removed > 0, nil
+}
+
+// ChatRestriction reports whether a user is restricted in a channel and for how much longer;
+// the remaining time is 0 for a restriction without expiry
+func ChatRestriction(ctx context.Context, kind, channel, userID string) (bool, time.Duration, error) {
+	if redisClient == nil {
+		return false, 0, fmt.Errorf("redis client is not initialized")
+	}
+
+	remaining, err := redisClient.PTTL(ctx, chatRestrictionKey(kind, channel, userID)).Result()
+	if err != nil {
+		return false, 0, err
+	}
+	switch {
+	case remaining == -2: // no key
+		return false, 0, nil
+	case remaining < 0: // no expiry
+		return true, 0, nil
+	}
+	return true, remaining, nil
+}
+
+// PublishChatEvent publishes an encoded chat event to every server instance
+func PublishChatEvent(ctx context.Context, event []byte) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	return redisClient.Publish(ctx, chatEventsChannel, event).Err()
+}
+
+// SubscribeChatEvents blocks and hands every published chat event to onEvent until ctx is cancelled
+func SubscribeChatEvents(ctx context.Context, onEvent func(event []byte)) {
+	if redisClient == nil {
+		LogWithTime(red, "ERROR", "❌ SubscribeChatEvents called before Redis initialization")
+		return
+	}
+
+	pubsub := redisClient.Subscribe(ctx, chatEventsChannel)
+	defer pubsub.Close()
+
+	LogWithTime(cyan, "INFO", "📡 Subscribed to channel '%s'", chatEventsChannel)
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			onEvent([]byte(msg.Payload))
+		}
+	}
+}
+
+func chatRestrictionKey(kind, channel, userID string) string {
+	return chatRestrictionPrefix + kind + ":" + channel + ":" + userID
+}
@@ -8,7 +8,6 @@ import (
 	"strings"
 	"time"
 
-	"TetriON.WebServer/server/internal/net/websocket"
 	"github.com/fatih/color"
 	"github.com/redis/go-redis/v9"
 )
@@ -99,7 +98,8 @@ func PublishMessage(ctx context.Context, message string) error {
 }
 
 // --- Subscribe and listen for messages ---
-func SubscribeMessages(ctx context.Context) {
+// SubscribeMessages blocks and hands every message on the broadcast channel to onMessage.
+func SubscribeMessages(ctx context.Context, onMessage func(channel, payload string)) {
 	if redisClient == nil {
 		LogWithTime(red, "ERROR", "❌ SubscribeMessages called before Redis initialization")
 		return
@@ -113,12 +113,7 @@ func SubscribeMessages(ctx context.Context) {
 
 	for msg := range ch {
 		LogWithTime(white, "RECV", "📨 Received message: %s", msg.Payload)
-		websocket.Broadcast(map[string]any{
-			"type":      "redis_broadcast",
-			"channel":   msg.Channel,
-			"payload":   msg.Payload,
-			"timestamp": time.Now().Unix(),
-		})
+		onMessage(msg.Channel, msg.Payload)
 	}
 
 	LogWithTime(yellow, "INFO", "❌ Subscription closed for channel '%s'", redisChannel)
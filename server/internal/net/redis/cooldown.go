@@ -0,0 +1,35 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const cooldownPrefix = "cooldown:"
+
+// AcquireCooldown starts a cooldown for the given action and subject.
+// It returns false and the remaining time when a cooldown is already running.
+func AcquireCooldown(ctx context.Context, action, subject string, ttl time.Duration) (bool, time.Duration, error) {
+	if redisClient == nil {
+		return false, 0, fmt.Errorf("redis client is not initialized")
+	}
+
+	key := cooldownPrefix + action + ":" + subject
+	acquired, err := redisClient.SetNX(ctx, key, 1, ttl).Result()
+	if err != nil {
+		return false, 0, err
+	}
+	if acquired {
+		return true, 0, nil
+	}
+
+	remaining, err := redisClient.TTL(ctx, key).Result()
+	if err != nil {
+		return false, 0, err
+	}
+	if remaining < 0 {
+		remaining = ttl
+	}
+	return false, remaining, nil
+}
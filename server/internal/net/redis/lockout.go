@@ -0,0 +1,102 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"TetriON.WebServer/server/internal/redis/lua"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+const (
+	failurePrefix      = "auth:failures:"
+	lockoutPrefix      = "auth:lockout:"
+	lockoutLevelPrefix = "auth:lockout_level:"
+)
+
+var rateLimitScript = redisv9.NewScript(lua.RateLimit)
+
+// LockoutPolicy configures how failures turn into temporary lockouts.
+type LockoutPolicy struct {
+	Window      time.Duration // window in which failures are counted
+	MaxFailures int           // failures allowed per window before locking
+	BaseLockout time.Duration // first lockout; doubles with every further lockout
+	MaxLockout  time.Duration // upper bound for a single lockout
+	LevelTTL    time.Duration // how long previous lockouts keep escalating the backoff
+}
+
+// HitRateLimit counts a hit against key using rate_limit.lua and reports whether it is within max per window.
+func HitRateLimit(ctx context.Context, key string, window time.Duration, max int) (bool, error) {
+	if redisClient == nil {
+		return false, fmt.Errorf("redis client is not initialized")
+	}
+
+	allowed, err := rateLimitScript.Run(ctx, redisClient, []string{key}, int(window.Seconds()), max).Int()
+	if err != nil {
+		return false, err
+	}
+	return allowed == 1, nil
+}
+
+// LockoutRemaining returns how long the subject stays locked out, or 0 when it is not locked.
+func LockoutRemaining(ctx context.Context, kind, subject string) (time.Duration, error) {
+	if redisClient == nil {
+		return 0, fmt.Errorf("redis client is not initialized")
+	}
+
+	remaining, err := redisClient.PTTL(ctx, lockoutPrefix+kind+":"+subject).Result()
+	if err != nil {
+		return 0, err
+	}
+	if remaining < 0 {
+		return 0, nil
+	}
+	return remaining, nil
+}
+
+// RecordFailure counts a failed attempt. When the policy's limit is exceeded the subject is locked
+// out with exponential backoff and the lockout duration is returned; otherwise it returns 0.
+func RecordFailure(ctx context.Context, kind, subject string, policy LockoutPolicy) (time.Duration, error) {
+	allowed, err := HitRateLimit(ctx, failurePrefix+kind+":"+subject, policy.Window, policy.MaxFailures)
+	if err != nil {
+		return 0, err
+	}
+	if allowed {
+		return 0, nil
+	}
+
+	levelKey := lockoutLevelPrefix + kind + ":" + subject
+	pipe := redisClient.TxPipeline()
+	levelCmd := pipe.Incr(ctx, levelKey)
+	pipe.Expire(ctx, levelKey, policy.LevelTTL)
+	pipe.Del(ctx, failurePrefix+kind+":"+subject)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	lockout := policy.BaseLockout
+	for level := levelCmd.Val(); level > 1 && lockout < policy.MaxLockout; level-- {
+		lockout *= 2
+	}
+	if lockout > policy.MaxLockout {
+		lockout = policy.MaxLockout
+	}
+
+	if err := redisClient.Set(ctx, lockoutPrefix+kind+":"+subject, 1, lockout).Err(); err != nil {
+		return 0, err
+	}
+	return lockout, nil
+}
+
+// ClearFailures forgets counted failures and previous lockouts of the subject.
+func ClearFailures(ctx context.Context, kind, subject string) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	return redisClient.Del(ctx,
+		failurePrefix+kind+":"+subject,
+		lockoutLevelPrefix+kind+":"+subject,
+	).Err()
+}
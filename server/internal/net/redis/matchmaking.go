@@ -8,7 +8,15 @@ import (
 	redisv9 "github.com/redis/go-redis/v9"
 )
 
-const matchmakingQueuePrefix = "mm:queue:"
+const (
+	matchmakingQueuePrefix = "mm:queue:"
+	// The queues in which a user holds a solo ticket, so parties can refuse players who are
+	// already queued alone
+	soloQueuesPrefix = "mm:solo:"
+	// A running match is a hash of user ID to role ("player" or "spectator"). Only matchmaking
+	// and game server reports write it; it expires in case the end is never reported.
+	matchPrefix = "mm:match:"
+)
 
 func EnqueuePlayer(ctx context.Context, queue string, userID string, skill int) error {
 	if redisClient == nil {
@@ -30,6 +38,43 @@ func RemovePlayerFromQueue(ctx context.Context, queue string, userID string) err
 	return redisClient.ZRem(ctx, key, userID).Err()
 }
 
+// EnqueueSoloPlayer queues a user on their own and remembers the queue for SoloQueues
+func EnqueueSoloPlayer(ctx context.Context, queue string, userID string, skill int) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	score := float64(skill)*1_000_000 + float64(time.Now().Unix())
+
+	pipe := redisClient.TxPipeline()
+	pipe.ZAdd(ctx, matchmakingQueuePrefix+queue, redisv9.Z{Score: score, Member: userID})
+	pipe.SAdd(ctx, soloQueuesPrefix+userID, queue)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RemoveSoloPlayer takes a user's solo ticket out of a queue
+func RemoveSoloPlayer(ctx context.Context, queue string, userID string) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	pipe := redisClient.TxPipeline()
+	pipe.ZRem(ctx, matchmakingQueuePrefix+queue, userID)
+	pipe.SRem(ctx, soloQueuesPrefix+userID, queue)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SoloQueues returns the queues in which the user holds a solo ticket
+func SoloQueues(ctx context.Context, userID string) ([]string, error) {
+	if redisClient == nil {
+		return nil, fmt.Errorf("redis client is not initialized")
+	}
+
+	return redisClient.SMembers(ctx, soloQueuesPrefix+userID).Result()
+}
+
 func PeekPlayers(ctx context.Context, queue string, limit int64) ([]string, error) {
 	if redisClient == nil {
 		return nil, fmt.Errorf("redis client is not initialized")
@@ -50,3 +95,69 @@ func QueueSize(ctx context.Context, queue string) (int64, error) {
 	key := matchmakingQueuePrefix + queue
 	return redisClient.ZCard(ctx, key).Result()
 }
+
+// SetMatchParticipants adds users with a role to a match and resets its expiry
+func SetMatchParticipants(ctx context.Context, matchID string, userIDs []string, role string, ttl time.Duration) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	fields := make(map[string]any, len(userIDs))
+	for _, userID := range userIDs {
+		fields[userID] = role
+	}
+
+	key := matchPrefix + matchID
+	pipe := redisClient.TxPipeline()
+	pipe.HSet(ctx, key, fields)
+	pipe.Expire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// MatchExists reports whether a match is running
+func MatchExists(ctx context.Context, matchID string) (bool, error) {
+	if redisClient == nil {
+		return false, fmt.Errorf("redis client is not initialized")
+	}
+
+	exists, err := redisClient.Exists(ctx, matchPrefix+matchID).Result()
+	if err != nil {
+		return false, err
+	}
+	return exists > 0, nil
+}
+
+// MatchRole returns the role of a user in a running match, or "" when they take no part in it
+func MatchRole(ctx context.Context, matchID, userID string) (string, error) {
+	if redisClient == nil {
+		return "", fmt.Errorf("redis client is not initialized")
+	}
+
+	role, err := redisClient.HGet(ctx, matchPrefix+matchID, userID).Result()
+	if err == redisv9.Nil {
+		return "", nil
+	}
+	return role, err
+}
+
+// RemoveMatchParticipant takes a user out of a running match
+func RemoveMatchParticipant(ctx context.Context, matchID, userID string) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	return redisClient.HDel(ctx, matchPrefix+matchID, userID).Err()
+}
+
+// EndMatch forgets a match and everyone in it
+func EndMatch(ctx context.Context, matchID string) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	return redisClient.Del(ctx, matchPrefix+matchID).Err()
+}
@@ -0,0 +1,116 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+const (
+	partyPrefix           = "party:"
+	partyMembershipPrefix = "party:user:"
+
+	// partyTTL drops parties nobody changed for a day
+	partyTTL = 24 * time.Hour
+
+	partyUpdateAttempts = 5
+)
+
+var ErrPartyConflict = errors.New("party changed concurrently, try again")
+
+// PartyWrite is what a party update stores
+type PartyWrite struct {
+	Party       []byte            // encoded party; nil deletes the party
+	Memberships map[string]string // user ID -> party ID; an empty party ID removes the membership
+}
+
+// GetParty returns an encoded party, or nil when it does not exist
+func GetParty(ctx context.Context, partyID string) ([]byte, error) {
+	if redisClient == nil {
+		return nil, fmt.Errorf("redis client is not initialized")
+	}
+
+	data, err := redisClient.Get(ctx, partyPrefix+partyID).Bytes()
+	if err == redisv9.Nil {
+		return nil, nil
+	}
+	return data, err
+}
+
+// PartyOf returns the ID of the party a user belongs to, or "" when they are in none
+func PartyOf(ctx context.Context, userID string) (string, error) {
+	if redisClient == nil {
+		return "", fmt.Errorf("redis client is not initialized")
+	}
+
+	partyID, err := redisClient.Get(ctx, partyMembershipPrefix+userID).Result()
+	if err == redisv9.Nil {
+		return "", nil
+	}
+	return partyID, err
+}
+
+// UpdateParty reads a party and the memberships of userIDs and stores what update returns in
+// one optimistic transaction, retrying when one of them changes concurrently. The party is
+// nil when it does not exist; update returns a nil write to change nothing.
+func UpdateParty(ctx context.Context, partyID string, userIDs []string, update func(party []byte, memberships map[string]string) (*PartyWrite, error)) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	keys := []string{partyPrefix + partyID}
+	for _, userID := range userIDs {
+		keys = append(keys, partyMembershipPrefix+userID)
+	}
+
+	txn := func(tx *redisv9.Tx) error {
+		party, err := tx.Get(ctx, keys[0]).Bytes()
+		if err == redisv9.Nil {
+			party = nil
+		} else if err != nil {
+			return err
+		}
+
+		memberships := make(map[string]string, len(userIDs))
+		for i, userID := range userIDs {
+			current, err := tx.Get(ctx, keys[i+1]).Result()
+			if err != nil && err != redisv9.Nil {
+				return err
+			}
+			memberships[userID] = current
+		}
+
+		write, err := update(party, memberships)
+		if err != nil || write == nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redisv9.Pipeliner) error {
+			if write.Party == nil {
+				pipe.Del(ctx, keys[0])
+			} else {
+				pipe.Set(ctx, keys[0], write.Party, partyTTL)
+			}
+			for userID, membership := range write.Memberships {
+				if membership == "" {
+					pipe.Del(ctx, partyMembershipPrefix+userID)
+				} else {
+					pipe.Set(ctx, partyMembershipPrefix+userID, membership, partyTTL)
+				}
+			}
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < partyUpdateAttempts; attempt++ {
+		err := redisClient.Watch(ctx, txn, keys...)
+		if err != redisv9.TxFailedErr {
+			return err
+		}
+	}
+	return ErrPartyConflict
+}
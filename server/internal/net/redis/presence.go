@@ -3,12 +3,38 @@ package redis
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
+
+	"TetriON.WebServer/server/internal/redis/lua"
+	"github.com/redis/go-redis/v9"
+)
+
+// Presence is stored as a hash per user (status, activity, mode, match_id, since) that
+// expires unless it is kept alive. A counter next to it tracks the user's open connections
+// across all server instances and expires like the presence.
+const (
+	presencePrefix    = "presence:user:"
+	connectionsPrefix = "presence:connections:"
 )
 
-const presencePrefix = "presence:user:"
+var releaseConnectionScript = redis.NewScript(lua.ReleaseConnection)
 
+// SetUserOnline writes a fresh presence hash: online, in the menu since now
 func SetUserOnline(ctx context.Context, userID string, ttl time.Duration) error {
+	return SetPresence(ctx, userID, map[string]string{
+		"status":   "online",
+		"activity": "menu",
+		"mode":     "",
+		"match_id": "",
+		"since":    strconv.FormatInt(time.Now().Unix(), 10),
+	}, ttl)
+}
+
+// SetPresence writes the given presence fields and resets the TTL in one transaction.
+// Empty values clear a field.
+func SetPresence(ctx context.Context, userID string, fields map[string]string, ttl time.Duration) error {
 	if redisClient == nil {
 		return fmt.Errorf("redis client is not initialized")
 	}
@@ -17,19 +43,66 @@ func SetUserOnline(ctx context.Context, userID string, ttl time.Duration) error
 	}
 
 	key := presencePrefix + userID
-	return redisClient.Set(ctx, key, "online", ttl).Err()
+	pipe := redisClient.TxPipeline()
+	pipe.HSet(ctx, key, fields)
+	pipe.Expire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
-func HeartbeatUser(ctx context.Context, userID string, ttl time.Duration) error {
+// HeartbeatUser extends the presence of an online user and reports whether the key still
+// existed. Unlike a write it causes no "hset" keyspace event and so no presence notification.
+func HeartbeatUser(ctx context.Context, userID string, ttl time.Duration) (bool, error) {
 	if redisClient == nil {
-		return fmt.Errorf("redis client is not initialized")
+		return false, fmt.Errorf("redis client is not initialized")
 	}
 	if ttl <= 0 {
 		ttl = 60 * time.Second
 	}
 
 	key := presencePrefix + userID
-	return redisClient.Expire(ctx, key, ttl).Err()
+	return redisClient.Expire(ctx, key, ttl).Result()
+}
+
+// AddUserConnection counts a new connection of the user and returns how many are open
+func AddUserConnection(ctx context.Context, userID string, ttl time.Duration) (int64, error) {
+	if redisClient == nil {
+		return 0, fmt.Errorf("redis client is not initialized")
+	}
+	if ttl <= 0 {
+		ttl = 60 * time.Second
+	}
+
+	key := connectionsPrefix + userID
+	pipe := redisClient.TxPipeline()
+	count := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
+}
+
+// RefreshUserConnections keeps the connection counter of a connected user from expiring
+func RefreshUserConnections(ctx context.Context, userID string, ttl time.Duration) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+	if ttl <= 0 {
+		ttl = 60 * time.Second
+	}
+
+	return redisClient.Expire(ctx, connectionsPrefix+userID, ttl).Err()
+}
+
+// ReleaseUserConnection uncounts a closed connection and returns how many are still open on
+// any server instance
+func ReleaseUserConnection(ctx context.Context, userID string) (int64, error) {
+	if redisClient == nil {
+		return 0, fmt.Errorf("redis client is not initialized")
+	}
+
+	return releaseConnectionScript.Run(ctx, redisClient, []string{connectionsPrefix + userID}).Int64()
 }
 
 func SetUserOffline(ctx context.Context, userID string) error {
@@ -41,6 +114,8 @@ func SetUserOffline(ctx context.Context, userID string) error {
 	return redisClient.Del(ctx, key).Err()
 }
 
+// IsUserOnline reports whether the user has a presence hash at all. It ignores the status;
+// what other players may see is decided by the presence package.
 func IsUserOnline(ctx context.Context, userID string) (bool, error) {
 	if redisClient == nil {
 		return false, fmt.Errorf("redis client is not initialized")
@@ -53,3 +128,105 @@ func IsUserOnline(ctx context.Context, userID string) (bool, error) {
 	}
 	return exists > 0, nil
 }
+
+// GetPresence returns the presence hash of a user, or an empty map when they are offline
+func GetPresence(ctx context.Context, userID string) (map[string]string, error) {
+	if redisClient == nil {
+		return nil, fmt.Errorf("redis client is not initialized")
+	}
+
+	return redisClient.HGetAll(ctx, presencePrefix+userID).Result()
+}
+
+// GetPresences returns the presence hashes of several users in a single round trip; users
+// without presence are omitted
+func GetPresences(ctx context.Context, userIDs []string) (map[string]map[string]string, error) {
+	if redisClient == nil {
+		return nil, fmt.Errorf("redis client is not initialized")
+	}
+
+	presences := make(map[string]map[string]string, len(userIDs))
+	if len(userIDs) == 0 {
+		return presences, nil
+	}
+
+	pipe := redisClient.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(userIDs))
+	for i, userID := range userIDs {
+		cmds[i] = pipe.HGetAll(ctx, presencePrefix+userID)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	for i, userID := range userIDs {
+		if fields := cmds[i].Val(); len(fields) > 0 {
+			presences[userID] = fields
+		}
+	}
+	return presences, nil
+}
+
+// presenceEvents are the keyspace notification flags presence changes need: K (keyspace
+// channel), h (hset), g (del) and x (expired)
+const presenceEvents = "Khgx"
+
+// EnablePresenceNotifications turns on the keyspace notifications SubscribePresence relies on,
+// keeping any flags that are already enabled. Managed Redis services often forbid CONFIG SET;
+// there the flags have to be configured on the service.
+func EnablePresenceNotifications(ctx context.Context) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	current, err := redisClient.ConfigGet(ctx, "notify-keyspace-events").Result()
+	if err != nil {
+		return err
+	}
+	flags := current["notify-keyspace-events"]
+
+	missing := ""
+	for _, flag := range presenceEvents {
+		if !strings.ContainsRune(flags, flag) && !(flag != 'K' && strings.ContainsRune(flags, 'A')) {
+			missing += string(flag)
+		}
+	}
+	if missing == "" {
+		return nil
+	}
+	return redisClient.ConfigSet(ctx, "notify-keyspace-events", flags+missing).Err()
+}
+
+// SubscribePresence blocks and reports every user whose presence hash is written
+// (removed=false) or deleted or expired (removed=true) until ctx is cancelled
+func SubscribePresence(ctx context.Context, onChange func(userID string, removed bool)) {
+	if redisClient == nil {
+		LogWithTime(red, "ERROR", "❌ SubscribePresence called before Redis initialization")
+		return
+	}
+
+	prefix := fmt.Sprintf("__keyspace@%d__:%s", redisClient.Options().DB, presencePrefix)
+	pubsub := redisClient.PSubscribe(ctx, prefix+"*")
+	defer pubsub.Close()
+
+	LogWithTime(cyan, "INFO", "📡 Subscribed to presence keyspace events '%s*'", prefix)
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			userID := strings.TrimPrefix(msg.Channel, prefix)
+			switch msg.Payload {
+			case "hset":
+				onChange(userID, false)
+			case "del", "expired":
+				onChange(userID, true)
+			}
+		}
+	}
+}
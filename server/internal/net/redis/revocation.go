@@ -0,0 +1,150 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+const (
+	revokedTokenPrefix   = "auth:revoked:jti:"
+	revokedSessionPrefix = "auth:revoked:sid:"
+	revokedUserPrefix    = "auth:revoked:user:"
+
+	// sessionRevokedChannel tells every server instance to close the sockets of revoked sessions
+	sessionRevokedChannel = "auth_session_revoked"
+)
+
+type sessionRevocation struct {
+	UserID    string `json:"user_id"`
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// RevokeToken blacklists a single token ID until it would have expired anyway.
+func RevokeToken(ctx context.Context, tokenID string, ttl time.Duration) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+	if ttl <= 0 {
+		return nil
+	}
+
+	return redisClient.Set(ctx, revokedTokenPrefix+tokenID, 1, ttl).Err()
+}
+
+// RevokeSession blacklists every token issued for the given session ID.
+func RevokeSession(ctx context.Context, sessionID string, ttl time.Duration) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+	if ttl <= 0 {
+		return nil
+	}
+
+	return redisClient.Set(ctx, revokedSessionPrefix+sessionID, 1, ttl).Err()
+}
+
+// RevokeUserTokensBefore blacklists every token of the user issued before the given time.
+// iat only has whole seconds, so the cutoff is rounded up to the next second: tokens issued
+// earlier in that second are revoked too, at the price of also rejecting the few issued later
+// in it.
+func RevokeUserTokensBefore(ctx context.Context, userID string, before time.Time, ttl time.Duration) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+	if ttl <= 0 {
+		return nil
+	}
+
+	cutoff := before.Truncate(time.Second)
+	if cutoff.Before(before) {
+		cutoff = cutoff.Add(time.Second)
+	}
+	return redisClient.Set(ctx, revokedUserPrefix+userID, cutoff.Unix(), ttl).Err()
+}
+
+// IsTokenRevoked reports whether a token was revoked by ID, by session or by a user-wide cutoff.
+func IsTokenRevoked(ctx context.Context, tokenID, sessionID, userID string, issuedAt time.Time) (bool, error) {
+	if redisClient == nil {
+		return false, fmt.Errorf("redis client is not initialized")
+	}
+
+	var tokenCmd, sessionCmd *redisv9.IntCmd
+	var userCmd *redisv9.StringCmd
+	_, err := redisClient.Pipelined(ctx, func(pipe redisv9.Pipeliner) error {
+		if tokenID != "" {
+			tokenCmd = pipe.Exists(ctx, revokedTokenPrefix+tokenID)
+		}
+		if sessionID != "" {
+			sessionCmd = pipe.Exists(ctx, revokedSessionPrefix+sessionID)
+		}
+		userCmd = pipe.Get(ctx, revokedUserPrefix+userID)
+		return nil
+	})
+	if err != nil && err != redisv9.Nil {
+		return false, err
+	}
+
+	if tokenCmd != nil && tokenCmd.Val() > 0 {
+		return true, nil
+	}
+	if sessionCmd != nil && sessionCmd.Val() > 0 {
+		return true, nil
+	}
+	if cutoff, err := userCmd.Result(); err == nil {
+		if unix, err := strconv.ParseInt(cutoff, 10, 64); err == nil && issuedAt.Unix() < unix {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// PublishSessionRevoked tells every server instance that a session was revoked.
+// An empty sessionID means every session of the user.
+func PublishSessionRevoked(ctx context.Context, userID, sessionID string) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	data, err := json.Marshal(sessionRevocation{UserID: userID, SessionID: sessionID})
+	if err != nil {
+		return err
+	}
+	return redisClient.Publish(ctx, sessionRevokedChannel, data).Err()
+}
+
+// SubscribeSessionRevocations blocks and hands every published session revocation to onRevoked
+// until ctx is cancelled
+func SubscribeSessionRevocations(ctx context.Context, onRevoked func(userID, sessionID string)) {
+	if redisClient == nil {
+		LogWithTime(red, "ERROR", "❌ SubscribeSessionRevocations called before Redis initialization")
+		return
+	}
+
+	pubsub := redisClient.Subscribe(ctx, sessionRevokedChannel)
+	defer pubsub.Close()
+
+	LogWithTime(cyan, "INFO", "📡 Subscribed to channel '%s'", sessionRevokedChannel)
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var revocation sessionRevocation
+			if err := json.Unmarshal([]byte(msg.Payload), &revocation); err != nil || revocation.UserID == "" {
+				LogWithTime(red, "ERROR", "❌ Invalid session revocation: %s", msg.Payload)
+				continue
+			}
+			onRevoked(revocation.UserID, revocation.SessionID)
+		}
+	}
+}
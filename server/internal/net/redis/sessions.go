@@ -0,0 +1,59 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const sessionSeenPrefix = "auth:session_seen:"
+
+// TouchSessions stores the last time each session was used, in a single round trip.
+func TouchSessions(ctx context.Context, seen map[string]time.Time, ttl time.Duration) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+
+	pipe := redisClient.Pipeline()
+	for sessionID, at := range seen {
+		pipe.Set(ctx, sessionSeenPrefix+sessionID, at.Unix(), ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SessionsLastSeen returns the last recorded use of the given sessions; unknown sessions are omitted.
+func SessionsLastSeen(ctx context.Context, sessionIDs []string) (map[string]time.Time, error) {
+	if redisClient == nil {
+		return nil, fmt.Errorf("redis client is not initialized")
+	}
+
+	seen := make(map[string]time.Time, len(sessionIDs))
+	if len(sessionIDs) == 0 {
+		return seen, nil
+	}
+
+	keys := make([]string, len(sessionIDs))
+	for i, id := range sessionIDs {
+		keys[i] = sessionSeenPrefix + id
+	}
+	values, err := redisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if unix, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			seen[sessionIDs[i]] = time.Unix(unix, 0)
+		}
+	}
+	return seen, nil
+}
@@ -0,0 +1,69 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+const ticketPrefix = "ticket:"
+
+// PutTicket stores a short-lived value under an opaque ticket ID.
+func PutTicket(ctx context.Context, kind, id, value string, ttl time.Duration) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	return redisClient.Set(ctx, ticketPrefix+kind+":"+id, value, ttl).Err()
+}
+
+// GetTicket returns the value of a ticket without consuming it.
+func GetTicket(ctx context.Context, kind, id string) (string, bool, error) {
+	if redisClient == nil {
+		return "", false, fmt.Errorf("redis client is not initialized")
+	}
+
+	value, err := redisClient.Get(ctx, ticketPrefix+kind+":"+id).Result()
+	if err == redisv9.Nil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// TakeTicket atomically returns and deletes a ticket so it can only be used once.
+func TakeTicket(ctx context.Context, kind, id string) (string, bool, error) {
+	if redisClient == nil {
+		return "", false, fmt.Errorf("redis client is not initialized")
+	}
+
+	value, err := redisClient.GetDel(ctx, ticketPrefix+kind+":"+id).Result()
+	if err == redisv9.Nil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// CountTicketFailure increments the failed attempt counter of a ticket and returns the new count.
+func CountTicketFailure(ctx context.Context, kind, id string, ttl time.Duration) (int64, error) {
+	if redisClient == nil {
+		return 0, fmt.Errorf("redis client is not initialized")
+	}
+
+	key := ticketPrefix + kind + ":" + id + ":failures"
+	count, err := redisClient.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		redisClient.Expire(ctx, key, ttl)
+	}
+	return count, nil
+}
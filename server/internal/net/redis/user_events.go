@@ -0,0 +1,45 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+)
+
+// userEventsChannel carries events addressed to particular users. Every server instance
+// subscribes and hands each event to the sockets of those users it holds.
+const userEventsChannel = "websocket_users"
+
+// PublishUserEvent publishes an encoded user event to every server instance
+func PublishUserEvent(ctx context.Context, event []byte) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	return redisClient.Publish(ctx, userEventsChannel, event).Err()
+}
+
+// SubscribeUserEvents blocks and hands every published user event to onEvent until ctx is cancelled
+func SubscribeUserEvents(ctx context.Context, onEvent func(event []byte)) {
+	if redisClient == nil {
+		LogWithTime(red, "ERROR", "❌ SubscribeUserEvents called before Redis initialization")
+		return
+	}
+
+	pubsub := redisClient.Subscribe(ctx, userEventsChannel)
+	defer pubsub.Close()
+
+	LogWithTime(cyan, "INFO", "📡 Subscribed to channel '%s'", userEventsChannel)
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			onEvent([]byte(msg.Payload))
+		}
+	}
+}
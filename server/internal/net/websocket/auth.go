@@ -1,15 +1,19 @@
 package websocket
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/domain/presence"
 	"TetriON.WebServer/server/internal/logging"
 	"github.com/coder/websocket"
 	"github.com/coder/websocket/wsjson"
 )
 
-// AuthWSHandler handles WebSocket authentication
+// AuthWSHandler authenticates a WebSocket with the first frame and keeps it open as a user-bound client
 func AuthWSHandler(w http.ResponseWriter, r *http.Request) {
 	conn, err := websocket.Accept(w, r, nil)
 	if err != nil {
@@ -19,7 +23,8 @@ func AuthWSHandler(w http.ResponseWriter, r *http.Request) {
 	defer conn.CloseNow()
 
 	var payload struct {
-		Token string `json:"token"`
+		Token  string `json:"token"`
+		Status string `json:"status"` // initial presence status, online when omitted
 	}
 
 	if err := wsjson.Read(r.Context(), conn, &payload); err != nil {
@@ -27,8 +32,18 @@ func AuthWSHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Verify token using auth package
-	user, err := auth.ValidateToken(payload.Token)
+	// Verify token using auth package (rejects revoked tokens)
+	user, claims, err := auth.AuthenticateToken(payload.Token)
+	var banErr *auth.BanError
+	if errors.As(err, &banErr) {
+		logging.LogWarning("Banned user rejected from WebSocket: %v", err)
+		wsjson.Write(r.Context(), conn, map[string]any{
+			"success": false,
+			"error":   "banned",
+			"ban":     banErr,
+		})
+		return
+	}
 	if err != nil {
 		logging.LogWarning("Invalid token in WebSocket auth: %v", err)
 		wsjson.Write(r.Context(), conn, map[string]any{
@@ -47,4 +62,17 @@ func AuthWSHandler(w http.ResponseWriter, r *http.Request) {
 		"success": true,
 		"user":    user,
 	})
+
+	if hub == nil {
+		return
+	}
+
+	client := NewClient(fmt.Sprintf("%s-%d", user.ID, time.Now().UnixNano()), conn)
+	client.UserID = user.ID
+	client.Username = user.Username
+	client.SessionID = claims.SessionID
+	if presence.ValidStatus(payload.Status) {
+		client.SetPresenceStatus(payload.Status)
+	}
+	runClient(r.Context(), client, clientTimeout)
 }
@@ -0,0 +1,92 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+
+	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/domain/chat"
+	"TetriON.WebServer/server/internal/logging"
+)
+
+// SendToChannel pushes a message to every connected client that joined a chat channel
+func SendToChannel(channel string, payload any) int {
+	if hub == nil {
+		return 0
+	}
+	return hub.SendToChannel(channel, payload)
+}
+
+// RemoveFromChannel makes the connected clients of a user leave a chat channel
+func RemoveFromChannel(channel, userID string) int {
+	if hub == nil {
+		return 0
+	}
+	return hub.RemoveFromChannel(channel, userID)
+}
+
+// handleChatMessage handles the chat frames of an authenticated client:
+// {"type":"chat_join","channel":...} answered with chat_joined and the history,
+// {"type":"chat_leave","channel":...} answered with chat_left,
+// {"type":"chat_message","channel":...,"body":...} delivered to the channel as chat_message and
+// {"type":"chat_delete","channel":...,"message_id":...} delivered to the channel as chat_deleted.
+// Failures are answered with chat_error.
+func handleChatMessage(ctx context.Context, client *Client, raw map[string]any) {
+	channel, _ := raw["channel"].(string)
+
+	switch raw["type"] {
+	case "chat_join":
+		history, err := chat.Join(ctx, client.UserID, channel)
+		if err != nil {
+			client.Queue(chatError(client, channel, "join channel", err))
+			return
+		}
+		client.JoinChannel(channel)
+		client.Queue(map[string]any{"type": "chat_joined", "channel": channel, "history": history})
+
+	case "chat_leave":
+		client.LeaveChannel(channel)
+		client.Queue(map[string]any{"type": "chat_left", "channel": channel})
+
+	case "chat_message":
+		if !client.InChannel(channel) {
+			client.Queue(map[string]any{"type": "chat_error", "channel": channel, "error": "join the channel first"})
+			return
+		}
+		body, _ := raw["body"].(string)
+		if _, err := chat.Post(ctx, client.UserID, client.Username, channel, body); err != nil {
+			client.Queue(chatError(client, channel, "post chat message", err))
+		}
+
+	case "chat_delete":
+		messageID, _ := raw["message_id"].(float64)
+		if err := chat.Delete(ctx, client.UserID, channel, int64(messageID), ""); err != nil {
+			client.Queue(chatError(client, channel, "delete chat message", err))
+		}
+	}
+}
+
+func chatError(client *Client, channel, action string, err error) map[string]any {
+	reply := map[string]any{"type": "chat_error", "channel": channel}
+
+	var banErr *auth.BanError
+	var waitErr *chat.WaitError
+	switch {
+	case errors.As(err, &banErr):
+		reply["error"] = auth.ErrBanned.Error()
+		reply["ban"] = banErr
+	case errors.As(err, &waitErr):
+		reply["error"] = waitErr.Err.Error()
+		if waitErr.RetryAfter > 0 {
+			reply["retry_after"] = waitErr.RetryAfterSeconds()
+		}
+	case err == chat.ErrInvalidChannel, err == chat.ErrChannelUnavailable, err == chat.ErrNotAllowed,
+		err == chat.ErrEmptyBody, err == chat.ErrBodyTooLong, err == chat.ErrMessageNotFound,
+		err == chat.ErrNotAuthor:
+		reply["error"] = err.Error()
+	default:
+		logging.LogError("Failed to %s %s for user %s: %v", action, channel, client.UserID, err)
+		reply["error"] = "chat operation failed"
+	}
+	return reply
+}
@@ -2,6 +2,7 @@ package websocket
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/coder/websocket"
@@ -9,9 +10,16 @@ import (
 )
 
 type Client struct {
-	Conn *websocket.Conn
-	ID   string
-	Send chan any
+	Conn      *websocket.Conn
+	ID        string
+	UserID    string
+	Username  string
+	SessionID string
+	Send      chan any
+
+	mu             sync.Mutex
+	presenceStatus string
+	channels       map[string]bool // chat channels joined on this connection
 }
 
 func NewClient(id string, conn *websocket.Conn) *Client {
@@ -22,6 +30,69 @@ func NewClient(id string, conn *websocket.Conn) *Client {
 	}
 }
 
+// Queue sends a message to this client only, dropping it when the client is not keeping up
+func (c *Client) Queue(msg any) {
+	select {
+	case c.Send <- msg:
+	default:
+	}
+}
+
+// Deliver sends a message to this client only, waiting for room in the send buffer.
+// It must only be called while the client is registered.
+func (c *Client) Deliver(ctx context.Context, msg any) bool {
+	select {
+	case c.Send <- msg:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// PresenceStatus is the status the client chose, used when its presence has to be recreated
+func (c *Client) PresenceStatus() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.presenceStatus
+}
+
+func (c *Client) SetPresenceStatus(status string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.presenceStatus = status
+}
+
+// JoinChannel adds a chat channel to this connection and reports whether it was new
+func (c *Client) JoinChannel(channel string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.channels[channel] {
+		return false
+	}
+	if c.channels == nil {
+		c.channels = make(map[string]bool)
+	}
+	c.channels[channel] = true
+	return true
+}
+
+// LeaveChannel removes a chat channel from this connection and reports whether it was joined
+func (c *Client) LeaveChannel(channel string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.channels[channel] {
+		return false
+	}
+	delete(c.channels, channel)
+	return true
+}
+
+func (c *Client) InChannel(channel string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.channels[channel]
+}
+
 func (c *Client) ReadPump(ctx context.Context, onMessage func(any)) {
 	for {
 		var payload any
@@ -1,6 +1,10 @@
 package websocket
 
-import "sync"
+import (
+	"sync"
+
+	"github.com/coder/websocket"
+)
 
 type Hub struct {
 	mu         sync.RWMutex
@@ -28,11 +32,18 @@ func (h *Hub) Run() {
 			h.mu.Unlock()
 		case client := <-h.unregister:
 			h.mu.Lock()
+			authenticated := false
 			if _, exists := h.clients[client.ID]; exists {
 				delete(h.clients, client.ID)
 				close(client.Send)
+				authenticated = client.UserID != ""
 			}
 			h.mu.Unlock()
+			// Other instances may still hold connections of the user, so markOffline decides
+			// from the shared connection count
+			if authenticated {
+				go markOffline(client.UserID)
+			}
 		case msg := <-h.broadcast:
 			h.mu.RLock()
 			for _, c := range h.clients {
@@ -58,6 +69,98 @@ func (h *Hub) Broadcast(message any) {
 	h.broadcast <- message
 }
 
+// SendToUsers queues a message for every client of the given users and returns how many
+// clients it was queued for
+func (h *Hub) SendToUsers(userIDs []string, message any) int {
+	wanted := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		wanted[userID] = true
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	sent := 0
+	for _, c := range h.clients {
+		if c.UserID == "" || !wanted[c.UserID] {
+			continue
+		}
+		select {
+		case c.Send <- message:
+			sent++
+		default:
+		}
+	}
+	return sent
+}
+
+// SendToChannel queues a message for every client that joined a chat channel and returns
+// how many clients it was queued for
+func (h *Hub) SendToChannel(channel string, message any) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	sent := 0
+	for _, c := range h.clients {
+		if !c.InChannel(channel) {
+			continue
+		}
+		select {
+		case c.Send <- message:
+			sent++
+		default:
+		}
+	}
+	return sent
+}
+
+// RemoveFromChannel makes every client of a user leave a chat channel and returns how many did
+func (h *Hub) RemoveFromChannel(channel, userID string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	removed := 0
+	for _, c := range h.clients {
+		if c.UserID == userID && c.LeaveChannel(channel) {
+			removed++
+		}
+	}
+	return removed
+}
+
+// HasAuthenticatedClients reports whether any client is bound to a user
+func (h *Hub) HasAuthenticatedClients() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, c := range h.clients {
+		if c.UserID != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// DisconnectSession closes every client authenticated with the given session.
+// An empty sessionID closes all clients of the user.
+func (h *Hub) DisconnectSession(userID, sessionID, reason string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	closed := 0
+	for _, c := range h.clients {
+		if c.UserID == "" || c.UserID != userID {
+			continue
+		}
+		if sessionID != "" && c.SessionID != sessionID {
+			continue
+		}
+		go c.Conn.Close(websocket.StatusPolicyViolation, reason)
+		closed++
+	}
+	return closed
+}
+
 func (h *Hub) ClientCount() int {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
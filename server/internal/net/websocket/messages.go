@@ -0,0 +1,81 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+
+	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/domain/messages"
+	"TetriON.WebServer/server/internal/logging"
+)
+
+// pendingBatchSize is how many queued direct messages are loaded at a time on connect
+const pendingBatchSize = 100
+
+// deliverPendingMessages sends the direct messages that arrived while the user had no socket.
+// A message that arrives live in the meantime may be received twice; clients dedupe by ID.
+func deliverPendingMessages(ctx context.Context, client *Client) {
+	for {
+		pending, err := messages.Pending(client.UserID, pendingBatchSize)
+		if err != nil {
+			logging.LogError("Failed to load queued messages of user %s: %v", client.UserID, err)
+			return
+		}
+
+		ids := make([]int64, 0, len(pending))
+		for _, msg := range pending {
+			if !client.Deliver(ctx, map[string]any{"type": "dm", "message": msg}) {
+				break
+			}
+			ids = append(ids, msg.ID)
+		}
+		if err := messages.MarkDelivered(client.UserID, ids...); err != nil {
+			logging.LogError("Failed to mark queued messages of user %s delivered: %v", client.UserID, err)
+			return
+		}
+		if len(ids) < pendingBatchSize {
+			return
+		}
+	}
+}
+
+// handleDirectMessage sends a {"type":"dm","to":"<user id>","body":"..."} message from an
+// authenticated client and answers with dm_sent or dm_error
+func handleDirectMessage(client *Client, raw map[string]any) {
+	to, _ := raw["to"].(string)
+	body, _ := raw["body"].(string)
+
+	msg, err := messages.Send(client.UserID, to, body)
+	if err != nil {
+		client.Queue(directMessageError(client, "send", err))
+		return
+	}
+	client.Queue(map[string]any{"type": "dm_sent", "message": msg})
+}
+
+// handleReadReceipt marks messages as read for a {"type":"dm_read","user_id":"<sender id>",
+// "up_to":<message id>} message; the receipt itself reaches both users as a dm_read event
+func handleReadReceipt(client *Client, raw map[string]any) {
+	senderID, _ := raw["user_id"].(string)
+	upTo, _ := raw["up_to"].(float64)
+
+	if _, _, err := messages.MarkRead(client.UserID, senderID, int64(upTo)); err != nil {
+		client.Queue(directMessageError(client, "mark read", err))
+	}
+}
+
+func directMessageError(client *Client, action string, err error) map[string]any {
+	var banErr *auth.BanError
+	if errors.As(err, &banErr) {
+		return map[string]any{"type": "dm_error", "error": auth.ErrBanned.Error(), "ban": banErr}
+	}
+
+	switch err {
+	case messages.ErrEmptyBody, messages.ErrBodyTooLong, messages.ErrSelf, messages.ErrBlocked:
+		return map[string]any{"type": "dm_error", "error": err.Error()}
+	case auth.ErrUserNotFound:
+		return map[string]any{"type": "dm_error", "error": "player not found"}
+	}
+	logging.LogError("Failed to %s direct message for user %s: %v", action, client.UserID, err)
+	return map[string]any{"type": "dm_error", "error": "message operation failed"}
+}
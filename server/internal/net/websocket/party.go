@@ -0,0 +1,87 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+
+	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/domain/matchmaking"
+	"TetriON.WebServer/server/internal/domain/party"
+	"TetriON.WebServer/server/internal/logging"
+	redisnet "TetriON.WebServer/server/internal/net/redis"
+)
+
+// handlePartyMessage handles the party frames of an authenticated client:
+// party_invite and party_kick and party_promote with a user_id, party_accept and party_decline
+// with a party_id, party_leave and party_get, and the leader's party_queue with a queue and
+// party_dequeue. Changes reach every member as party_updated; the caller gets party_state with
+// its party (null when it has none) or party_error.
+func handlePartyMessage(ctx context.Context, client *Client, raw map[string]any) {
+	userID, _ := raw["user_id"].(string)
+	partyID, _ := raw["party_id"].(string)
+	queue, _ := raw["queue"].(string)
+
+	var current *party.Party
+	var err error
+	switch raw["type"] {
+	case "party_get":
+		current, err = party.Get(ctx, client.UserID)
+	case "party_invite":
+		current, err = party.Invite(ctx, client.UserID, client.Username, userID)
+	case "party_accept":
+		current, err = party.Accept(ctx, client.UserID, partyID)
+	case "party_decline":
+		if err = party.Decline(ctx, client.UserID, partyID); err == nil {
+			current, err = party.Get(ctx, client.UserID)
+		}
+	case "party_leave":
+		_, err = party.Leave(ctx, client.UserID)
+	case "party_kick":
+		current, err = party.Kick(ctx, client.UserID, userID)
+	case "party_promote":
+		current, err = party.Promote(ctx, client.UserID, userID)
+	case "party_queue":
+		if !matchmaking.ValidQueueName(queue) {
+			err = matchmaking.ErrInvalidQueue
+			break
+		}
+		// Skills come from the members' stored ratings, never from the client
+		current, err = matchmaking.NewManager(queue).EnqueueParty(ctx, client.UserID)
+	case "party_dequeue":
+		if current, err = party.Get(ctx, client.UserID); err != nil {
+			break
+		}
+		if current == nil {
+			err = party.ErrNotInParty
+			break
+		}
+		if err = matchmaking.NewManager(current.Queue).DequeueParty(ctx, client.UserID); err == nil {
+			current, err = party.Get(ctx, client.UserID)
+		}
+	}
+	if err != nil {
+		client.Queue(partyError(client, err))
+		return
+	}
+
+	client.Queue(map[string]any{"type": "party_state", "party": current})
+}
+
+func partyError(client *Client, err error) map[string]any {
+	switch err {
+	case party.ErrNotInParty, party.ErrAlreadyInParty, party.ErrNotLeader, party.ErrNotMember,
+		party.ErrPartyFull, party.ErrInviteNotFound, party.ErrAlreadyInvited, party.ErrSelf,
+		party.ErrBlocked, party.ErrQueued, party.ErrSoloQueued, party.ErrNotQueued, party.ErrRosterChanged,
+		matchmaking.ErrInvalidQueue, matchmaking.ErrMemberQueued, matchmaking.ErrEmailNotVerified,
+		matchmaking.ErrGuestNotAllowed, redisnet.ErrPartyConflict:
+		return map[string]any{"type": "party_error", "error": err.Error()}
+	case auth.ErrUserNotFound:
+		return map[string]any{"type": "party_error", "error": "player not found"}
+	}
+	// Another member's ban details are not the caller's business
+	if errors.Is(err, auth.ErrBanned) {
+		return map[string]any{"type": "party_error", "error": "a party member is banned from this queue"}
+	}
+	logging.LogError("Party operation failed for user %s: %v", client.UserID, err)
+	return map[string]any{"type": "party_error", "error": "party operation failed"}
+}
@@ -0,0 +1,101 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"TetriON.WebServer/server/internal/domain/party"
+	"TetriON.WebServer/server/internal/domain/presence"
+	"TetriON.WebServer/server/internal/logging"
+)
+
+const presenceHeartbeat = 25 * time.Second
+
+// SendToUsers pushes a message to every connected client of the given users
+func SendToUsers(userIDs []string, payload any) int {
+	if hub == nil || len(userIDs) == 0 {
+		return 0
+	}
+	return hub.SendToUsers(userIDs, payload)
+}
+
+// HasAuthenticatedClients reports whether any user is connected to this server
+func HasAuthenticatedClients() bool {
+	if hub == nil {
+		return false
+	}
+	return hub.HasAuthenticatedClients()
+}
+
+// keepPresence marks the client's user online and keeps the presence alive while the client
+// is connected. The connection must already be counted with presence.AddConnection.
+func keepPresence(ctx context.Context, client *Client) {
+	// Remember the status in effect so an expired presence is recreated with it
+	if status, err := presence.Connect(ctx, client.UserID, client.PresenceStatus()); err != nil {
+		logging.LogError("Failed to set presence of user %s: %v", client.UserID, err)
+	} else {
+		client.SetPresenceStatus(status)
+	}
+
+	ticker := time.NewTicker(presenceHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := presence.Heartbeat(ctx, client.UserID, client.PresenceStatus()); err != nil && ctx.Err() == nil {
+				logging.LogError("Failed to refresh presence of user %s: %v", client.UserID, err)
+			}
+		}
+	}
+}
+
+// markOffline uncounts a closed connection and, once the user has no connection left on any
+// server instance, removes their presence and takes their party out of any matchmaking queue
+func markOffline(userID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	last, err := presence.RemoveConnection(ctx, userID)
+	if err != nil {
+		logging.LogError("Failed to uncount a connection of user %s: %v", userID, err)
+		return
+	}
+	if !last {
+		return
+	}
+
+	if err := presence.Disconnect(ctx, userID); err != nil {
+		logging.LogError("Failed to clear presence of user %s: %v", userID, err)
+	}
+	party.MemberDisconnected(ctx, userID)
+}
+
+// handlePresenceMessage applies a {"type":"presence", ...} message from an authenticated client
+// and answers with the stored presence or an error
+func handlePresenceMessage(ctx context.Context, client *Client, raw map[string]any) {
+	var update presence.Update
+	encoded, _ := json.Marshal(raw)
+	if err := json.Unmarshal(encoded, &update); err != nil {
+		client.Queue(map[string]any{"type": "presence_error", "error": "invalid presence update"})
+		return
+	}
+
+	current, err := presence.Apply(ctx, client.UserID, update)
+	if err != nil {
+		switch err {
+		case presence.ErrInvalidStatus, presence.ErrInvalidActivity, presence.ErrInvalidField:
+			client.Queue(map[string]any{"type": "presence_error", "error": err.Error()})
+		default:
+			logging.LogError("Failed to update presence of user %s: %v", client.UserID, err)
+			client.Queue(map[string]any{"type": "presence_error", "error": "failed to update presence"})
+		}
+		return
+	}
+
+	client.SetPresenceStatus(current.Status)
+	client.Queue(map[string]any{"type": "presence_updated", "presence": current})
+}
@@ -11,15 +11,19 @@ import (
 	"github.com/coder/websocket/wsjson"
 
 	"TetriON.WebServer/server/internal/api"
+	"TetriON.WebServer/server/internal/auth"
 	"TetriON.WebServer/server/internal/config"
+	"TetriON.WebServer/server/internal/domain/presence"
 	"TetriON.WebServer/server/internal/logging"
+	redisnet "TetriON.WebServer/server/internal/net/redis"
 )
 
 var (
-	initialized = false
-	endpoints   = make(map[string]func(http.ResponseWriter, *http.Request))
-	server      *http.Server
-	hub         *Hub
+	initialized   = false
+	endpoints     = make(map[string]func(http.ResponseWriter, *http.Request))
+	server        *http.Server
+	hub           *Hub
+	clientTimeout time.Duration
 )
 
 func Init() {
@@ -32,12 +36,24 @@ func Init() {
 
 	timeoutVal, _ := strconv.Atoi(fmt.Sprint(config.GetConfig(config.CONFIG_SESSION_TIMEOUT)))
 	timeout := time.Duration(timeoutVal) * time.Second
+	clientTimeout = timeout
 
 	// Create a custom multiplexer
 	mux := http.NewServeMux()
 	hub = NewHub()
 	go hub.Run()
 
+	// Drop live sockets as soon as their session is revoked, on every server instance. The
+	// keyspace subscriber worker closes them when the revocation comes back through Redis.
+	auth.OnSessionRevoked(func(userID, sessionID string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := redisnet.PublishSessionRevoked(ctx, userID, sessionID); err != nil {
+			logging.LogError("Failed to publish session revocation of user %s: %v", userID, err)
+			DisconnectSession(userID, sessionID)
+		}
+	})
+
 	// WebSocket endpoints
 	mux.HandleFunc("/api/ws", func(w http.ResponseWriter, r *http.Request) {
 		handleWSClient(w, r, timeout)
@@ -120,6 +136,19 @@ func Broadcast(payload any) {
 	hub.Broadcast(payload)
 }
 
+// DisconnectSession closes the sockets of a revoked session connected to this server.
+// An empty sessionID closes every socket of the user.
+func DisconnectSession(userID, sessionID string) int {
+	if hub == nil {
+		return 0
+	}
+	closed := hub.DisconnectSession(userID, sessionID, "session revoked")
+	if closed > 0 {
+		logging.LogInfo("Closed %d WebSocket connection(s) for revoked session of user %s", closed, userID)
+	}
+	return closed
+}
+
 func handleWSClient(w http.ResponseWriter, r *http.Request, timeout time.Duration) {
 	if hub == nil {
 		http.Error(w, "websocket hub not initialized", http.StatusServiceUnavailable)
@@ -134,26 +163,61 @@ func handleWSClient(w http.ResponseWriter, r *http.Request, timeout time.Duratio
 
 	clientID := fmt.Sprintf("%s-%d", r.RemoteAddr, time.Now().UnixNano())
 	client := NewClient(clientID, conn)
-	hub.Register(client)
-
-	ctx, cancel := context.WithTimeout(r.Context(), timeout)
-	defer cancel()
 	defer conn.Close(websocket.StatusNormalClosure, "bye")
-	defer hub.Unregister(client)
 
-	_ = wsjson.Write(ctx, conn, map[string]any{
+	_ = wsjson.Write(r.Context(), conn, map[string]any{
 		"type":    "welcome",
 		"message": "connected",
 	})
 
+	runClient(r.Context(), client, timeout)
+}
+
+// runClient registers the client with the hub and pumps messages until the connection ends or times out
+func runClient(parent context.Context, client *Client, timeout time.Duration) {
+	// Count the connection before the hub can unregister it, so markOffline never uncounts
+	// a connection that was not counted yet
+	if client.UserID != "" {
+		if err := presence.AddConnection(parent, client.UserID); err != nil {
+			logging.LogError("Failed to count a connection of user %s: %v", client.UserID, err)
+		}
+	}
+	hub.Register(client)
+
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+	defer hub.Unregister(client)
+
+	if client.UserID != "" {
+		go keepPresence(ctx, client)
+	}
+
 	go client.WritePump(ctx)
+	if client.UserID != "" {
+		deliverPendingMessages(ctx, client)
+	}
 	client.ReadPump(ctx, func(v any) {
-		msg := map[string]any{
-			"type":      "ws_message",
-			"client_id": clientID,
-			"payload":   v,
-			"timestamp": time.Now().Unix(),
+		// Anonymous sockets only receive broadcasts
+		if client.UserID == "" {
+			client.Queue(map[string]any{"type": "error", "error": "authentication required"})
+			return
+		}
+
+		raw, _ := v.(map[string]any)
+		switch raw["type"] {
+		case "presence":
+			handlePresenceMessage(ctx, client, raw)
+		case "dm":
+			handleDirectMessage(client, raw)
+		case "dm_read":
+			handleReadReceipt(client, raw)
+		case "chat_join", "chat_leave", "chat_message", "chat_delete":
+			handleChatMessage(ctx, client, raw)
+		case "party_get", "party_invite", "party_accept", "party_decline", "party_leave", "party_kick", "party_promote",
+			"party_queue", "party_dequeue":
+			handlePartyMessage(ctx, client, raw)
+		default:
+			client.Queue(map[string]any{"type": "error", "error": "unknown message type"})
 		}
-		hub.Broadcast(msg)
 	})
 }
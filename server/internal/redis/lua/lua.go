@@ -0,0 +1,14 @@
+// Package lua embeds the Lua scripts executed on Redis.
+package lua
+
+import _ "embed"
+
+// RateLimit is the fixed-window rate limit script (rate_limit.lua).
+//
+//go:embed rate_limit.lua
+var RateLimit string
+
+// ReleaseConnection decrements a connection counter and deletes it at zero (release_connection.lua).
+//
+//go:embed release_connection.lua
+var ReleaseConnection string
@@ -0,0 +1,19 @@
+-- luacheck: globals KEYS ARGV redis
+---@diagnostic disable: undefined-global
+
+local KEYS = _G.KEYS
+local redis = _G.redis
+
+-- Connection counter release script.
+-- KEYS[1]: counter key
+-- Returns the connections left, deleting the counter once none are.
+
+local key = KEYS[1]
+
+local left = redis.call('DECR', key)
+if left <= 0 then
+  redis.call('DEL', key)
+  return 0
+end
+
+return left
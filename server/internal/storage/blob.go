@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"TetriON.WebServer/server/internal/config"
+	"TetriON.WebServer/server/internal/logging"
+)
+
+var (
+	ErrBlobNotFound = errors.New("blob not found")
+	ErrInvalidKey   = errors.New("invalid blob key")
+)
+
+// BlobInfo describes a stored blob
+type BlobInfo struct {
+	ContentType  string
+	Size         int64
+	LastModified time.Time
+}
+
+// BlobStore keeps binary objects under slash separated keys such as "avatars/<user>/<file>"
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get returns ErrBlobNotFound when the key does not exist; the caller closes the reader
+	Get(ctx context.Context, key string) (io.ReadCloser, *BlobInfo, error)
+	// Delete succeeds when the key does not exist
+	Delete(ctx context.Context, key string) error
+	// List returns every key starting with prefix
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+var store BlobStore = &LocalStore{Root: "data/blobs"}
+
+// Init selects the blob store configured by the blob_store key ("local" or "s3")
+func Init() {
+	backend := strings.ToLower(strings.TrimSpace(fmt.Sprint(config.GetConfig(config.CONFIG_BLOB_STORE))))
+
+	switch backend {
+	case "s3":
+		s3Store, err := NewS3Store()
+		if err != nil {
+			logging.LogError("S3 blob store unavailable, falling back to local storage: %v", err)
+			store = newLocalStore()
+			return
+		}
+		store = s3Store
+	default:
+		store = newLocalStore()
+	}
+
+	logging.LogInfo("Blob store initialized (%T)", store)
+}
+
+// Put stores data under key in the configured blob store
+func Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	return store.Put(ctx, key, data, contentType)
+}
+
+// Get opens the blob stored under key
+func Get(ctx context.Context, key string) (io.ReadCloser, *BlobInfo, error) {
+	if !ValidKey(key) {
+		return nil, nil, ErrBlobNotFound
+	}
+	return store.Get(ctx, key)
+}
+
+// Delete removes the blob stored under key
+func Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	return store.Delete(ctx, key)
+}
+
+// DeletePrefix removes every blob whose key starts with prefix and returns how many were removed.
+// The prefix must be a valid key, optionally followed by '/'.
+func DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	if !ValidKey(strings.TrimSuffix(prefix, "/")) {
+		return 0, ErrInvalidKey
+	}
+	keys, err := store.List(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+	for i, key := range keys {
+		if err := store.Delete(ctx, key); err != nil {
+			return i, err
+		}
+	}
+	return len(keys), nil
+}
+
+// SetStore replaces the active blob store
+func SetStore(s BlobStore) {
+	if s != nil {
+		store = s
+	}
+}
+
+// ValidKey reports whether key is a relative path of non-empty segments made of letters,
+// digits, '-', '_' and '.', none of them "." or ".."
+func ValidKey(key string) bool {
+	if key == "" || len(key) > 512 {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+		for _, r := range segment {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"TetriON.WebServer/server/internal/config"
+)
+
+// LocalStore keeps blobs as files below Root. The content type is derived from the key's extension.
+type LocalStore struct {
+	Root string
+}
+
+func newLocalStore() *LocalStore {
+	return &LocalStore{Root: config.GetEnvOrDefault(config.ENV_BLOB_LOCAL_PATH, "data/blobs")}
+}
+
+// Put writes to a temporary file first, so readers never see a partially written blob
+func (s *LocalStore) Put(_ context.Context, key string, data []byte, _ string) error {
+	target := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+func (s *LocalStore) Get(_ context.Context, key string) (io.ReadCloser, *BlobInfo, error) {
+	file, err := os.Open(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if stat.IsDir() {
+		file.Close()
+		return nil, nil, ErrBlobNotFound
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return file, &BlobInfo{
+		ContentType:  contentType,
+		Size:         stat.Size(),
+		LastModified: stat.ModTime(),
+	}, nil
+}
+
+func (s *LocalStore) Delete(_ context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStore) List(_ context.Context, prefix string) ([]string, error) {
+	// Only the directory holding the prefix needs to be walked
+	dir := s.Root
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		dir = s.path(prefix[:i])
+	}
+
+	keys := []string{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.Root, p)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	return keys, err
+}
+
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.Root, filepath.FromSlash(key))
+}
@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/logging"
+)
+
+// AccountPurger periodically purges accounts whose deletion grace period has elapsed.
+type AccountPurger struct {
+	interval time.Duration
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func NewAccountPurger(interval time.Duration) *AccountPurger {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	return &AccountPurger{interval: interval}
+}
+
+func (p *AccountPurger) Start(parent context.Context) {
+	ctx, cancel := context.WithCancel(parent)
+	p.cancel = cancel
+	p.wg.Add(1)
+
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		logging.LogInfo("Account purger started (every %s)", p.interval)
+		for {
+			select {
+			case <-ctx.Done():
+				logging.LogInfo("Account purger stopped")
+				return
+			case <-ticker.C:
+				RunAccountPurge()
+			}
+		}
+	}()
+}
+
+func (p *AccountPurger) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+}
+
+// RunAccountPurge purges every account past its deletion deadline and logs the outcome.
+func RunAccountPurge() {
+	purged, err := auth.PurgeExpiredAccounts()
+	if err != nil {
+		logging.LogError("Account purge failed after %d account(s): %v", purged, err)
+		return
+	}
+	if purged > 0 {
+		logging.LogInfo("Purged %d deleted account(s)", purged)
+	}
+}
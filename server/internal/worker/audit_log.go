@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"TetriON.WebServer/server/internal/auth"
+	"TetriON.WebServer/server/internal/logging"
+)
+
+// AuditWriter stores audit events queued by the auth package in the background.
+type AuditWriter struct {
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewAuditWriter() *AuditWriter {
+	return &AuditWriter{}
+}
+
+func (a *AuditWriter) Start(parent context.Context) {
+	ctx, cancel := context.WithCancel(parent)
+	a.cancel = cancel
+	a.wg.Add(1)
+
+	go func() {
+		defer a.wg.Done()
+		logging.LogInfo("Audit writer started")
+		auth.RunAuditWriter(ctx)
+		logging.LogInfo("Audit writer stopped")
+	}()
+}
+
+// Stop waits until queued events are written, so it must run before the database is closed.
+func (a *AuditWriter) Stop() {
+	if a.cancel != nil {
+		a.cancel()
+	}
+	a.wg.Wait()
+}
+
+// AuditPruner periodically deletes audit events past the retention period.
+type AuditPruner struct {
+	interval time.Duration
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func NewAuditPruner(interval time.Duration) *AuditPruner {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	return &AuditPruner{interval: interval}
+}
+
+func (p *AuditPruner) Start(parent context.Context) {
+	ctx, cancel := context.WithCancel(parent)
+	p.cancel = cancel
+	p.wg.Add(1)
+
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		logging.LogInfo("Audit pruner started (every %s)", p.interval)
+		for {
+			select {
+			case <-ctx.Done():
+				logging.LogInfo("Audit pruner stopped")
+				return
+			case <-ticker.C:
+				RunAuditPrune()
+			}
+		}
+	}()
+}
+
+func (p *AuditPruner) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+}
+
+// RunAuditPrune deletes expired audit events and logs the outcome.
+func RunAuditPrune() {
+	deleted, err := auth.PurgeAuditEvents()
+	if err != nil {
+		logging.LogError("Audit log pruning failed: %v", err)
+		return
+	}
+	if deleted > 0 {
+		logging.LogInfo("Pruned %d audit event(s) past retention", deleted)
+	}
+}
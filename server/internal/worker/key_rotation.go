@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"TetriON.WebServer/server/internal/auth/signing"
+	"TetriON.WebServer/server/internal/logging"
+)
+
+// KeyRotator periodically rotates the JWT signing keys and reloads keys created by other instances.
+type KeyRotator struct {
+	interval time.Duration
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func NewKeyRotator(interval time.Duration) *KeyRotator {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	return &KeyRotator{interval: interval}
+}
+
+func (k *KeyRotator) Start(parent context.Context) {
+	ctx, cancel := context.WithCancel(parent)
+	k.cancel = cancel
+	k.wg.Add(1)
+
+	go func() {
+		defer k.wg.Done()
+		ticker := time.NewTicker(k.interval)
+		defer ticker.Stop()
+
+		logging.LogInfo("Signing key rotator started (every %s)", k.interval)
+		for {
+			select {
+			case <-ctx.Done():
+				logging.LogInfo("Signing key rotator stopped")
+				return
+			case <-ticker.C:
+				rotated, err := signing.Rotate(false)
+				if err != nil {
+					logging.LogError("Signing key rotation failed: %v", err)
+					continue
+				}
+				if rotated {
+					logging.LogInfo("Scheduled a new JWT signing key")
+				}
+			}
+		}
+	}()
+}
+
+func (k *KeyRotator) Stop() {
+	if k.cancel != nil {
+		k.cancel()
+	}
+	k.wg.Wait()
+}
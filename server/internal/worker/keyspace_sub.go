@@ -3,30 +3,88 @@ package worker
 import (
 	"context"
 	"sync"
+	"time"
 
+	"TetriON.WebServer/server/internal/domain/chat"
+	"TetriON.WebServer/server/internal/domain/friends"
+	"TetriON.WebServer/server/internal/domain/messages"
+	"TetriON.WebServer/server/internal/domain/notify"
+	"TetriON.WebServer/server/internal/domain/party"
+	"TetriON.WebServer/server/internal/domain/presence"
 	"TetriON.WebServer/server/internal/logging"
 	redisnet "TetriON.WebServer/server/internal/net/redis"
+	"TetriON.WebServer/server/internal/net/websocket"
 )
 
+// KeyspaceSubscriber relays the Redis broadcast channel to every WebSocket client, user events
+// such as direct messages to the sockets of their users and chat events to channel members,
+// closes the sockets of revoked sessions and turns keyspace events on presence hashes into
+// presence events for the user's friends
 type KeyspaceSubscriber struct {
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	lastSeen map[string]presence.Presence // presence last pushed per user, offline users omitted
 }
 
 func NewKeyspaceSubscriber() *KeyspaceSubscriber {
-	return &KeyspaceSubscriber{}
+	return &KeyspaceSubscriber{lastSeen: make(map[string]presence.Presence)}
 }
 
 func (s *KeyspaceSubscriber) Start(parent context.Context) {
 	ctx, cancel := context.WithCancel(parent)
 	s.cancel = cancel
-	s.wg.Add(1)
+	s.wg.Add(5)
 	go func() {
 		defer s.wg.Done()
 		logging.LogInfo("Starting Redis pub/sub subscriber worker")
-		redisnet.SubscribeMessages(ctx)
+		redisnet.SubscribeMessages(ctx, func(channel, payload string) {
+			websocket.Broadcast(map[string]any{
+				"type":      "redis_broadcast",
+				"channel":   channel,
+				"payload":   payload,
+				"timestamp": time.Now().Unix(),
+			})
+		})
 		logging.LogInfo("Redis pub/sub subscriber worker stopped")
 	}()
+	go func() {
+		defer s.wg.Done()
+		logging.LogInfo("Starting user event subscriber worker")
+		redisnet.SubscribeUserEvents(ctx, deliverUserEvent)
+		logging.LogInfo("User event subscriber worker stopped")
+	}()
+	go func() {
+		defer s.wg.Done()
+		logging.LogInfo("Starting chat event subscriber worker")
+		redisnet.SubscribeChatEvents(ctx, deliverChatEvent)
+		logging.LogInfo("Chat event subscriber worker stopped")
+	}()
+	go func() {
+		defer s.wg.Done()
+		logging.LogInfo("Starting session revocation subscriber worker")
+		redisnet.SubscribeSessionRevocations(ctx, func(userID, sessionID string) {
+			websocket.DisconnectSession(userID, sessionID)
+		})
+		logging.LogInfo("Session revocation subscriber worker stopped")
+	}()
+	go func() {
+		defer s.wg.Done()
+		if err := redisnet.EnablePresenceNotifications(ctx); err != nil {
+			logging.LogWarning("Could not enable Redis keyspace notifications, friend presence events need notify-keyspace-events to include Khgx: %v", err)
+		}
+		logging.LogInfo("Starting presence keyspace subscriber worker")
+		redisnet.SubscribePresence(ctx, func(userID string, removed bool) {
+			// Presence also expires when a server dies with the user's sockets; a queued
+			// party must not wait for a member who is gone
+			if removed {
+				party.MemberDisconnected(ctx, userID)
+			}
+			s.notifyFriends(userID, removed)
+		})
+		logging.LogInfo("Presence keyspace subscriber worker stopped")
+	}()
 }
 
 func (s *KeyspaceSubscriber) Stop() {
@@ -35,3 +93,102 @@ func (s *KeyspaceSubscriber) Stop() {
 	}
 	s.wg.Wait()
 }
+
+// notifyFriends pushes a presence change to the friends of the user connected to this server.
+// Every server instance receives the keyspace event and serves its own clients.
+func (s *KeyspaceSubscriber) notifyFriends(userID string, removed bool) {
+	visible := presence.Presence{Status: presence.StatusOffline}
+	if !removed {
+		current, ok, err := presence.Get(context.Background(), userID)
+		if err != nil {
+			logging.LogError("Failed to read presence of %s: %v", userID, err)
+			return
+		}
+		if ok {
+			visible = presence.Visible(current, false)
+		}
+	}
+
+	// Invisible players look offline, so changes they make while invisible must not be pushed:
+	// only what friends would see is compared with the last event
+	s.mu.Lock()
+	last, known := s.lastSeen[userID]
+	if !known {
+		last = presence.Presence{Status: presence.StatusOffline}
+	}
+	changed := !samePresence(last, visible)
+	if visible.Status == presence.StatusOffline {
+		delete(s.lastSeen, userID)
+	} else {
+		s.lastSeen[userID] = visible
+	}
+	s.mu.Unlock()
+
+	if !changed || !websocket.HasAuthenticatedClients() {
+		return
+	}
+
+	ids, err := friends.FriendIDs(userID)
+	if err != nil {
+		logging.LogError("Failed to load friends of %s for a presence event: %v", userID, err)
+		return
+	}
+
+	websocket.SendToUsers(ids, map[string]any{
+		"type":      "presence",
+		"user_id":   userID,
+		"status":    visible.Status,
+		"presence":  visible,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// deliverUserEvent hands a user event to the sockets connected to this server and marks a
+// direct message delivered once a socket of its recipient received it
+func deliverUserEvent(data []byte) {
+	event, err := notify.Decode(data)
+	if err != nil {
+		logging.LogError("Failed to decode user event: %v", err)
+		return
+	}
+	if len(event.UserIDs) == 0 || !websocket.HasAuthenticatedClients() {
+		return
+	}
+
+	userIDs := event.UserIDs
+	if event.DeliveryOf != 0 {
+		recipientID := userIDs[0]
+		if websocket.SendToUsers(userIDs[:1], event.Payload) > 0 {
+			if err := messages.MarkDelivered(recipientID, event.DeliveryOf); err != nil {
+				logging.LogError("Failed to mark message %d delivered: %v", event.DeliveryOf, err)
+			}
+		}
+		userIDs = userIDs[1:]
+	}
+	websocket.SendToUsers(userIDs, event.Payload)
+}
+
+// deliverChatEvent hands a chat event to the channel members connected to this server and
+// removes a kicked user's sockets from the channel
+func deliverChatEvent(data []byte) {
+	event, err := chat.DecodeEvent(data)
+	if err != nil {
+		logging.LogError("Failed to decode chat event: %v", err)
+		return
+	}
+
+	websocket.SendToChannel(event.Channel, event.Payload)
+	if event.Kick != "" {
+		websocket.RemoveFromChannel(event.Channel, event.Kick)
+	}
+}
+
+func samePresence(a, b presence.Presence) bool {
+	if a.Status != b.Status || a.Activity != b.Activity || a.Mode != b.Mode || a.MatchID != b.MatchID {
+		return false
+	}
+	if a.Since == nil || b.Since == nil {
+		return a.Since == b.Since
+	}
+	return a.Since.Equal(*b.Since)
+}
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"TetriON.WebServer/server/internal/logging"
+	"TetriON.WebServer/server/internal/middleware"
+)
+
+// SessionActivityFlusher periodically writes the session activity batched by the auth
+// middleware to Redis.
+type SessionActivityFlusher struct {
+	interval time.Duration
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func NewSessionActivityFlusher(interval time.Duration) *SessionActivityFlusher {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &SessionActivityFlusher{interval: interval}
+}
+
+func (f *SessionActivityFlusher) Start(parent context.Context) {
+	ctx, cancel := context.WithCancel(parent)
+	f.cancel = cancel
+	f.wg.Add(1)
+
+	go func() {
+		defer f.wg.Done()
+		ticker := time.NewTicker(f.interval)
+		defer ticker.Stop()
+
+		logging.LogInfo("Session activity flusher started (every %s)", f.interval)
+		for {
+			select {
+			case <-ctx.Done():
+				middleware.FlushSessionActivity()
+				logging.LogInfo("Session activity flusher stopped")
+				return
+			case <-ticker.C:
+				middleware.FlushSessionActivity()
+			}
+		}
+	}()
+}
+
+// Stop writes whatever activity is still batched, so it must run before Redis is closed.
+func (f *SessionActivityFlusher) Stop() {
+	if f.cancel != nil {
+		f.cancel()
+	}
+	f.wg.Wait()
+}
@@ -51,6 +51,7 @@ try {
     Write-Host "OK Login successful!" -ForegroundColor Green
     Write-Host "  Username: $($response.user.username)" -ForegroundColor Gray
     $token = $response.token
+    $refreshToken = $response.refresh_token
     Write-Host "  Token: $($token.Substring(0, 20))..." -ForegroundColor Gray
 } catch {
     Write-Host "X Login Failed: $_" -ForegroundColor Red
@@ -75,6 +76,30 @@ if ($token) {
     Write-Host "4. Skipping profile test (no token available)" -ForegroundColor Yellow
 }
 
+Write-Host ""
+
+# Test 5: Refresh Token Rotation
+if ($refreshToken) {
+    Write-Host "5. Testing Refresh Token Rotation..." -ForegroundColor Yellow
+    $refreshBody = @{ refresh_token = $refreshToken } | ConvertTo-Json
+    try {
+        $response = Invoke-RestMethod -Uri "$BASE_URL/api/auth/refresh" -Method Post -Body $refreshBody -ContentType "application/json"
+        Write-Host "OK Token refreshed successfully!" -ForegroundColor Green
+        $token = $response.token
+    } catch {
+        Write-Host "X Refresh Failed: $_" -ForegroundColor Red
+    }
+
+    try {
+        Invoke-RestMethod -Uri "$BASE_URL/api/auth/refresh" -Method Post -Body $refreshBody -ContentType "application/json" | Out-Null
+        Write-Host "X Reused refresh token was accepted!" -ForegroundColor Red
+    } catch {
+        Write-Host "OK Reused refresh token was rejected" -ForegroundColor Green
+    }
+} else {
+    Write-Host "5. Skipping refresh test (no refresh token available)" -ForegroundColor Yellow
+}
+
 Write-Host ""
 Write-Host "========================================" -ForegroundColor Cyan
 Write-Host "Testing Complete!" -ForegroundColor Cyan